@@ -0,0 +1,271 @@
+package nex
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/PretendoNetwork/nex-go/v2/types"
+)
+
+// rateLimiterPruneInterval is how often idle addresses are removed from a RateLimiter
+const rateLimiterPruneInterval = time.Minute
+
+// RateLimiter limits how many datagrams or requests each IP address may send, using a token bucket per address.
+//
+// The limit can be changed with SetLimit while the server is running, such as by ServerConfig.Reload.
+// A nil RateLimiter allows everything
+type RateLimiter struct {
+	mutex      sync.Mutex
+	perSecond  float64
+	burst      int
+	buckets    map[netip.Addr]*rateLimitBucket
+	lastPruned time.Time
+}
+
+// rateLimitBucket holds the tokens left for an address, as of the time it was last updated
+type rateLimitBucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+// SetLimit sets how many datagrams or requests each address may send per second, and how many it may send at once
+// after being idle. Addresses are not limited if perSecond is 0. If the limit changed, every address starts again
+// with a full bucket
+func (rl *RateLimiter) SetLimit(perSecond float64, burst int) {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	if rl.buckets != nil && rl.perSecond == perSecond && rl.burst == burst {
+		return
+	}
+
+	rl.perSecond = perSecond
+	rl.burst = burst
+	rl.buckets = make(map[netip.Addr]*rateLimitBucket)
+}
+
+// Limit returns the number of datagrams or requests each address may send per second, and at once
+func (rl *RateLimiter) Limit() (float64, int) {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	return rl.perSecond, rl.burst
+}
+
+// Allow reports whether the address may send another datagram or request, and takes a token from it's bucket if so.
+// Addresses without an IP, such as those of in-memory connections, are never limited
+func (rl *RateLimiter) Allow(address net.Addr) bool {
+	if rl == nil {
+		return true
+	}
+
+	ip, ok := addressIP(address)
+	if !ok {
+		return true
+	}
+
+	return rl.allowAt(ip, time.Now())
+}
+
+func (rl *RateLimiter) allowAt(ip netip.Addr, now time.Time) bool {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	if rl.perSecond <= 0 {
+		return true
+	}
+
+	if now.Sub(rl.lastPruned) >= rateLimiterPruneInterval {
+		rl.prune(now)
+	}
+
+	bucket, ok := rl.buckets[ip]
+	if !ok {
+		bucket = &rateLimitBucket{tokens: float64(rl.burst), updatedAt: now}
+		rl.buckets[ip] = bucket
+	}
+
+	bucket.tokens = rl.refilled(bucket, now)
+	bucket.updatedAt = now
+
+	if bucket.tokens < 1 {
+		return false
+	}
+
+	bucket.tokens--
+
+	return true
+}
+
+// refilled returns the tokens in the bucket at the given time
+func (rl *RateLimiter) refilled(bucket *rateLimitBucket, now time.Time) float64 {
+	return math.Min(float64(rl.burst), bucket.tokens+now.Sub(bucket.updatedAt).Seconds()*rl.perSecond)
+}
+
+// prune removes the buckets which have refilled completely, since a new bucket would be the same.
+// Must be called with the mutex held
+func (rl *RateLimiter) prune(now time.Time) {
+	for ip, bucket := range rl.buckets {
+		if rl.refilled(bucket, now) >= float64(rl.burst) {
+			delete(rl.buckets, ip)
+		}
+	}
+
+	rl.lastPruned = now
+}
+
+// NewRateLimiter returns a new RateLimiter allowing each address perSecond datagrams or requests per second,
+// and burst at once. Addresses are not limited if perSecond is 0
+func NewRateLimiter(perSecond float64, burst int) *RateLimiter {
+	rl := &RateLimiter{}
+	rl.SetLimit(perSecond, burst)
+
+	return rl
+}
+
+// BanList holds the IP addresses and PIDs refused by a server.
+//
+// Datagrams and requests from banned addresses are dropped, so existing connections from them time out.
+// Banned PIDs are refused when they connect to a secure endpoint or send a HPP request. Existing connections
+// of a PID which is banned later are kept, and can be ended with PRUDPEndPoint.FindConnectionByPID.
+//
+// Bans can be changed with Set while the server is running, such as by ServerConfig.Reload. A nil BanList bans nothing
+type BanList struct {
+	mutex     sync.RWMutex
+	addresses []netip.Prefix
+	pids      map[types.PID]struct{}
+}
+
+// Set replaces the banned addresses and PIDs. Single addresses are banned with a prefix covering only them,
+// such as "203.0.113.7/32"
+func (bl *BanList) Set(addresses []netip.Prefix, pids []types.PID) {
+	bannedPIDs := make(map[types.PID]struct{}, len(pids))
+	for _, pid := range pids {
+		bannedPIDs[pid] = struct{}{}
+	}
+
+	bannedAddresses := make([]netip.Prefix, 0, len(addresses))
+	for _, prefix := range addresses {
+		// * IPv4 clients may be seen as IPv4-mapped IPv6 addresses,
+		// * which addressIP unmaps, so bans are stored unmapped too
+		if prefix.Addr().Is4In6() {
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), max(prefix.Bits()-96, 0))
+		}
+
+		bannedAddresses = append(bannedAddresses, prefix.Masked())
+	}
+
+	bl.mutex.Lock()
+	defer bl.mutex.Unlock()
+
+	bl.addresses = bannedAddresses
+	bl.pids = bannedPIDs
+}
+
+// Addresses returns the banned addresses
+func (bl *BanList) Addresses() []netip.Prefix {
+	bl.mutex.RLock()
+	defer bl.mutex.RUnlock()
+
+	return slices.Clone(bl.addresses)
+}
+
+// PIDs returns the banned PIDs, sorted
+func (bl *BanList) PIDs() []types.PID {
+	bl.mutex.RLock()
+	defer bl.mutex.RUnlock()
+
+	pids := make([]types.PID, 0, len(bl.pids))
+	for pid := range bl.pids {
+		pids = append(pids, pid)
+	}
+
+	slices.Sort(pids)
+
+	return pids
+}
+
+// AddressBanned returns true if the IP of the address is banned
+func (bl *BanList) AddressBanned(address net.Addr) bool {
+	if bl == nil {
+		return false
+	}
+
+	ip, ok := addressIP(address)
+	if !ok {
+		return false
+	}
+
+	bl.mutex.RLock()
+	defer bl.mutex.RUnlock()
+
+	for _, prefix := range bl.addresses {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PIDBanned returns true if the PID is banned
+func (bl *BanList) PIDBanned(pid types.PID) bool {
+	if bl == nil {
+		return false
+	}
+
+	bl.mutex.RLock()
+	defer bl.mutex.RUnlock()
+
+	_, banned := bl.pids[pid]
+
+	return banned
+}
+
+// NewBanList returns a new BanList which bans nothing
+func NewBanList() *BanList {
+	return &BanList{
+		addresses: make([]netip.Prefix, 0),
+		pids:      make(map[types.PID]struct{}),
+	}
+}
+
+// addressIP returns the IP of a socket address, without the port or zone. IPv4-mapped IPv6 addresses are unmapped
+func addressIP(address net.Addr) (netip.Addr, bool) {
+	var ip net.IP
+
+	switch address := address.(type) {
+	case nil:
+		return netip.Addr{}, false
+	case *net.UDPAddr:
+		ip = address.IP
+	case *net.TCPAddr:
+		ip = address.IP
+	default:
+		addrPort, err := netip.ParseAddrPort(address.String())
+		if err != nil {
+			return netip.Addr{}, false
+		}
+
+		return addrPort.Addr().WithZone("").Unmap(), true
+	}
+
+	parsed, ok := netip.AddrFromSlice(ip)
+
+	return parsed.Unmap(), ok
+}
+
+// requestAddr returns the remote address of a HTTP request, or nil if it cannot be parsed
+func requestAddr(req *http.Request) net.Addr {
+	addrPort, err := netip.ParseAddrPort(req.RemoteAddr)
+	if err != nil {
+		return nil
+	}
+
+	return net.TCPAddrFromAddrPort(addrPort)
+}
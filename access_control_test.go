@@ -0,0 +1,97 @@
+package nex
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/PretendoNetwork/nex-go/v2/constants"
+	"github.com/PretendoNetwork/nex-go/v2/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter(t *testing.T) {
+	rateLimiter := NewRateLimiter(2, 3)
+	ip := netip.MustParseAddr("203.0.113.7")
+	now := time.Now()
+
+	// * The burst is allowed at once, then 2 per second
+	for i := 0; i < 3; i++ {
+		assert.True(t, rateLimiter.allowAt(ip, now))
+	}
+
+	assert.False(t, rateLimiter.allowAt(ip, now))
+	assert.True(t, rateLimiter.allowAt(ip, now.Add(500*time.Millisecond)))
+	assert.False(t, rateLimiter.allowAt(ip, now.Add(500*time.Millisecond)))
+
+	// * Other addresses have their own bucket
+	assert.True(t, rateLimiter.allowAt(netip.MustParseAddr("203.0.113.8"), now))
+
+	// * Idle addresses are pruned once their bucket is full again
+	rateLimiter.allowAt(ip, now.Add(time.Hour))
+	assert.Len(t, rateLimiter.buckets, 1)
+
+	// * Setting the same limit keeps the buckets, a new limit resets them
+	rateLimiter.SetLimit(2, 3)
+	assert.Len(t, rateLimiter.buckets, 1)
+
+	rateLimiter.SetLimit(0, 0)
+	assert.Empty(t, rateLimiter.buckets)
+
+	for i := 0; i < 10; i++ {
+		assert.True(t, rateLimiter.Allow(&net.UDPAddr{IP: net.IPv4(203, 0, 113, 7), Port: 60000}))
+	}
+
+	var disabled *RateLimiter
+	assert.True(t, disabled.Allow(&net.UDPAddr{IP: net.IPv4(203, 0, 113, 7), Port: 60000}))
+}
+
+func TestBanList(t *testing.T) {
+	banList := NewBanList()
+	banList.Set([]netip.Prefix{
+		netip.MustParsePrefix("203.0.113.0/24"),
+		netip.MustParsePrefix("::ffff:198.51.100.7/128"),
+		netip.MustParsePrefix("2001:db8::/32"),
+	}, []types.PID{types.NewPID(1000)})
+
+	assert.True(t, banList.AddressBanned(&net.UDPAddr{IP: net.IPv4(203, 0, 113, 7), Port: 60000}))
+	assert.False(t, banList.AddressBanned(&net.UDPAddr{IP: net.IPv4(203, 0, 114, 7), Port: 60000}))
+	assert.True(t, banList.AddressBanned(&net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 443}))
+
+	// * IPv4 addresses are matched whether or not they, or the ban, are IPv4-mapped
+	assert.True(t, banList.AddressBanned(&net.UDPAddr{IP: net.ParseIP("::ffff:203.0.113.7"), Port: 60000}))
+	assert.True(t, banList.AddressBanned(&net.UDPAddr{IP: net.IPv4(198, 51, 100, 7).To4(), Port: 60000}))
+	assert.Equal(t, netip.MustParsePrefix("198.51.100.7/32"), banList.Addresses()[1])
+
+	assert.True(t, banList.PIDBanned(types.NewPID(1000)))
+	assert.False(t, banList.PIDBanned(types.NewPID(1001)))
+
+	// * Set replaces every ban
+	banList.Set(nil, nil)
+	assert.False(t, banList.AddressBanned(&net.UDPAddr{IP: net.IPv4(203, 0, 113, 7), Port: 60000}))
+	assert.False(t, banList.PIDBanned(types.NewPID(1000)))
+
+	var disabled *BanList
+	assert.False(t, disabled.AddressBanned(&net.UDPAddr{IP: net.IPv4(203, 0, 113, 7), Port: 60000}))
+	assert.False(t, disabled.PIDBanned(types.NewPID(1000)))
+}
+
+func TestPRUDPServerDropsBannedAndRateLimitedDatagrams(t *testing.T) {
+	server, endpoint := newTestPRUDPServer(t)
+
+	banned := newTestPRUDPClient(t, endpoint)
+	server.BanList.Set([]netip.Prefix{netip.PrefixFrom(netip.MustParseAddr("127.0.0.1"), 32)}, nil)
+
+	banned.send(banned.newPacket(constants.SynPacket, constants.PacketFlagNeedsAck))
+	assert.Empty(t, banned.receiveFor(200*time.Millisecond))
+
+	server.BanList.Set(nil, nil)
+	server.RateLimiter.SetLimit(1, 1)
+
+	limited := newTestPRUDPClient(t, endpoint)
+	limited.syn()
+
+	limited.send(limited.newPacket(constants.SynPacket, constants.PacketFlagNeedsAck))
+	assert.Empty(t, limited.receiveFor(200*time.Millisecond))
+}
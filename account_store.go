@@ -0,0 +1,166 @@
+package nex
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/PretendoNetwork/nex-go/v2/types"
+)
+
+// AccountStore looks up the game server accounts used for Kerberos authentication
+type AccountStore interface {
+	AccountByPID(ctx context.Context, pid types.PID) (*Account, *Error)
+	AccountByUsername(ctx context.Context, username string) (*Account, *Error)
+}
+
+// KerberosKeyStore is implemented by AccountStores which can return the Kerberos key of an account
+// without deriving it each time, such as CachingAccountStore
+type KerberosKeyStore interface {
+	KerberosKey(ctx context.Context, pid types.PID) ([]byte, *Error)
+}
+
+// AccountStoreFuncs adapts a pair of lookup functions to an AccountStore.
+// Used to support the AccountDetailsByPID and AccountDetailsByUsername fields
+type AccountStoreFuncs struct {
+	ByPID      func(pid types.PID) (*Account, *Error)
+	ByUsername func(username string) (*Account, *Error)
+}
+
+// AccountByPID calls ByPID. The context is ignored
+func (asf AccountStoreFuncs) AccountByPID(_ context.Context, pid types.PID) (*Account, *Error) {
+	if asf.ByPID == nil {
+		return nil, NewError(ResultCodes.Core.NotImplemented, "No account lookup by PID set")
+	}
+
+	return asf.ByPID(pid)
+}
+
+// AccountByUsername calls ByUsername. The context is ignored
+func (asf AccountStoreFuncs) AccountByUsername(_ context.Context, username string) (*Account, *Error) {
+	if asf.ByUsername == nil {
+		return nil, NewError(ResultCodes.Core.NotImplemented, "No account lookup by username set")
+	}
+
+	return asf.ByUsername(username)
+}
+
+// cachedKerberosKey is a Kerberos key held by a CachingAccountStore
+type cachedKerberosKey struct {
+	key       []byte
+	expiresAt time.Time
+}
+
+// CachingAccountStore wraps an AccountStore and caches the Kerberos keys derived from it's accounts.
+//
+// Deriving a key takes tens of thousands of MD5 rounds, and happens on every secure CONNECT and HPP request.
+// Cached keys are used until they expire, so a changed password is only picked up once the key expires or
+// is invalidated. Call Invalidate when an accounts password changes
+type CachingAccountStore struct {
+	Store     AccountStore
+	TTL       time.Duration // * How long a derived key is used before it is derived again
+	keys      *MutexMap[types.PID, cachedKerberosKey]
+	pruneLock sync.Mutex
+	prunedAt  time.Time
+}
+
+// AccountByPID looks up the account in the wrapped store
+func (cas *CachingAccountStore) AccountByPID(ctx context.Context, pid types.PID) (*Account, *Error) {
+	return cas.Store.AccountByPID(ctx, pid)
+}
+
+// AccountByUsername looks up the account in the wrapped store
+func (cas *CachingAccountStore) AccountByUsername(ctx context.Context, username string) (*Account, *Error) {
+	return cas.Store.AccountByUsername(ctx, username)
+}
+
+// KerberosKey returns the Kerberos key of the account with the given PID,
+// deriving it from the wrapped store only if it is not cached
+func (cas *CachingAccountStore) KerberosKey(ctx context.Context, pid types.PID) ([]byte, *Error) {
+	now := time.Now()
+
+	if cached, ok := cas.keys.Get(pid); ok && now.Before(cached.expiresAt) {
+		return cached.key, nil
+	}
+
+	cas.pruneExpired(now)
+
+	account, err := cas.Store.AccountByPID(ctx, pid)
+	if err != nil {
+		return nil, err
+	}
+
+	if account == nil {
+		return nil, NewError(ResultCodes.RendezVous.InvalidPID, "Account does not exist")
+	}
+
+	key := DeriveKerberosKey(pid, []byte(account.Password))
+
+	cas.keys.Set(pid, cachedKerberosKey{
+		key:       key,
+		expiresAt: now.Add(cas.TTL),
+	})
+
+	return key, nil
+}
+
+// Invalidate removes the cached Kerberos key of the account with the given PID
+func (cas *CachingAccountStore) Invalidate(pid types.PID) {
+	cas.keys.Delete(pid)
+}
+
+// InvalidateAll removes every cached Kerberos key
+func (cas *CachingAccountStore) InvalidateAll() {
+	cas.keys.Clear(func(_ types.PID, _ cachedKerberosKey) {})
+}
+
+// pruneExpired removes expired keys, at most once per TTL, so keys of accounts which are
+// never seen again do not stay in memory
+func (cas *CachingAccountStore) pruneExpired(now time.Time) {
+	cas.pruneLock.Lock()
+
+	if now.Sub(cas.prunedAt) < cas.TTL {
+		cas.pruneLock.Unlock()
+		return
+	}
+
+	cas.prunedAt = now
+	cas.pruneLock.Unlock()
+
+	cas.keys.DeleteIf(func(_ types.PID, cached cachedKerberosKey) bool {
+		return !now.Before(cached.expiresAt)
+	})
+}
+
+// NewCachingAccountStore returns a new CachingAccountStore which caches the Kerberos keys of the stores accounts for ttl
+func NewCachingAccountStore(store AccountStore, ttl time.Duration) *CachingAccountStore {
+	return &CachingAccountStore{
+		Store: store,
+		TTL:   ttl,
+		keys:  NewMutexMap[types.PID, cachedKerberosKey](),
+	}
+}
+
+// kerberosKey returns the Kerberos key of the account with the given PID.
+// The key is taken from the store if it is a KerberosKeyStore, otherwise it is derived from the account.
+// If account is nil, it is looked up in the store when needed
+func kerberosKey(ctx context.Context, store AccountStore, pid types.PID, account *Account) ([]byte, *Error) {
+	if keyStore, ok := store.(KerberosKeyStore); ok {
+		return keyStore.KerberosKey(ctx, pid)
+	}
+
+	if account == nil {
+		var err *Error
+
+		account, err = store.AccountByPID(ctx, pid)
+		if err != nil {
+			return nil, err
+		}
+
+		if account == nil {
+			return nil, NewError(ResultCodes.RendezVous.InvalidPID, "Account does not exist")
+		}
+	}
+
+	return DeriveKerberosKey(pid, []byte(account.Password)), nil
+}
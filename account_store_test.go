@@ -0,0 +1,62 @@
+package nex
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/PretendoNetwork/nex-go/v2/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCachingAccountStore(t *testing.T) {
+	ctx := context.Background()
+	account := NewAccount(types.NewPID(1000), "1000", "password")
+	lookups := 0
+
+	store := NewCachingAccountStore(AccountStoreFuncs{
+		ByPID: func(pid types.PID) (*Account, *Error) {
+			lookups++
+
+			if pid != account.PID {
+				return nil, NewError(ResultCodes.RendezVous.InvalidPID, "Invalid PID")
+			}
+
+			return account, nil
+		},
+	}, time.Hour)
+
+	key, err := store.KerberosKey(ctx, account.PID)
+	assert.Nil(t, err)
+	assert.Equal(t, DeriveKerberosKey(account.PID, []byte("password")), key)
+
+	// * Cached keys are not derived again
+	key, _ = store.KerberosKey(ctx, account.PID)
+	assert.Equal(t, DeriveKerberosKey(account.PID, []byte("password")), key)
+	assert.Equal(t, 1, lookups)
+
+	// * Invalidated keys pick up the new password
+	account.Password = "changed"
+	store.Invalidate(account.PID)
+
+	key, _ = store.KerberosKey(ctx, account.PID)
+	assert.Equal(t, DeriveKerberosKey(account.PID, []byte("changed")), key)
+	assert.Equal(t, 2, lookups)
+
+	// * Expired keys are derived again
+	store.TTL = 0
+	store.InvalidateAll()
+	_, _ = store.KerberosKey(ctx, account.PID)
+	_, _ = store.KerberosKey(ctx, account.PID)
+	assert.Equal(t, 4, lookups)
+
+	_, err = store.KerberosKey(ctx, types.NewPID(2000))
+	if assert.NotNil(t, err) {
+		assert.Equal(t, NewError(ResultCodes.RendezVous.InvalidPID, "").ResultCode, err.ResultCode)
+	}
+
+	// * Keys are derived from stores without a cache
+	key, err = kerberosKey(ctx, store.Store, account.PID, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, DeriveKerberosKey(account.PID, []byte("changed")), key)
+}
@@ -0,0 +1,513 @@
+package nex
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxAdminRecentErrors is the number of errors kept by an AdminHandler
+const maxAdminRecentErrors = 100
+
+// AdminHandler is an http.Handler exposing a JSON API for inspecting and managing a running PRUDPServer.
+//
+// The handler performs no authentication of it's own. It is meant to be mounted behind the callers own
+// auth middleware, optionally with http.StripPrefix. The following routes are available:
+//
+//	GET  /endpoints              - Bound endpoints and their stream settings
+//	PATCH /endpoints/{stream_id}/stream-settings - Updates an endpoints stream settings. The body is a JSON StreamSettingsConfig
+//	GET  /connections            - Connections on all endpoints. Filter with ?stream_id=, ?pid= or ?connection_id=
+//	POST /kick                   - Disconnects connections matching ?pid= or ?connection_id=. Optionally filtered by ?stream_id=
+//	POST /broadcast-disconnect   - Disconnects every connection. Optionally filtered by ?stream_id=
+//	GET  /library-versions       - The servers LibraryVersions
+//	GET  /errors                 - The most recent errors emitted by the servers endpoints
+//	GET  /bans                   - The banned addresses and PIDs
+//	PUT  /bans                   - Replaces the banned addresses and PIDs. The body is a JSON BanListConfig
+//	GET  /rate-limit             - The limit on datagrams sent by each address
+//	PUT  /rate-limit             - Replaces the limit on datagrams sent by each address. The body is a JSON RateLimitConfig
+//
+// Bans and rate limits set through the handler are replaced when the server config is reloaded. See ServerConfig.Reload
+type AdminHandler struct {
+	server       *PRUDPServer
+	mux          *http.ServeMux
+	errorsMutex  sync.Mutex
+	recentErrors []adminErrorView
+}
+
+type adminStreamSettingsView struct {
+	ExtraRetransmitTimeoutTrigger    uint32  `json:"extra_retransmit_timeout_trigger"`
+	MaxPacketRetransmissions         uint32  `json:"max_packet_retransmissions"`
+	KeepAliveTimeout                 uint32  `json:"keep_alive_timeout"`
+	ChecksumBase                     uint32  `json:"checksum_base"`
+	FaultDetectionEnabled            bool    `json:"fault_detection_enabled"`
+	InitialRTT                       uint32  `json:"initial_rtt"`
+	SynInitialRTT                    uint32  `json:"syn_initial_rtt"`
+	EncryptionAlgorithm              string  `json:"encryption_algorithm"`
+	ExtraRetransmitTimeoutMultiplier float32 `json:"extra_retransmit_timeout_multiplier"`
+	WindowSize                       uint32  `json:"window_size"`
+	CompressionAlgorithm             string  `json:"compression_algorithm"`
+	RTTRetransmit                    uint32  `json:"rtt_retransmit"`
+	RetransmitTimeoutMultiplier      float32 `json:"retransmit_timeout_multiplier"`
+	MaxSilenceTime                   uint32  `json:"max_silence_time"`
+}
+
+type adminEndpointView struct {
+	StreamID         uint8                   `json:"stream_id"`
+	IsSecureEndPoint bool                    `json:"is_secure_endpoint"`
+	Connections      int                     `json:"connections"`
+	StreamSettings   adminStreamSettingsView `json:"stream_settings"`
+}
+
+type adminConnectionView struct {
+	ID              uint32  `json:"id"`
+	EndpointID      uint8   `json:"endpoint_stream_id"`
+	PID             uint64  `json:"pid"`
+	Address         string  `json:"address"`
+	State           string  `json:"state"`
+	StreamType      string  `json:"stream_type"`
+	StreamID        uint8   `json:"stream_id"`
+	PRUDPVersion    int     `json:"prudp_version"`
+	RTTMilliseconds float64 `json:"rtt_ms"`
+	AgeSeconds      float64 `json:"age_seconds"`
+}
+
+type adminBanListView struct {
+	Addresses []string `json:"addresses"`
+	PIDs      []uint64 `json:"pids"`
+}
+
+type adminRateLimitView struct {
+	PerSecond float64 `json:"per_second"`
+	Burst     int     `json:"burst"`
+}
+
+type adminErrorView struct {
+	Time       time.Time `json:"time"`
+	EndpointID uint8     `json:"endpoint_stream_id"`
+	ResultCode uint32    `json:"result_code"`
+	Error      string    `json:"error"`
+	Address    string    `json:"address,omitempty"`
+}
+
+// ServeHTTP satisfies the http.Handler interface
+func (ah *AdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ah.mux.ServeHTTP(w, r)
+}
+
+// recordError adds an error to the recent errors, dropping the oldest error if needed
+func (ah *AdminHandler) recordError(endpoint *PRUDPEndPoint, err *Error) {
+	view := adminErrorView{
+		Time:       time.Now(),
+		EndpointID: endpoint.StreamID,
+		ResultCode: err.ResultCode,
+		Error:      err.Error(),
+	}
+
+	if err.Packet != nil && err.Packet.Sender() != nil {
+		view.Address = err.Packet.Sender().Address().String()
+	}
+
+	ah.errorsMutex.Lock()
+	defer ah.errorsMutex.Unlock()
+
+	if len(ah.recentErrors) == maxAdminRecentErrors {
+		ah.recentErrors = append(ah.recentErrors[:0], ah.recentErrors[1:]...)
+	}
+
+	ah.recentErrors = append(ah.recentErrors, view)
+}
+
+func (ah *AdminHandler) handleEndpoints(w http.ResponseWriter, r *http.Request) {
+	endpoints := make([]adminEndpointView, 0)
+
+	ah.server.Endpoints.Each(func(streamID uint8, endpoint *PRUDPEndPoint) bool {
+		endpoints = append(endpoints, adminEndpointView{
+			StreamID:         streamID,
+			IsSecureEndPoint: endpoint.IsSecureEndPoint,
+			Connections:      endpoint.Connections.Size(),
+			StreamSettings:   newAdminStreamSettingsView(endpoint.defaultStreamSettings()),
+		})
+
+		return false
+	})
+
+	ah.writeJSON(w, http.StatusOK, endpoints)
+}
+
+func (ah *AdminHandler) handleUpdateStreamSettings(w http.ResponseWriter, r *http.Request) {
+	streamID, err := strconv.ParseUint(r.PathValue("stream_id"), 10, 8)
+	if err != nil {
+		ah.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid stream_id"})
+		return
+	}
+
+	endpoint, ok := ah.server.Endpoints.Get(uint8(streamID))
+	if !ok {
+		ah.writeJSON(w, http.StatusNotFound, map[string]string{"error": "endpoint not found"})
+		return
+	}
+
+	var config StreamSettingsConfig
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&config); err != nil {
+		ah.writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	// * Only the fields in the body are changed
+	settings := endpoint.defaultStreamSettings().Copy()
+	problems := &ConfigError{}
+
+	config.apply(settings, "stream_settings", problems)
+
+	if len(problems.Problems) != 0 {
+		ah.writeJSON(w, http.StatusBadRequest, map[string][]string{"errors": problems.Problems})
+		return
+	}
+
+	endpoint.UpdateStreamSettings(settings)
+
+	ah.writeJSON(w, http.StatusOK, newAdminStreamSettingsView(endpoint.defaultStreamSettings()))
+}
+
+func (ah *AdminHandler) handleBans(w http.ResponseWriter, r *http.Request) {
+	if ah.server.BanList == nil {
+		ah.writeJSON(w, http.StatusNotFound, map[string]string{"error": "server has no ban list"})
+		return
+	}
+
+	ah.writeJSON(w, http.StatusOK, newAdminBanListView(ah.server.BanList))
+}
+
+func (ah *AdminHandler) handleUpdateBans(w http.ResponseWriter, r *http.Request) {
+	if ah.server.BanList == nil {
+		ah.writeJSON(w, http.StatusNotFound, map[string]string{"error": "server has no ban list"})
+		return
+	}
+
+	var config BanListConfig
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&config); err != nil {
+		ah.writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	// * Parsed into a new list first, so invalid bans do not replace the current ones
+	banList := NewBanList()
+	problems := &ConfigError{}
+
+	config.apply(banList, "bans", problems)
+
+	if len(problems.Problems) != 0 {
+		ah.writeJSON(w, http.StatusBadRequest, map[string][]string{"errors": problems.Problems})
+		return
+	}
+
+	ah.server.BanList.Set(banList.Addresses(), banList.PIDs())
+
+	ah.writeJSON(w, http.StatusOK, newAdminBanListView(ah.server.BanList))
+}
+
+func (ah *AdminHandler) handleRateLimit(w http.ResponseWriter, r *http.Request) {
+	if ah.server.RateLimiter == nil {
+		ah.writeJSON(w, http.StatusNotFound, map[string]string{"error": "server has no rate limiter"})
+		return
+	}
+
+	ah.writeJSON(w, http.StatusOK, newAdminRateLimitView(ah.server.RateLimiter))
+}
+
+func (ah *AdminHandler) handleUpdateRateLimit(w http.ResponseWriter, r *http.Request) {
+	if ah.server.RateLimiter == nil {
+		ah.writeJSON(w, http.StatusNotFound, map[string]string{"error": "server has no rate limiter"})
+		return
+	}
+
+	var config RateLimitConfig
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&config); err != nil {
+		ah.writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	rateLimiter := NewRateLimiter(0, 0)
+	problems := &ConfigError{}
+
+	config.apply(rateLimiter, "rate_limit", problems)
+
+	if len(problems.Problems) != 0 {
+		ah.writeJSON(w, http.StatusBadRequest, map[string][]string{"errors": problems.Problems})
+		return
+	}
+
+	ah.server.RateLimiter.SetLimit(rateLimiter.Limit())
+
+	ah.writeJSON(w, http.StatusOK, newAdminRateLimitView(ah.server.RateLimiter))
+}
+
+func (ah *AdminHandler) handleConnections(w http.ResponseWriter, r *http.Request) {
+	filter, ok := ah.parseConnectionFilter(w, r)
+	if !ok {
+		return
+	}
+
+	connections := make([]adminConnectionView, 0)
+	for _, connection := range ah.findConnections(filter) {
+		connections = append(connections, newAdminConnectionView(connection))
+	}
+
+	ah.writeJSON(w, http.StatusOK, connections)
+}
+
+func (ah *AdminHandler) handleKick(w http.ResponseWriter, r *http.Request) {
+	filter, ok := ah.parseConnectionFilter(w, r)
+	if !ok {
+		return
+	}
+
+	if filter.pid == nil && filter.connectionID == nil {
+		ah.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "pid or connection_id is required"})
+		return
+	}
+
+	ah.disconnect(w, filter)
+}
+
+func (ah *AdminHandler) handleBroadcastDisconnect(w http.ResponseWriter, r *http.Request) {
+	filter, ok := ah.parseConnectionFilter(w, r)
+	if !ok {
+		return
+	}
+
+	ah.disconnect(w, filter)
+}
+
+func (ah *AdminHandler) disconnect(w http.ResponseWriter, filter adminConnectionFilter) {
+	connections := ah.findConnections(filter)
+
+	// * Connections can not be removed while the Connections
+	// * MutexMap is locked, so they are disconnected only
+	// * after all of them have been found
+	for _, connection := range connections {
+		connection.endpoint.Disconnect(connection)
+	}
+
+	ah.writeJSON(w, http.StatusOK, map[string]int{"disconnected": len(connections)})
+}
+
+func (ah *AdminHandler) handleLibraryVersions(w http.ResponseWriter, r *http.Request) {
+	ah.writeJSON(w, http.StatusOK, ah.server.LibraryVersions)
+}
+
+func (ah *AdminHandler) handleErrors(w http.ResponseWriter, r *http.Request) {
+	ah.errorsMutex.Lock()
+	recentErrors := append(make([]adminErrorView, 0, len(ah.recentErrors)), ah.recentErrors...)
+	ah.errorsMutex.Unlock()
+
+	ah.writeJSON(w, http.StatusOK, recentErrors)
+}
+
+// adminConnectionFilter holds the optional query parameters used to select connections
+type adminConnectionFilter struct {
+	streamID     *uint8
+	pid          *uint64
+	connectionID *uint32
+}
+
+func (acf adminConnectionFilter) matches(endpoint *PRUDPEndPoint, connection *PRUDPConnection) bool {
+	if acf.streamID != nil && endpoint.StreamID != *acf.streamID {
+		return false
+	}
+
+	if acf.pid != nil && uint64(connection.PID()) != *acf.pid {
+		return false
+	}
+
+	if acf.connectionID != nil && connection.ID != *acf.connectionID {
+		return false
+	}
+
+	return true
+}
+
+func (ah *AdminHandler) findConnections(filter adminConnectionFilter) []*PRUDPConnection {
+	connections := make([]*PRUDPConnection, 0)
+
+	ah.server.Endpoints.Each(func(_ uint8, endpoint *PRUDPEndPoint) bool {
+		endpoint.Connections.Each(func(_ string, connection *PRUDPConnection) bool {
+			if filter.matches(endpoint, connection) {
+				connections = append(connections, connection)
+			}
+
+			return false
+		})
+
+		return false
+	})
+
+	return connections
+}
+
+func (ah *AdminHandler) parseConnectionFilter(w http.ResponseWriter, r *http.Request) (adminConnectionFilter, bool) {
+	var filter adminConnectionFilter
+	query := r.URL.Query()
+
+	if value := query.Get("stream_id"); value != "" {
+		streamID, err := strconv.ParseUint(value, 10, 8)
+		if err != nil {
+			ah.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid stream_id"})
+			return filter, false
+		}
+
+		filter.streamID = new(uint8)
+		*filter.streamID = uint8(streamID)
+	}
+
+	if value := query.Get("pid"); value != "" {
+		pid, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			ah.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid pid"})
+			return filter, false
+		}
+
+		filter.pid = &pid
+	}
+
+	if value := query.Get("connection_id"); value != "" {
+		connectionID, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			ah.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid connection_id"})
+			return filter, false
+		}
+
+		filter.connectionID = new(uint32)
+		*filter.connectionID = uint32(connectionID)
+	}
+
+	return filter, true
+}
+
+func newAdminStreamSettingsView(settings *StreamSettings) adminStreamSettingsView {
+	return adminStreamSettingsView{
+		ExtraRetransmitTimeoutTrigger:    settings.ExtraRetransmitTimeoutTrigger,
+		MaxPacketRetransmissions:         settings.MaxPacketRetransmissions,
+		KeepAliveTimeout:                 settings.KeepAliveTimeout,
+		ChecksumBase:                     settings.ChecksumBase,
+		FaultDetectionEnabled:            settings.FaultDetectionEnabled,
+		InitialRTT:                       settings.InitialRTT,
+		SynInitialRTT:                    settings.SynInitialRTT,
+		EncryptionAlgorithm:              algorithmName(settings.EncryptionAlgorithm),
+		ExtraRetransmitTimeoutMultiplier: settings.ExtraRetransmitTimeoutMultiplier,
+		WindowSize:                       settings.WindowSize,
+		CompressionAlgorithm:             algorithmName(settings.CompressionAlgorithm),
+		RTTRetransmit:                    settings.RTTRetransmit,
+		RetransmitTimeoutMultiplier:      settings.RetransmitTimeoutMultiplier,
+		MaxSilenceTime:                   settings.MaxSilenceTime,
+	}
+}
+
+func newAdminBanListView(banList *BanList) adminBanListView {
+	view := adminBanListView{
+		Addresses: make([]string, 0),
+		PIDs:      make([]uint64, 0),
+	}
+
+	for _, prefix := range banList.Addresses() {
+		view.Addresses = append(view.Addresses, prefix.String())
+	}
+
+	for _, pid := range banList.PIDs() {
+		view.PIDs = append(view.PIDs, uint64(pid))
+	}
+
+	return view
+}
+
+func newAdminRateLimitView(rateLimiter *RateLimiter) adminRateLimitView {
+	perSecond, burst := rateLimiter.Limit()
+
+	return adminRateLimitView{
+		PerSecond: perSecond,
+		Burst:     burst,
+	}
+}
+
+func newAdminConnectionView(connection *PRUDPConnection) adminConnectionView {
+	stats := connection.Stats()
+
+	return adminConnectionView{
+		ID:              stats.ID,
+		EndpointID:      connection.endpoint.StreamID,
+		PID:             uint64(stats.PID),
+		Address:         stats.Address,
+		State:           stats.State.String(),
+		StreamType:      connection.StreamType.String(),
+		StreamID:        connection.StreamID,
+		PRUDPVersion:    connection.DefaultPRUDPVersion,
+		RTTMilliseconds: float64(stats.SmoothedRTT) / float64(time.Millisecond),
+		AgeSeconds:      stats.Age.Seconds(),
+	}
+}
+
+// algorithmName returns the type name of an encryption or compression algorithm, without the package name
+func algorithmName(algorithm any) string {
+	name := fmt.Sprintf("%T", algorithm)
+
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+func (ah *AdminHandler) writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	err := json.NewEncoder(w).Encode(value)
+	if err != nil {
+		ah.server.log().Error(err.Error())
+	}
+}
+
+// NewAdminHandler returns a new AdminHandler for the given server.
+// The handler records errors emitted by every endpoint bound to the server at the time it is created,
+// so it should be created after all endpoints have been bound
+func NewAdminHandler(server *PRUDPServer) *AdminHandler {
+	ah := &AdminHandler{
+		server:       server,
+		mux:          http.NewServeMux(),
+		recentErrors: make([]adminErrorView, 0, maxAdminRecentErrors),
+	}
+
+	ah.mux.HandleFunc("GET /endpoints", ah.handleEndpoints)
+	ah.mux.HandleFunc("PATCH /endpoints/{stream_id}/stream-settings", ah.handleUpdateStreamSettings)
+	ah.mux.HandleFunc("GET /connections", ah.handleConnections)
+	ah.mux.HandleFunc("POST /kick", ah.handleKick)
+	ah.mux.HandleFunc("POST /broadcast-disconnect", ah.handleBroadcastDisconnect)
+	ah.mux.HandleFunc("GET /library-versions", ah.handleLibraryVersions)
+	ah.mux.HandleFunc("GET /errors", ah.handleErrors)
+	ah.mux.HandleFunc("GET /bans", ah.handleBans)
+	ah.mux.HandleFunc("PUT /bans", ah.handleUpdateBans)
+	ah.mux.HandleFunc("GET /rate-limit", ah.handleRateLimit)
+	ah.mux.HandleFunc("PUT /rate-limit", ah.handleUpdateRateLimit)
+
+	server.Endpoints.Each(func(_ uint8, endpoint *PRUDPEndPoint) bool {
+		endpoint.OnError(func(err *Error) {
+			ah.recordError(endpoint, err)
+		})
+
+		return false
+	})
+
+	return ah
+}
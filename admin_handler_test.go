@@ -0,0 +1,92 @@
+package nex
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/PretendoNetwork/nex-go/v2/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminHandlerEndpoints(t *testing.T) {
+	server := NewPRUDPServer()
+	server.BindPRUDPEndPoint(NewPRUDPEndPoint(1))
+
+	handler := NewAdminHandler(server)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/endpoints", nil))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	var endpoints []adminEndpointView
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &endpoints))
+	assert.Len(t, endpoints, 1)
+	assert.Equal(t, uint8(1), endpoints[0].StreamID)
+	assert.Equal(t, "RC4", endpoints[0].StreamSettings.EncryptionAlgorithm)
+}
+
+func TestAdminHandlerKickRequiresTarget(t *testing.T) {
+	handler := NewAdminHandler(NewPRUDPServer())
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/kick", nil))
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestAdminHandlerUpdateStreamSettings(t *testing.T) {
+	server := NewPRUDPServer()
+	server.BindPRUDPEndPoint(NewPRUDPEndPoint(1))
+
+	handler := NewAdminHandler(server)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPatch, "/endpoints/1/stream-settings", strings.NewReader(`{"max_silence_time": 30000}`)))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	endpoint, _ := server.Endpoints.Get(1)
+	assert.Equal(t, uint32(30000), endpoint.DefaultStreamSettings.MaxSilenceTime)
+	assert.Equal(t, uint32(0x14), endpoint.DefaultStreamSettings.MaxPacketRetransmissions)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPatch, "/endpoints/1/stream-settings", strings.NewReader(`{"retransmit_timeout_multiplier": -1}`)))
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestAdminHandlerUpdateBans(t *testing.T) {
+	server := NewPRUDPServer()
+	handler := NewAdminHandler(server)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPut, "/bans", strings.NewReader(`{"addresses": ["203.0.113.7", "2001:db8::/32"], "pids": [1000]}`)))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	var bans adminBanListView
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &bans))
+	assert.Equal(t, []string{"203.0.113.7/32", "2001:db8::/32"}, bans.Addresses)
+	assert.Equal(t, []uint64{1000}, bans.PIDs)
+	assert.True(t, server.BanList.PIDBanned(types.NewPID(1000)))
+
+	// * Invalid bans leave the current ones in place
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPut, "/bans", strings.NewReader(`{"addresses": ["203.0.113"]}`)))
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.True(t, server.BanList.PIDBanned(types.NewPID(1000)))
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPut, "/rate-limit", strings.NewReader(`{"per_second": 2.5}`)))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	perSecond, burst := server.RateLimiter.Limit()
+	assert.Equal(t, 2.5, perSecond)
+	assert.Equal(t, 3, burst)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"time"
+
+	"github.com/PretendoNetwork/nex-go/v2"
+	"github.com/PretendoNetwork/nex-go/v2/pcapng"
+)
+
+const (
+	linkTypeNull     = 0
+	linkTypeEthernet = 1
+	linkTypeLinuxSLL = 113
+	linkTypeLoop     = 108
+	linkTypeSLL2     = 276
+)
+
+// capturedDatagram is a single UDP datagram read from a capture
+type capturedDatagram struct {
+	timestamp   time.Time
+	direction   pcapng.Direction
+	source      *net.UDPAddr
+	destination *net.UDPAddr
+	payload     []byte
+	sessionKey  []byte
+}
+
+// readCapture reads every UDP datagram from a pcap or pcapng capture. Packets which are not UDP are skipped
+func readCapture(input []byte) ([]capturedDatagram, error) {
+	if len(input) < 4 {
+		return nil, errors.New("capture is too short to contain a pcap or pcapng header")
+	}
+
+	reader, err := pcapng.NewCaptureReader(bytes.NewReader(input))
+	if err != nil {
+		return nil, err
+	}
+
+	datagrams := make([]capturedDatagram, 0)
+
+	for {
+		packet, linkType, err := reader.ReadPacket()
+		if errors.Is(err, io.EOF) {
+			return datagrams, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		datagram, ok := decodeLinkLayer(packet.Data, linkType)
+		if !ok {
+			continue
+		}
+
+		datagram.timestamp = packet.Timestamp
+		datagram.direction = packet.Direction
+		datagram.sessionKey, _ = nex.CapturedSessionKey(packet)
+
+		datagrams = append(datagrams, datagram)
+	}
+}
+
+// decodeLinkLayer strips the link layer header from a frame and decodes the UDP datagram inside it
+func decodeLinkLayer(frame []byte, linkType uint16) (capturedDatagram, bool) {
+	switch linkType {
+	case pcapng.LinkTypeRaw:
+	case linkTypeNull, linkTypeLoop:
+		frame = skip(frame, 4)
+	case linkTypeEthernet:
+		if len(frame) < 14 {
+			return capturedDatagram{}, false
+		}
+
+		// * Skip any 802.1Q VLAN tags
+		etherTypeOffset := 12
+		for etherTypeOffset+4 <= len(frame) && binary.BigEndian.Uint16(frame[etherTypeOffset:]) == 0x8100 {
+			etherTypeOffset += 4
+		}
+
+		frame = skip(frame, etherTypeOffset+2)
+	case linkTypeLinuxSLL:
+		frame = skip(frame, 16)
+	case linkTypeSLL2:
+		frame = skip(frame, 20)
+	default:
+		return capturedDatagram{}, false
+	}
+
+	source, destination, payload, err := pcapng.DecodeUDP(frame)
+	if err != nil {
+		return capturedDatagram{}, false
+	}
+
+	return capturedDatagram{
+		source:      source,
+		destination: destination,
+		payload:     payload,
+	}, true
+}
+
+func skip(data []byte, n int) []byte {
+	if len(data) < n {
+		return nil
+	}
+
+	return data[n:]
+}
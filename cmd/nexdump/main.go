@@ -0,0 +1,214 @@
+// Package main implements nexdump, a tool which decodes PRUDP packets and the RMC messages they carry.
+//
+// Input may be hex (one datagram per line), a single raw binary datagram, or a pcap/pcapng capture.
+// The PRUDP version of each datagram is detected automatically. Captures made with nex.PacketCapture
+// which include session keys are decrypted without needing -session-key
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/PretendoNetwork/nex-go/v2"
+	"github.com/PretendoNetwork/nex-go/v2/compression"
+	"github.com/PretendoNetwork/nex-go/v2/pcapng"
+)
+
+type options struct {
+	format           string
+	accessKey        string
+	sessionKey       string
+	compression      string
+	quazal           bool
+	enhancedChecksum bool
+	verboseRMC       bool
+	fromServer       bool
+	serverPort       int
+}
+
+func main() {
+	var opts options
+
+	flag.StringVar(&opts.format, "format", "auto", "input format. One of auto, hex, bin or pcap")
+	flag.StringVar(&opts.accessKey, "access-key", "", "access key of the server, used for PRUDPv0 checksums")
+	flag.StringVar(&opts.sessionKey, "session-key", "", "hex encoded session key used to decrypt secure connections")
+	flag.StringVar(&opts.compression, "compression", "none", "payload compression algorithm. One of none, zlib or lzo")
+	flag.BoolVar(&opts.quazal, "quazal", false, "decode PRUDPv0 packets in Quazal mode")
+	flag.BoolVar(&opts.enhancedChecksum, "enhanced-checksum", false, "PRUDPv0 packets use the 4 byte checksum")
+	flag.BoolVar(&opts.verboseRMC, "verbose-rmc", false, "decode RMC messages in the verbose format")
+	flag.BoolVar(&opts.fromServer, "from-server", false, "treat hex and bin input as sent by the server")
+	flag.IntVar(&opts.serverPort, "server-port", 0, "port of the server in pcap input without direction information. Defaults to the lower port")
+	flag.Parse()
+
+	if flag.NArg() > 1 {
+		fmt.Fprintln(os.Stderr, "usage: nexdump [flags] [file]")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	if err := run(opts, flag.Arg(0)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(opts options, path string) error {
+	var input []byte
+	var err error
+
+	if path == "" || path == "-" {
+		input, err = io.ReadAll(os.Stdin)
+	} else {
+		input, err = os.ReadFile(path)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	dissector, err := newDissector(opts)
+	if err != nil {
+		return err
+	}
+
+	format := opts.format
+	if format == "auto" {
+		format = detectFormat(input)
+	}
+
+	// * Addresses are unknown outside of captures
+	// * so every datagram shares the same connection
+	client := &net.UDPAddr{IP: net.IPv4zero}
+
+	switch format {
+	case "hex":
+		for i, line := range strings.Split(string(input), "\n") {
+			line = strings.NewReplacer(" ", "", "\t", "", "\r", "", ":", "", "0x", "").Replace(line)
+			if line == "" {
+				continue
+			}
+
+			datagram, err := hex.DecodeString(line)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", i+1, err)
+			}
+
+			dump(dissector, datagram, client, !opts.fromServer)
+		}
+	case "bin":
+		dump(dissector, input, client, !opts.fromServer)
+	case "pcap":
+		return dumpCapture(dissector, input, opts.serverPort)
+	default:
+		return fmt.Errorf("unknown input format %q", format)
+	}
+
+	return nil
+}
+
+func newDissector(opts options) (*nex.PRUDPDissector, error) {
+	server := nex.NewPRUDPServer()
+	server.AccessKey = opts.accessKey
+	server.UseVerboseRMC = opts.verboseRMC
+	server.PRUDPV0Settings.IsQuazalMode = opts.quazal
+	server.PRUDPV0Settings.UseEnhancedChecksum = opts.enhancedChecksum
+
+	dissector := nex.NewPRUDPDissector(server)
+
+	switch opts.compression {
+	case "none":
+	case "zlib":
+		dissector.StreamSettings.CompressionAlgorithm = compression.NewZlibCompression()
+	case "lzo":
+		dissector.StreamSettings.CompressionAlgorithm = compression.NewLZOCompression()
+	default:
+		return nil, fmt.Errorf("unknown compression algorithm %q", opts.compression)
+	}
+
+	if opts.sessionKey != "" {
+		sessionKey, err := hex.DecodeString(opts.sessionKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid session key: %w", err)
+		}
+
+		dissector.SessionKey = sessionKey
+	}
+
+	return dissector, nil
+}
+
+// detectFormat guesses the format of the input from it's contents
+func detectFormat(input []byte) string {
+	if pcapng.IsCaptureMagic(input) {
+		return "pcap"
+	}
+
+	for _, c := range bytes.TrimSpace(input) {
+		if !strings.ContainsRune("0123456789abcdefABCDEFxX: \t\r\n", rune(c)) {
+			return "bin"
+		}
+	}
+
+	return "hex"
+}
+
+func dump(dissector *nex.PRUDPDissector, datagram []byte, client net.Addr, fromClient bool) []*nex.DissectedPacket {
+	packets, err := dissector.Dissect(datagram, client, fromClient)
+
+	for _, packet := range packets {
+		fmt.Println(packet.String())
+	}
+
+	if err != nil {
+		fmt.Printf("error: %s\n\n", err.Error())
+	}
+
+	return packets
+}
+
+func dumpCapture(dissector *nex.PRUDPDissector, input []byte, serverPort int) error {
+	datagrams, err := readCapture(input)
+	if err != nil {
+		return err
+	}
+
+	for _, datagram := range datagrams {
+		client, fromClient := datagram.source, true
+
+		switch {
+		case datagram.direction != pcapng.DirectionUnknown:
+			fromClient = datagram.direction == pcapng.DirectionInbound
+		case serverPort != 0:
+			fromClient = datagram.destination.Port == serverPort
+		default:
+			fromClient = datagram.destination.Port < datagram.source.Port
+		}
+
+		if !fromClient {
+			client = datagram.destination
+		}
+
+		fmt.Printf("%s %s -> %s\n", datagram.timestamp.Format("2006-01-02 15:04:05.000000"), datagram.source, datagram.destination)
+
+		packets := dump(dissector, datagram.payload, client, fromClient)
+
+		// * Session keys recorded by nex.PacketCapture are attached to
+		// * the CONNECT acknowledgement, and apply from then on
+		if datagram.sessionKey != nil && len(packets) != 0 {
+			dissector.SetSessionKey(client, packets[0].Packet.SourceVirtualPortStreamID(), datagram.sessionKey)
+		}
+	}
+
+	if len(datagrams) == 0 {
+		return errors.New("no UDP datagrams found in capture")
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+// Package main implements nexreplay, a tool which replays the client datagrams of a pcapng capture
+// made with nex.PacketCapture against a running server.
+//
+// Each client address in the capture is given it's own local UDP socket, so the server sees
+// the same number of distinct clients as were recorded. Responses from the server are read and discarded.
+// To replay a capture through a server in process, use PRUDPServer.ReplayCapture instead
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+
+	"github.com/PretendoNetwork/nex-go/v2/pcapng"
+)
+
+func main() {
+	target := flag.String("target", "127.0.0.1:60000", "address of the server to replay the capture against")
+	realtime := flag.Bool("realtime", true, "preserve the original delay between datagrams")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: nexreplay [-target host:port] [-realtime=false] capture.pcapng")
+		os.Exit(2)
+	}
+
+	if err := replay(flag.Arg(0), *target, *realtime); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func replay(path, target string, realtime bool) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	targetAddress, err := net.ResolveUDPAddr("udp", target)
+	if err != nil {
+		return fmt.Errorf("resolving target address: %w", err)
+	}
+
+	reader := pcapng.NewReader(file)
+	clients := make(map[string]*net.UDPConn)
+
+	defer func() {
+		for _, client := range clients {
+			client.Close()
+		}
+	}()
+
+	var lastTimestamp time.Time
+	sent := 0
+
+	for {
+		packet, linkType, err := reader.ReadPacket()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if linkType != pcapng.LinkTypeRaw || packet.Direction != pcapng.DirectionInbound {
+			continue
+		}
+
+		source, _, payload, err := pcapng.DecodeUDP(packet.Data)
+		if err != nil {
+			return fmt.Errorf("decoding captured datagram: %w", err)
+		}
+
+		client, ok := clients[source.String()]
+		if !ok {
+			client, err = net.DialUDP("udp", nil, targetAddress)
+			if err != nil {
+				return fmt.Errorf("opening client socket: %w", err)
+			}
+
+			clients[source.String()] = client
+
+			go discardResponses(client)
+		}
+
+		if realtime && !lastTimestamp.IsZero() {
+			time.Sleep(packet.Timestamp.Sub(lastTimestamp))
+		}
+
+		lastTimestamp = packet.Timestamp
+
+		if _, err := client.Write(payload); err != nil {
+			return fmt.Errorf("sending datagram: %w", err)
+		}
+
+		sent++
+	}
+
+	fmt.Printf("Replayed %d datagrams from %d clients\n", sent, len(clients))
+
+	return nil
+}
+
+func discardResponses(client *net.UDPConn) {
+	buffer := make([]byte, 64000)
+	for {
+		if _, err := client.Read(buffer); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,352 @@
+package nex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/PretendoNetwork/nex-go/v2/constants"
+	"github.com/PretendoNetwork/nex-go/v2/types"
+)
+
+// ErrConnectionNotFound is returned when no node holds a connection for a PID
+var ErrConnectionNotFound = errors.New("no connection found for PID")
+
+// ConnectionLocation is where the connection of a PID is held
+type ConnectionLocation struct {
+	NodeID           string `json:"node_id"`            // * Node holding the connection. Empty for LocalConnectionRegistry
+	EndPointStreamID uint8  `json:"endpoint_stream_id"` // * Stream ID of the PRUDPEndPoint the connection is on
+	ConnectionID     uint32 `json:"connection_id"`
+}
+
+// ConnectionRegistry finds the connections of PIDs and delivers RMC messages to them,
+// whether they are connected to this process or to another node in a cluster
+type ConnectionRegistry interface {
+	Locate(ctx context.Context, pid types.PID) (ConnectionLocation, error) // * Returns ErrConnectionNotFound if the PID is not connected
+	Deliver(ctx context.Context, pid types.PID, message *RMCMessage) error // * Sends the message, usually a notification, to the connection of the PID
+}
+
+// LocalConnectionRegistry is a ConnectionRegistry which only knows the connections of a single PRUDPServer
+type LocalConnectionRegistry struct {
+	server *PRUDPServer
+}
+
+// Locate returns the location of the PIDs connection on the server
+func (lcr *LocalConnectionRegistry) Locate(_ context.Context, pid types.PID) (ConnectionLocation, error) {
+	connection := lcr.find(pid)
+	if connection == nil {
+		return ConnectionLocation{}, ErrConnectionNotFound
+	}
+
+	return ConnectionLocation{
+		EndPointStreamID: connection.endpoint.StreamID,
+		ConnectionID:     connection.ID,
+	}, nil
+}
+
+// Deliver sends the message to the PIDs connection on the server
+func (lcr *LocalConnectionRegistry) Deliver(_ context.Context, pid types.PID, message *RMCMessage) error {
+	connection := lcr.find(pid)
+	if connection == nil {
+		return ErrConnectionNotFound
+	}
+
+	connection.endpoint.sendRMCMessage(connection, message, message.Bytes())
+
+	return nil
+}
+
+func (lcr *LocalConnectionRegistry) find(pid types.PID) *PRUDPConnection {
+	var connection *PRUDPConnection
+
+	lcr.server.Endpoints.Each(func(_ uint8, endpoint *PRUDPEndPoint) bool {
+		connection = endpoint.FindConnectionByPID(uint64(pid))
+		return connection != nil
+	})
+
+	return connection
+}
+
+// NewLocalConnectionRegistry returns a new LocalConnectionRegistry for the connections of the server
+func NewLocalConnectionRegistry(server *PRUDPServer) *LocalConnectionRegistry {
+	return &LocalConnectionRegistry{server: server}
+}
+
+// ClusterMessage is an RMC message sent from one node to the connection of a PID on another node
+type ClusterMessage struct {
+	FromNodeID string    `json:"from_node_id"`
+	PID        types.PID `json:"pid"`
+	Payload    []byte    `json:"payload"` // * The encoded RMC message
+}
+
+// ClusterBackend is the shared state and message bus used by ClusterConnectionRegistry to coordinate nodes.
+// It is implemented on top of a shared store, such as Redis or etcd. MemoryClusterBackend implements it in memory
+type ClusterBackend interface {
+	Register(ctx context.Context, pid types.PID, location ConnectionLocation) error
+	Unregister(ctx context.Context, pid types.PID, location ConnectionLocation) error // * Must only remove the PID if it is still registered at the location, since the PID may have reconnected on another node
+	Lookup(ctx context.Context, pid types.PID) (ConnectionLocation, bool, error)
+	Publish(ctx context.Context, nodeID string, message ClusterMessage) error
+	Subscribe(ctx context.Context, nodeID string, handler func(message ClusterMessage)) error // * Calls handler with every message published to the node until the context is cancelled
+}
+
+// ClusterConnectionRegistry is a ConnectionRegistry shared by several PRUDPServers, each running on their own node.
+//
+// Each node registers it's own connections with the backend as clients connect to it's secure endpoints,
+// and delivers messages published to it by other nodes to it's local connections
+type ClusterConnectionRegistry struct {
+	NodeID  string
+	server  *PRUDPServer
+	local   *LocalConnectionRegistry
+	backend ClusterBackend
+}
+
+// Start registers the connections already on the server, then receives messages sent to the node by other nodes
+// until the context is cancelled. Start blocks, so it is usually started in it's own goroutine
+func (ccr *ClusterConnectionRegistry) Start(ctx context.Context) error {
+	var err error
+
+	ccr.server.Endpoints.Each(func(_ uint8, endpoint *PRUDPEndPoint) bool {
+		endpoint.Connections.Each(func(_ string, connection *PRUDPConnection) bool {
+			if connection.state() == StateConnected {
+				err = errors.Join(err, ccr.register(ctx, connection))
+			}
+
+			return false
+		})
+
+		return false
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return ccr.backend.Subscribe(ctx, ccr.NodeID, ccr.handleMessage)
+}
+
+// Locate returns the location of the PIDs connection, on this node or any other
+func (ccr *ClusterConnectionRegistry) Locate(ctx context.Context, pid types.PID) (ConnectionLocation, error) {
+	if location, err := ccr.local.Locate(ctx, pid); err == nil {
+		location.NodeID = ccr.NodeID
+		return location, nil
+	}
+
+	location, ok, err := ccr.backend.Lookup(ctx, pid)
+	if err != nil {
+		return ConnectionLocation{}, err
+	}
+
+	if !ok {
+		return ConnectionLocation{}, ErrConnectionNotFound
+	}
+
+	return location, nil
+}
+
+// Deliver sends the message to the PIDs connection. If the connection is on another node,
+// the message is published to that node
+func (ccr *ClusterConnectionRegistry) Deliver(ctx context.Context, pid types.PID, message *RMCMessage) error {
+	if err := ccr.local.Deliver(ctx, pid, message); err == nil {
+		return nil
+	}
+
+	location, ok, err := ccr.backend.Lookup(ctx, pid)
+	if err != nil {
+		return err
+	}
+
+	if !ok || location.NodeID == ccr.NodeID {
+		return ErrConnectionNotFound
+	}
+
+	return ccr.backend.Publish(ctx, location.NodeID, ClusterMessage{
+		FromNodeID: ccr.NodeID,
+		PID:        pid,
+		Payload:    message.Bytes(),
+	})
+}
+
+func (ccr *ClusterConnectionRegistry) handleMessage(message ClusterMessage) {
+	connection := ccr.local.find(message.PID)
+	if connection == nil {
+		ccr.server.log().Warn("Dropping cluster message for PID with no connection", "pid", uint64(message.PID), "from_node_id", message.FromNodeID)
+		return
+	}
+
+	connection.endpoint.sendRMCMessage(connection, nil, message.Payload)
+}
+
+func (ccr *ClusterConnectionRegistry) register(ctx context.Context, connection *PRUDPConnection) error {
+	// * Only the secure server knows the PID of a connection
+	if connection.PID() == 0 {
+		return nil
+	}
+
+	return ccr.backend.Register(ctx, connection.PID(), ccr.location(connection))
+}
+
+func (ccr *ClusterConnectionRegistry) location(connection *PRUDPConnection) ConnectionLocation {
+	return ConnectionLocation{
+		NodeID:           ccr.NodeID,
+		EndPointStreamID: connection.endpoint.StreamID,
+		ConnectionID:     connection.ID,
+	}
+}
+
+// NewClusterConnectionRegistry returns a new ClusterConnectionRegistry for the server, which is known to other nodes as nodeID.
+// The registry tracks the connections of every endpoint bound to the server at the time it is created,
+// so it should be created after all endpoints have been bound
+func NewClusterConnectionRegistry(nodeID string, server *PRUDPServer, backend ClusterBackend) *ClusterConnectionRegistry {
+	ccr := &ClusterConnectionRegistry{
+		NodeID:  nodeID,
+		server:  server,
+		local:   NewLocalConnectionRegistry(server),
+		backend: backend,
+	}
+
+	server.Endpoints.Each(func(_ uint8, endpoint *PRUDPEndPoint) bool {
+		endpoint.on("connect", func(packet PacketInterface) {
+			connection := packet.Sender().(*PRUDPConnection)
+			if err := ccr.register(context.Background(), connection); err != nil {
+				server.log().Error("Failed to register connection with cluster", append(connectionLogFields(connection), "error", err.Error())...)
+			}
+		})
+
+		endpoint.OnConnectionEnded(func(connection *PRUDPConnection) {
+			if connection.PID() == 0 {
+				return
+			}
+
+			if err := backend.Unregister(context.Background(), connection.PID(), ccr.location(connection)); err != nil {
+				server.log().Error("Failed to unregister connection from cluster", append(connectionLogFields(connection), "error", err.Error())...)
+			}
+		})
+
+		return false
+	})
+
+	return ccr
+}
+
+// MemoryClusterBackend is a ClusterBackend which keeps everything in memory.
+// It can only connect nodes running in the same process, which makes it useful for tests
+type MemoryClusterBackend struct {
+	mutex       sync.Mutex
+	locations   map[types.PID]ConnectionLocation
+	subscribers map[string]chan ClusterMessage
+}
+
+// Register stores the location of the PIDs connection
+func (mcb *MemoryClusterBackend) Register(_ context.Context, pid types.PID, location ConnectionLocation) error {
+	mcb.mutex.Lock()
+	defer mcb.mutex.Unlock()
+
+	mcb.locations[pid] = location
+
+	return nil
+}
+
+// Unregister removes the PIDs connection if it is still at the location
+func (mcb *MemoryClusterBackend) Unregister(_ context.Context, pid types.PID, location ConnectionLocation) error {
+	mcb.mutex.Lock()
+	defer mcb.mutex.Unlock()
+
+	if mcb.locations[pid] == location {
+		delete(mcb.locations, pid)
+	}
+
+	return nil
+}
+
+// Lookup returns the location of the PIDs connection
+func (mcb *MemoryClusterBackend) Lookup(_ context.Context, pid types.PID) (ConnectionLocation, bool, error) {
+	mcb.mutex.Lock()
+	defer mcb.mutex.Unlock()
+
+	location, ok := mcb.locations[pid]
+
+	return location, ok, nil
+}
+
+// Publish sends the message to the node. Fails if the node is not subscribed
+func (mcb *MemoryClusterBackend) Publish(ctx context.Context, nodeID string, message ClusterMessage) error {
+	mcb.mutex.Lock()
+	messages, ok := mcb.subscribers[nodeID]
+	mcb.mutex.Unlock()
+
+	if !ok {
+		return fmt.Errorf("Cluster node %q is not subscribed", nodeID)
+	}
+
+	select {
+	case messages <- message:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Subscribe calls handler with every message published to the node until the context is cancelled
+func (mcb *MemoryClusterBackend) Subscribe(ctx context.Context, nodeID string, handler func(message ClusterMessage)) error {
+	messages := make(chan ClusterMessage, 64)
+
+	mcb.mutex.Lock()
+	mcb.subscribers[nodeID] = messages
+	mcb.mutex.Unlock()
+
+	defer func() {
+		mcb.mutex.Lock()
+		delete(mcb.subscribers, nodeID)
+		mcb.mutex.Unlock()
+	}()
+
+	for {
+		select {
+		case message := <-messages:
+			handler(message)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// NewMemoryClusterBackend returns a new, empty MemoryClusterBackend
+func NewMemoryClusterBackend() *MemoryClusterBackend {
+	return &MemoryClusterBackend{
+		locations:   make(map[types.PID]ConnectionLocation),
+		subscribers: make(map[string]chan ClusterMessage),
+	}
+}
+
+// sendRMCMessage sends an encoded RMC message to the connection on a reliable DATA packet.
+// message may be nil if only the encoded payload is known
+func (pep *PRUDPEndPoint) sendRMCMessage(connection *PRUDPConnection, message *RMCMessage, payload []byte) {
+	var packet PRUDPPacketInterface
+
+	switch connection.DefaultPRUDPVersion {
+	case 0:
+		packet, _ = NewPRUDPPacketV0(pep.Server, connection, nil)
+	case 1:
+		packet, _ = NewPRUDPPacketV1(pep.Server, connection, nil)
+	case 2:
+		packet, _ = NewPRUDPPacketLite(pep.Server, connection, nil)
+	}
+
+	packet.SetType(constants.DataPacket)
+	packet.AddFlag(constants.PacketFlagHasSize)
+	packet.AddFlag(constants.PacketFlagReliable)
+	packet.AddFlag(constants.PacketFlagNeedsAck)
+	packet.SetSourceVirtualPortStreamType(connection.StreamType)
+	packet.SetSourceVirtualPortStreamID(pep.StreamID)
+	packet.SetDestinationVirtualPortStreamType(connection.StreamType)
+	packet.SetDestinationVirtualPortStreamID(connection.StreamID)
+	packet.SetSubstreamID(0)
+	packet.SetPayload(payload)
+
+	if message != nil {
+		packet.SetRMCMessage(message)
+	}
+
+	pep.Server.send(packet)
+}
@@ -0,0 +1,121 @@
+package nex
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/PretendoNetwork/nex-go/v2/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRegistryConnection(server *PRUDPServer, endpoint *PRUDPEndPoint, pid uint64) *PRUDPConnection {
+	connection := NewPRUDPConnection(NewSocketConnection(server, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 60000}, nil))
+	connection.endpoint = endpoint
+	connection.ID = endpoint.ConnectionIDCounter.Next()
+	connection.DefaultPRUDPVersion = 1
+	connection.StreamSettings = endpoint.DefaultStreamSettings.Copy()
+	connection.ConnectionState = StateConnected
+	connection.SetPID(types.NewPID(pid))
+	connection.InitializeSlidingWindows(1)
+	connection.InitializePacketDispatchQueues(1)
+	endpoint.Connections.Set("test", connection)
+
+	return connection
+}
+
+// signallingClusterBackend reports when nodes subscribe and when they have handled a message
+type signallingClusterBackend struct {
+	*MemoryClusterBackend
+	subscribed chan string
+	handled    chan ClusterMessage
+}
+
+func (scb *signallingClusterBackend) Subscribe(ctx context.Context, nodeID string, handler func(message ClusterMessage)) error {
+	go func() {
+		// * Wait for the subscription to be in place before reporting it
+		for !scb.isSubscribed(nodeID) {
+			time.Sleep(time.Millisecond)
+		}
+
+		scb.subscribed <- nodeID
+	}()
+
+	return scb.MemoryClusterBackend.Subscribe(ctx, nodeID, func(message ClusterMessage) {
+		handler(message)
+		scb.handled <- message
+	})
+}
+
+func (scb *signallingClusterBackend) isSubscribed(nodeID string) bool {
+	scb.mutex.Lock()
+	defer scb.mutex.Unlock()
+
+	_, ok := scb.subscribers[nodeID]
+
+	return ok
+}
+
+func TestClusterConnectionRegistry(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	backend := &signallingClusterBackend{
+		MemoryClusterBackend: NewMemoryClusterBackend(),
+		subscribed:           make(chan string, 2),
+		handled:              make(chan ClusterMessage, 1),
+	}
+
+	serverA, endpointA := newTestSessionServer()
+	serverB, endpointB := newTestSessionServer()
+
+	registryA := NewClusterConnectionRegistry("a", serverA, backend)
+	registryB := NewClusterConnectionRegistry("b", serverB, backend)
+
+	connectionA := newTestRegistryConnection(serverA, endpointA, 1000)
+	connectionB := newTestRegistryConnection(serverB, endpointB, 2000)
+
+	go registryA.Start(ctx)
+	go registryB.Start(ctx)
+
+	<-backend.subscribed
+	<-backend.subscribed
+
+	location, err := registryA.Locate(ctx, types.NewPID(1000))
+	assert.NoError(t, err)
+	assert.Equal(t, ConnectionLocation{NodeID: "a", EndPointStreamID: 1, ConnectionID: connectionA.ID}, location)
+
+	location, err = registryA.Locate(ctx, types.NewPID(2000))
+	assert.NoError(t, err)
+	assert.Equal(t, ConnectionLocation{NodeID: "b", EndPointStreamID: 1, ConnectionID: connectionB.ID}, location)
+
+	_, err = registryA.Locate(ctx, types.NewPID(3000))
+	assert.ErrorIs(t, err, ErrConnectionNotFound)
+
+	// * Messages for PIDs on another node are sent to the client by that node
+	message := NewRMCRequest(endpointA)
+	message.ProtocolID = 0x0E
+	message.MethodID = 1
+	message.CallID = 1
+	message.Parameters = []byte{}
+
+	assert.NoError(t, registryA.Deliver(ctx, types.NewPID(2000), message))
+
+	handled := <-backend.handled
+	assert.Equal(t, "a", handled.FromNodeID)
+	assert.Equal(t, uint16(1), connectionB.SlidingWindow(0).sequenceIDCounter.Value)
+
+	assert.ErrorIs(t, registryA.Deliver(ctx, types.NewPID(3000), message), ErrConnectionNotFound)
+
+	// * A PID is only unregistered by the node which holds it
+	backend.Unregister(ctx, types.NewPID(2000), ConnectionLocation{NodeID: "a"})
+	_, ok, _ := backend.Lookup(ctx, types.NewPID(2000))
+	assert.True(t, ok)
+
+	connectionA.cleanup()
+	connectionB.cleanup()
+
+	_, ok, _ = backend.Lookup(ctx, types.NewPID(2000))
+	assert.False(t, ok)
+}
@@ -0,0 +1,132 @@
+package nex
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"time"
+)
+
+// ConnectionSignatureKeyRing rotates the key used for PRUDPv1 connection signatures.
+//
+// The server sends a connection signature, made from the clients address, in it's SYN response, which the client
+// signs it's CONNECT with. Keys are derived from Secret and the current rotation period, so every node in a cluster
+// configured with the same Secret uses the same key at the same time, and a CONNECT can be handled by a different
+// node than the SYN before it. The clocks of the nodes should be kept in sync, within GracePeriod of each other
+type ConnectionSignatureKeyRing struct {
+	Secret           []byte        // * Shared by every node in a cluster. Should be at least 16 random bytes
+	RotationInterval time.Duration // * How often the key changes. The key never changes if 0
+	GracePeriod      time.Duration // * How long after a rotation the previous key is still accepted, and how long before a rotation the next key is already accepted
+}
+
+// Key returns the key connection signatures are made with at the given time
+func (ckr *ConnectionSignatureKeyRing) Key(now time.Time) []byte {
+	return ckr.keyForPeriod(ckr.period(now))
+}
+
+// AcceptedKeys returns the keys which connection signatures are accepted from at the given time.
+// The current key is always first
+func (ckr *ConnectionSignatureKeyRing) AcceptedKeys(now time.Time) [][]byte {
+	period := ckr.period(now)
+	keys := [][]byte{ckr.keyForPeriod(period)}
+
+	if ckr.RotationInterval <= 0 {
+		return keys
+	}
+
+	periodStart := time.Unix(0, period*int64(ckr.RotationInterval))
+
+	// * Signatures made just before the rotation, on this node or another
+	if now.Sub(periodStart) < ckr.GracePeriod {
+		keys = append(keys, ckr.keyForPeriod(period-1))
+	}
+
+	// * Signatures made by a node whose clock is slightly ahead, which has already rotated
+	if periodStart.Add(ckr.RotationInterval).Sub(now) < ckr.GracePeriod {
+		keys = append(keys, ckr.keyForPeriod(period+1))
+	}
+
+	return keys
+}
+
+func (ckr *ConnectionSignatureKeyRing) period(now time.Time) int64 {
+	if ckr.RotationInterval <= 0 {
+		return 0
+	}
+
+	return now.UnixNano() / int64(ckr.RotationInterval)
+}
+
+func (ckr *ConnectionSignatureKeyRing) keyForPeriod(period int64) []byte {
+	periodBytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(periodBytes, uint64(period))
+
+	mac := hmac.New(sha256.New, ckr.Secret)
+	mac.Write([]byte("PRUDPv1 connection signature key"))
+	mac.Write(periodBytes)
+
+	return mac.Sum(nil)[:16]
+}
+
+// NewConnectionSignatureKeyRing returns a new ConnectionSignatureKeyRing which derives it's keys from the secret.
+// Nodes in a cluster should all use the same secret, rotation interval and grace period
+func NewConnectionSignatureKeyRing(secret []byte, rotationInterval, gracePeriod time.Duration) *ConnectionSignatureKeyRing {
+	return &ConnectionSignatureKeyRing{
+		Secret:           secret,
+		RotationInterval: rotationInterval,
+		GracePeriod:      gracePeriod,
+	}
+}
+
+// NewRandomConnectionSignatureKeyRing returns a new ConnectionSignatureKeyRing with a random secret, for servers which are not part of a cluster
+func NewRandomConnectionSignatureKeyRing(rotationInterval, gracePeriod time.Duration) *ConnectionSignatureKeyRing {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic(err)
+	}
+
+	return NewConnectionSignatureKeyRing(secret, rotationInterval, gracePeriod)
+}
+
+// connectionSignatureKey returns the key new connection signatures are made with
+func (ps *PRUDPServer) connectionSignatureKey() []byte {
+	if ps.ConnectionSignatureKeyRing != nil {
+		return ps.ConnectionSignatureKeyRing.Key(time.Now())
+	}
+
+	return ps.PRUDPv1ConnectionSignatureKey
+}
+
+// verifyConnectionSignature checks that a CONNECT was signed with a connection signature made with one of the accepted keys,
+// and returns that connection signature. Only PRUDPv1 packets can be checked, and only if LegacyConnectionSignature is off,
+// since legacy CONNECTs are not signed with the connection signature
+func (pep *PRUDPEndPoint) verifyConnectionSignature(packet PRUDPPacketInterface) ([]byte, bool) {
+	packetV1, ok := packet.(*PRUDPPacketV1)
+	keyRing := pep.Server.ConnectionSignatureKeyRing
+
+	if !ok || keyRing == nil || pep.Server.PRUDPV1Settings.LegacyConnectionSignature {
+		return nil, false
+	}
+
+	address := packet.Sender().(*PRUDPConnection).Socket.Address
+
+	defer func() {
+		packetV1.connectionSignatureKey = nil
+	}()
+
+	for _, key := range keyRing.AcceptedKeys(time.Now()) {
+		packetV1.connectionSignatureKey = key
+
+		connectionSignature, err := packetV1.calculateConnectionSignature(address)
+		if err != nil {
+			return nil, false
+		}
+
+		if hmac.Equal(packetV1.calculateSignature([]byte{}, connectionSignature), packetV1.signature) {
+			return connectionSignature, true
+		}
+	}
+
+	return nil, false
+}
@@ -0,0 +1,119 @@
+package nex
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/PretendoNetwork/nex-go/v2/constants"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnectionSignatureKeyRing(t *testing.T) {
+	keyRing := NewConnectionSignatureKeyRing([]byte("cluster secret"), time.Hour, time.Minute)
+	otherNode := NewConnectionSignatureKeyRing([]byte("cluster secret"), time.Hour, time.Minute)
+
+	rotation := time.Unix(0, 0).Add(1000 * time.Hour)
+
+	// * Nodes sharing a secret derive the same keys
+	assert.Equal(t, keyRing.Key(rotation), otherNode.Key(rotation))
+	assert.Len(t, keyRing.Key(rotation), 16)
+	assert.NotEqual(t, keyRing.Key(rotation), keyRing.Key(rotation.Add(-time.Second)))
+
+	previousKey := keyRing.Key(rotation.Add(-time.Second))
+
+	assert.Equal(t, [][]byte{keyRing.Key(rotation), previousKey}, keyRing.AcceptedKeys(rotation.Add(30*time.Second)))
+	assert.Equal(t, [][]byte{keyRing.Key(rotation)}, keyRing.AcceptedKeys(rotation.Add(2*time.Minute)))
+
+	// * The next key is accepted shortly before a rotation
+	assert.Contains(t, keyRing.AcceptedKeys(rotation.Add(-30*time.Second)), keyRing.Key(rotation))
+
+	assert.NotEqual(t, keyRing.Key(rotation), NewConnectionSignatureKeyRing([]byte("other secret"), time.Hour, time.Minute).Key(rotation))
+}
+
+func TestConnectionSignatureAcrossNodes(t *testing.T) {
+	address := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 60000}
+
+	newNode := func(secret string) (*PRUDPServer, *PRUDPEndPoint) {
+		server, endpoint := newTestSessionServer()
+		server.ConnectionSignatureKeyRing = NewConnectionSignatureKeyRing([]byte(secret), time.Hour, time.Minute)
+
+		return server, endpoint
+	}
+
+	// * Builds a CONNECT signed with the connection signature the server sent in it's SYN response
+	newConnect := func(server *PRUDPServer) *PRUDPPacketV1 {
+		connection := NewPRUDPConnection(NewSocketConnection(server, address, nil))
+
+		packet, _ := NewPRUDPPacketV1(server, connection, nil)
+		packet.SetType(constants.ConnectPacket)
+
+		connectionSignature, err := packet.calculateConnectionSignature(address)
+		assert.NoError(t, err)
+
+		packet.signature = packet.calculateSignature([]byte{}, connectionSignature)
+
+		return packet
+	}
+
+	synServer, _ := newNode("cluster secret")
+	connectServer, connectEndPoint := newNode("cluster secret")
+	_, otherEndPoint := newNode("other secret")
+
+	connectionSignature, ok := connectEndPoint.verifyConnectionSignature(newConnect(synServer))
+	assert.True(t, ok)
+	assert.Len(t, connectionSignature, 16)
+
+	_, ok = otherEndPoint.verifyConnectionSignature(newConnect(synServer))
+	assert.False(t, ok)
+
+	// * Legacy CONNECTs are not signed with the connection signature, so they can't be checked
+	connectServer.PRUDPV1Settings.LegacyConnectionSignature = true
+
+	_, ok = connectEndPoint.verifyConnectionSignature(newConnect(synServer))
+	assert.False(t, ok)
+}
+
+func TestHandleConnectWithRotatedKeys(t *testing.T) {
+	// * The rotation interval is picked so the current period started a second ago. The previous periods
+	// * key is then accepted for the rest of the grace period, and the key of the period before it has expired
+	now := time.Now()
+	rotationInterval := (time.Duration(now.UnixNano()) - time.Second) / 2
+	keyRing := NewConnectionSignatureKeyRing([]byte("cluster secret"), rotationInterval, time.Minute)
+
+	previousKey := keyRing.Key(now.Add(-rotationInterval))
+	expiredKey := keyRing.Key(now.Add(-2 * rotationInterval))
+
+	// * Makes the connection signature another node would have sent in it's SYN response while using the key
+	connectionSignature := func(client *testPRUDPClient, key []byte) []byte {
+		packet := client.newPacket(constants.SynPacket)
+		packet.connectionSignatureKey = key
+
+		connectionSignature, err := packet.calculateConnectionSignature(client.address)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+
+		return connectionSignature
+	}
+
+	server, endpoint := newTestPRUDPServer(t)
+	server.ConnectionSignatureKeyRing = keyRing
+
+	client := newTestPRUDPClient(t, endpoint)
+	client.send(client.newConnect(connectionSignature(client, previousKey)))
+	client.receive(constants.ConnectPacket)
+
+	if assert.NotNil(t, client.connection()) {
+		assert.Equal(t, StateConnected, client.connection().ConnectionState)
+	}
+
+	expired := newTestPRUDPClient(t, endpoint)
+	expired.send(expired.newConnect(connectionSignature(expired, expiredKey)))
+
+	assert.Empty(t, expired.receiveFor(200*time.Millisecond))
+
+	if connection := expired.connection(); connection != nil {
+		assert.Less(t, connection.ConnectionState, StateConnecting)
+	}
+}
@@ -7,6 +7,24 @@ package nex
 // unknown
 type ConnectionState uint8
 
+// String returns the name of the ConnectionState
+func (cs ConnectionState) String() string {
+	switch cs {
+	case StateNotConnected:
+		return "NotConnected"
+	case StateConnecting:
+		return "Connecting"
+	case StateConnected:
+		return "Connected"
+	case StateDisconnecting:
+		return "Disconnecting"
+	case StateFaulty:
+		return "Faulty"
+	default:
+		return "Unknown"
+	}
+}
+
 const (
 	// StateNotConnected indicates the client has not established a full PRUDP connection
 	StateNotConnected ConnectionState = iota
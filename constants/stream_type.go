@@ -14,6 +14,36 @@ func (st StreamType) EnumIndex() uint8 {
 	return uint8(st)
 }
 
+// String returns the name of the StreamType
+func (st StreamType) String() string {
+	switch st {
+	case StreamTypeDO:
+		return "DO"
+	case StreamTypeRV:
+		return "RV"
+	case StreamTypeOldRVSec:
+		return "OldRVSec"
+	case StreamTypeSBMGMT:
+		return "SBMGMT"
+	case StreamTypeNAT:
+		return "NAT"
+	case StreamTypeSessionDiscovery:
+		return "SessionDiscovery"
+	case StreamTypeNATEcho:
+		return "NATEcho"
+	case StreamTypeRouting:
+		return "Routing"
+	case StreamTypeGame:
+		return "Game"
+	case StreamTypeRVSecure:
+		return "RVSecure"
+	case StreamTypeRelay:
+		return "Relay"
+	default:
+		return "Unknown"
+	}
+}
+
 const (
 	// StreamTypeDO represents the DO PRUDP virtual connection stream type
 	StreamTypeDO StreamType = iota + 1
@@ -10,3 +10,16 @@ type Algorithm interface {
 	Decrypt(payload []byte) ([]byte, error)
 	Copy() Algorithm
 }
+
+// StreamState is the position of a stream cipher in it's key streams
+type StreamState struct {
+	Key             []byte `json:"key"`
+	CipheredCount   uint64 `json:"ciphered_count"`   // * Number of bytes encrypted with the key
+	DecipheredCount uint64 `json:"deciphered_count"` // * Number of bytes decrypted with the key
+}
+
+// Resumable is implemented by algorithms which keep state between payloads, so it can be saved and restored later
+type Resumable interface {
+	State() StreamState
+	Restore(state StreamState) error
+}
@@ -64,23 +64,42 @@ func (r *RC4) Decrypt(payload []byte) ([]byte, error) {
 func (r *RC4) Copy() Algorithm {
 	copied := NewRC4Encryption()
 
-	copied.SetKey(r.key)
+	copied.Restore(r.State())
+
+	return copied
+}
+
+// State returns the key and the position of the ciphers in their key streams
+func (r *RC4) State() StreamState {
+	return StreamState{
+		Key:             r.key,
+		CipheredCount:   r.cipheredCount,
+		DecipheredCount: r.decipheredCount,
+	}
+}
+
+// Restore sets the key and advances the ciphers to the positions in the state
+func (r *RC4) Restore(state StreamState) error {
+	err := r.SetKey(state.Key)
+	if err != nil {
+		return err
+	}
 
 	// * crypto/rc4 does not expose a way to directly copy streams and retain their state.
 	// * This just discards the number of iterations done in the original ciphers to sync
-	// * the copied ciphers states to the original
-	for i := 0; i < int(r.cipheredCount); i++ {
-		copied.cipher.XORKeyStream([]byte{0}, []byte{0})
+	// * the ciphers states to the original
+	for i := 0; i < int(state.CipheredCount); i++ {
+		r.cipher.XORKeyStream([]byte{0}, []byte{0})
 	}
 
-	for i := 0; i < int(r.decipheredCount); i++ {
-		copied.decipher.XORKeyStream([]byte{0}, []byte{0})
+	for i := 0; i < int(state.DecipheredCount); i++ {
+		r.decipher.XORKeyStream([]byte{0}, []byte{0})
 	}
 
-	copied.cipheredCount = r.cipheredCount
-	copied.decipheredCount = r.decipheredCount
+	r.cipheredCount = state.CipheredCount
+	r.decipheredCount = state.DecipheredCount
 
-	return copied
+	return nil
 }
 
 // NewRC4Encryption returns a new instance of the RC4 encryption
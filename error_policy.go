@@ -0,0 +1,89 @@
+package nex
+
+// ErrorAction is what a PRUDPEndPoint does with a connection after an error occurs while processing one of it's packets
+type ErrorAction int
+
+const (
+	// ErrorActionDrop drops the packet which caused the error. The connection is left open
+	ErrorActionDrop ErrorAction = iota
+
+	// ErrorActionNACK rejects the packet by sending an RMC error response with the errors result code,
+	// if the packet carried an RMC request with a known call ID. Otherwise the packet is dropped
+	ErrorActionNACK
+
+	// ErrorActionDisconnect disconnects the connection which sent the packet
+	ErrorActionDisconnect
+)
+
+// String returns a human readable name for the action
+func (ea ErrorAction) String() string {
+	switch ea {
+	case ErrorActionDrop:
+		return "drop"
+	case ErrorActionNACK:
+		return "nack"
+	case ErrorActionDisconnect:
+		return "disconnect"
+	default:
+		return "unknown"
+	}
+}
+
+// DefaultErrorPolicy is the ErrorPolicy used by endpoints which have not been given one.
+//
+// Connections which fail authentication are disconnected, requests which cannot be decoded
+// are rejected, and all other packets which cause errors are dropped
+func DefaultErrorPolicy(err *Error) ErrorAction {
+	switch err.ResultCode | uint32(errorMask) {
+	case ResultCodes.Transport.IncorrectRemoteAuthentication | uint32(errorMask):
+		return ErrorActionDisconnect
+	case ResultCodes.Core.InvalidArgument | uint32(errorMask):
+		return ErrorActionNACK
+	default:
+		return ErrorActionDrop
+	}
+}
+
+// handleError logs the error, emits it to the endpoints error handlers and then applies the endpoints ErrorPolicy
+func (pep *PRUDPEndPoint) handleError(resultCode uint32, packet PRUDPPacketInterface, err error) {
+	nexError := NewError(resultCode, err.Error())
+	nexError.Packet = packet
+
+	pep.Server.log().Error(nexError.Error(), packetLogFields(packet)...)
+	pep.EmitError(nexError)
+
+	policy := pep.ErrorPolicy
+	if policy == nil {
+		policy = DefaultErrorPolicy
+	}
+
+	connection, ok := packet.Sender().(*PRUDPConnection)
+	if !ok {
+		return
+	}
+
+	connection.responseTrace(packet).recordError(nexError)
+
+	switch policy(nexError) {
+	case ErrorActionNACK:
+		message := packet.RMCMessage()
+		if message == nil || !message.IsRequest {
+			return
+		}
+
+		response := NewRMCError(pep, resultCode)
+		response.ProtocolID = message.ProtocolID
+		response.MethodID = message.MethodID
+		response.CallID = message.CallID
+
+		pep.sendRMCResponse(connection, packet, response)
+	case ErrorActionDisconnect:
+		if nexError.ResultCode&^uint32(errorMask) == ResultCodes.Transport.IOError {
+			// * Sending a DISCONNECT after a failed write
+			// * would most likely fail the same way
+			pep.cleanupConnection(connection)
+		} else {
+			pep.Disconnect(connection)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package nex
+
+import (
+	"testing"
+	"time"
+
+	"github.com/PretendoNetwork/nex-go/v2/constants"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultErrorPolicy(t *testing.T) {
+	assert.Equal(t, ErrorActionDisconnect, DefaultErrorPolicy(NewError(ResultCodes.Transport.IncorrectRemoteAuthentication, "")))
+	assert.Equal(t, ErrorActionNACK, DefaultErrorPolicy(NewError(ResultCodes.Core.InvalidArgument, "")))
+	assert.Equal(t, ErrorActionDrop, DefaultErrorPolicy(NewError(ResultCodes.Transport.DecompressionFailure, "")))
+}
+
+// truncatedTestRequest is a packed RMC request for protocol 0xA with call ID 7, which ends before the method ID
+var truncatedTestRequest = []byte{5, 0, 0, 0, 0x8A, 7, 0, 0, 0}
+
+// newErrorPolicyTest connects a client to an endpoint using the policy, and returns the errors emitted by the endpoint
+func newErrorPolicyTest(t *testing.T, policy func(err *Error) ErrorAction) (*PRUDPEndPoint, *testPRUDPClient, chan *Error) {
+	_, endpoint := newTestPRUDPServer(t)
+	endpoint.ErrorPolicy = policy
+
+	errs := make(chan *Error, 10)
+	endpoint.OnError(func(err *Error) {
+		errs <- err
+	})
+
+	client := newTestPRUDPClient(t, endpoint)
+	client.connect()
+
+	return endpoint, client, errs
+}
+
+func TestErrorPolicyNACK(t *testing.T) {
+	_, client, errs := newErrorPolicyTest(t, nil)
+
+	client.sendData(truncatedTestRequest)
+
+	err := <-errs
+	assert.Equal(t, NewError(ResultCodes.Core.InvalidArgument, "").ResultCode, err.ResultCode)
+
+	// * The default policy rejects requests which can't be decoded, so the client is not left waiting
+	response := client.receiveRMC()
+	assert.False(t, response.IsSuccess)
+	assert.Equal(t, ResultCodes.Core.InvalidArgument, response.ErrorCode&^uint32(errorMask))
+	assert.Equal(t, uint16(0xA), response.ProtocolID)
+	assert.Equal(t, uint32(7), response.CallID)
+	assert.NotNil(t, client.connection())
+}
+
+func TestErrorPolicyDrop(t *testing.T) {
+	_, client, errs := newErrorPolicyTest(t, func(err *Error) ErrorAction {
+		return ErrorActionDrop
+	})
+
+	client.sendData(truncatedTestRequest)
+	<-errs
+
+	// * Only the acknowledgement of the request is sent
+	for _, packet := range client.receiveFor(100 * time.Millisecond) {
+		assert.True(t, packet.HasFlag(constants.PacketFlagAck), "Unexpected packet type %d", packet.Type())
+	}
+
+	assert.NotNil(t, client.connection())
+}
+
+func TestErrorPolicyDisconnect(t *testing.T) {
+	_, client, errs := newErrorPolicyTest(t, func(err *Error) ErrorAction {
+		return ErrorActionDisconnect
+	})
+
+	connection := client.connection()
+	client.sendData(truncatedTestRequest)
+	<-errs
+
+	client.receive(constants.DisconnectPacket)
+	assert.Nil(t, client.connection())
+	assert.Equal(t, StateNotConnected, connection.ConnectionState)
+}
+
+func TestUnhandledPacketType(t *testing.T) {
+	_, client, errs := newErrorPolicyTest(t, nil)
+
+	// * Packet types only go up to PING
+	client.send(client.newPacket(constants.PingPacket + 3))
+
+	err := <-errs
+	assert.Equal(t, NewError(ResultCodes.Core.NotImplemented, "").ResultCode, err.ResultCode)
+	assert.Contains(t, err.Message, "Unhandled packet type 7")
+
+	// * and the default policy drops them
+	assert.Empty(t, client.receiveFor(100*time.Millisecond))
+	assert.NotNil(t, client.connection())
+}
@@ -0,0 +1,168 @@
+package nex
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// GameProfile is a named preset of the settings which differ between titles, such as the NEX version,
+// PRUDPv0 Quazal mode, fragment size, compression and ByteStreamSettings.
+//
+// Profiles are applied with GameProfile.Apply, or by naming them in the profile field of a PRUDPServerConfig.
+// The built in profiles cover common platforms. Titles which differ from them can register their own with RegisterGameProfile
+type GameProfile struct {
+	Name        string
+	Description string
+	Settings    *PRUDPServerConfig // * Settings applied by the profile. Profiles can not contain endpoints or other profiles
+}
+
+var gameProfiles = NewMutexMap[string, *GameProfile]()
+
+// Apply configures the server with the profile. The stream settings of the profile replace the
+// DefaultStreamSettings of every PRUDPEndPoint bound to the server, so endpoints should be bound first
+func (gp *GameProfile) Apply(server *PRUDPServer) error {
+	problems := &ConfigError{}
+	defaultStreamSettings := NewStreamSettings()
+
+	gp.Settings.applyServer(server, defaultStreamSettings, problems)
+
+	if err := problems.err(); err != nil {
+		return err
+	}
+
+	server.Endpoints.Each(func(_ uint8, endpoint *PRUDPEndPoint) bool {
+		endpoint.DefaultStreamSettings = defaultStreamSettings.Copy()
+		return false
+	})
+
+	return nil
+}
+
+// RegisterGameProfile registers a profile so it can be found by name. Registering a profile
+// with the same name as an existing one replaces it, which can be used to change the built in profiles
+func RegisterGameProfile(profile *GameProfile) error {
+	if profile.Name == "" {
+		return errors.New("Game profile has no name")
+	}
+
+	if profile.Settings == nil {
+		return fmt.Errorf("Game profile %q has no settings", profile.Name)
+	}
+
+	if profile.Settings.Profile != "" || len(profile.Settings.EndPoints) != 0 {
+		return fmt.Errorf("Game profile %q can not contain endpoints or other profiles", profile.Name)
+	}
+
+	problems := &ConfigError{}
+	profile.Settings.applyServer(NewPRUDPServer(), NewStreamSettings(), problems)
+
+	if err := problems.err(); err != nil {
+		return fmt.Errorf("Game profile %q is invalid. %w", profile.Name, err)
+	}
+
+	gameProfiles.Set(profile.Name, profile)
+
+	return nil
+}
+
+// GameProfileByName returns the registered profile with the given name
+func GameProfileByName(name string) (*GameProfile, bool) {
+	return gameProfiles.Get(name)
+}
+
+// GameProfiles returns the names of every registered profile, sorted
+func GameProfiles() []string {
+	names := make([]string, 0, gameProfiles.Size())
+
+	gameProfiles.Each(func(name string, _ *GameProfile) bool {
+		names = append(names, name)
+		return false
+	})
+
+	slices.Sort(names)
+
+	return names
+}
+
+func registerBuiltinGameProfiles() {
+	intPointer := func(value int) *int { return &value }
+	boolPointer := func(value bool) *bool { return &value }
+
+	// * Every profile sets the PRUDPv0 and PRUDPv1 options, so applying
+	// * one never keeps options left over from another title
+	prudpV0 := func(quazalMode, encryptedConnect, enhancedChecksum bool) *PRUDPV0Config {
+		return &PRUDPV0Config{
+			IsQuazalMode:              boolPointer(quazalMode),
+			EncryptedConnect:          boolPointer(encryptedConnect),
+			LegacyConnectionSignature: boolPointer(false),
+			UseEnhancedChecksum:       boolPointer(enhancedChecksum),
+		}
+	}
+
+	prudpV1 := &PRUDPV1Config{LegacyConnectionSignature: boolPointer(false)}
+
+	profiles := []*GameProfile{
+		{
+			Name:        "nex-3ds-v3",
+			Description: "3DS titles using NEX 3 over PRUDPv0",
+			Settings: &PRUDPServerConfig{
+				KerberosTicketVersion: intPointer(0),
+				SessionKeyLength:      intPointer(32),
+				FragmentSize:          intPointer(1264), // * NEX 3 raised the MTU to 1364, which leaves 1264 bytes for PRUDPv0 payloads. See SetFragmentSize
+				LibraryVersions:       &LibraryVersionsConfig{Default: "3.0.0"},
+				ByteStreamSettings:    &ByteStreamConfig{StringLengthSize: intPointer(2), PIDSize: intPointer(4)},
+				PRUDPV0Settings:       prudpV0(false, false, false), // * NEX uses a 1 byte checksum and plaintext CONNECT payloads
+				PRUDPV1Settings:       prudpV1,
+				StreamSettings:        &StreamSettingsConfig{CompressionAlgorithm: "zlib"},
+			},
+		},
+		{
+			Name:        "nex-wiiu-v4",
+			Description: "Wii U titles using NEX 4 over PRUDPv1",
+			Settings: &PRUDPServerConfig{
+				KerberosTicketVersion: intPointer(1),
+				SessionKeyLength:      intPointer(32),
+				FragmentSize:          intPointer(1300),
+				LibraryVersions:       &LibraryVersionsConfig{Default: "4.0.0"},
+				ByteStreamSettings:    &ByteStreamConfig{StringLengthSize: intPointer(2), PIDSize: intPointer(4)},
+				PRUDPV0Settings:       prudpV0(false, false, false),
+				PRUDPV1Settings:       prudpV1,
+				StreamSettings:        &StreamSettingsConfig{CompressionAlgorithm: "zlib"},
+			},
+		},
+		{
+			Name:        "quazal-ubisoft",
+			Description: "Ubisoft titles using Quazal Rendez-Vous over PRUDPv0",
+			Settings: &PRUDPServerConfig{
+				KerberosTicketVersion: intPointer(0),
+				SessionKeyLength:      intPointer(16),
+				FragmentSize:          intPointer(962), // * Quazal hardcodes the MTU to 1000
+				ByteStreamSettings:    &ByteStreamConfig{StringLengthSize: intPointer(4), PIDSize: intPointer(4)},
+				PRUDPV0Settings:       prudpV0(true, true, false), // * CONNECT payloads are encrypted with the stream. Titles using the 4 byte checksum need their own profile
+				PRUDPV1Settings:       prudpV1,
+				StreamSettings:        &StreamSettingsConfig{EncryptionAlgorithm: "quazal_rc4", CompressionAlgorithm: "lzo"},
+			},
+		},
+		{
+			Name:        "switch-prudplite",
+			Description: "Switch titles using NEX 4 over PRUDPLite",
+			Settings: &PRUDPServerConfig{
+				KerberosTicketVersion: intPointer(1),
+				SessionKeyLength:      intPointer(32),
+				FragmentSize:          intPointer(1300),
+				LibraryVersions:       &LibraryVersionsConfig{Default: "4.0.0"},
+				ByteStreamSettings:    &ByteStreamConfig{StringLengthSize: intPointer(2), PIDSize: intPointer(8)},
+				PRUDPV0Settings:       prudpV0(false, false, false),
+				PRUDPV1Settings:       prudpV1,
+				StreamSettings:        &StreamSettingsConfig{CompressionAlgorithm: "none"},
+			},
+		},
+	}
+
+	for _, profile := range profiles {
+		if err := RegisterGameProfile(profile); err != nil {
+			panic(err)
+		}
+	}
+}
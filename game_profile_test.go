@@ -0,0 +1,76 @@
+package nex
+
+import (
+	"testing"
+
+	"github.com/PretendoNetwork/nex-go/v2/compression"
+	"github.com/PretendoNetwork/nex-go/v2/encryption"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGameProfileApply(t *testing.T) {
+	server := NewPRUDPServer()
+	endpoint := NewPRUDPEndPoint(1)
+	server.BindPRUDPEndPoint(endpoint)
+
+	profile, ok := GameProfileByName("quazal-ubisoft")
+	if !assert.True(t, ok) {
+		return
+	}
+
+	assert.NoError(t, profile.Apply(server))
+	assert.True(t, server.PRUDPV0Settings.IsQuazalMode)
+	assert.True(t, server.PRUDPV0Settings.EncryptedConnect)
+	assert.Equal(t, 962, server.FragmentSize)
+	assert.Equal(t, 4, server.ByteStreamSettings.StringLengthSize)
+	assert.IsType(t, &encryption.QuazalRC4{}, endpoint.DefaultStreamSettings.EncryptionAlgorithm)
+	assert.IsType(t, &compression.LZO{}, endpoint.DefaultStreamSettings.CompressionAlgorithm)
+
+	// * Applying another profile replaces every title specific option of the previous one
+	profile, _ = GameProfileByName("nex-3ds-v3")
+
+	assert.NoError(t, profile.Apply(server))
+	assert.False(t, server.PRUDPV0Settings.IsQuazalMode)
+	assert.False(t, server.PRUDPV0Settings.EncryptedConnect)
+	assert.False(t, server.PRUDPV0Settings.UseEnhancedChecksum)
+	assert.Equal(t, 1264, server.FragmentSize)
+	assert.Equal(t, 2, server.ByteStreamSettings.StringLengthSize)
+}
+
+func TestGameProfileRegistration(t *testing.T) {
+	pidSize := 8
+
+	err := RegisterGameProfile(&GameProfile{
+		Name:     "test-title",
+		Settings: &PRUDPServerConfig{Profile: "nex-wiiu-v4", AccessKey: "12345678"},
+	})
+	assert.Error(t, err)
+
+	err = RegisterGameProfile(&GameProfile{
+		Name:     "test-title",
+		Settings: &PRUDPServerConfig{AccessKey: "12345678", ByteStreamSettings: &ByteStreamConfig{PIDSize: &pidSize}},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Contains(t, GameProfiles(), "test-title")
+
+	// * Profiles named in a config are applied before the rest of the config
+	config, err := ParseServerConfig([]byte(`{"prudp": {"profile": "test-title", "fragment_size": 1000, "endpoints": [{"stream_id": 1}]}}`), ConfigFormatJSON)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	server, err := config.NewPRUDPServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, "12345678", server.AccessKey)
+	assert.Equal(t, 8, server.ByteStreamSettings.PIDSize)
+	assert.Equal(t, 1000, server.FragmentSize)
+
+	_, err = ParseServerConfig([]byte(`{"prudp": {"profile": "missing"}}`), ConfigFormatJSON)
+	assert.ErrorContains(t, err, `prudp.profile: unknown game profile "missing"`)
+}
@@ -0,0 +1,150 @@
+package nex
+
+import (
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+)
+
+// HandlerPool runs packet handlers on a fixed number of worker goroutines.
+//
+// Handlers submitted to the pool are fire-and-forget from the point of view of the goroutine which received
+// the packet, so a slow handler does not hold up the processing of other packets. When every worker is busy
+// and the queue is full, Submit blocks until there is room, applying backpressure to the packet source
+type HandlerPool struct {
+	jobs     chan func()
+	quit     chan struct{}
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+}
+
+// Submit queues a job to be run by the next free worker. Jobs submitted after Stop are dropped
+func (hp *HandlerPool) Submit(job func()) {
+	hp.submit(job)
+}
+
+// submit queues a job like Submit. Returns false if the job was dropped
+func (hp *HandlerPool) submit(job func()) bool {
+	// * Checked first, since a select with room in the queue
+	// * would otherwise pick between the cases at random
+	select {
+	case <-hp.quit:
+		return false
+	default:
+	}
+
+	select {
+	case hp.jobs <- job:
+		return true
+	case <-hp.quit:
+		return false
+	}
+}
+
+// Stop stops the workers once they finish their current jobs. Queued jobs which have not started are dropped
+func (hp *HandlerPool) Stop() {
+	hp.stopOnce.Do(func() {
+		close(hp.quit)
+	})
+
+	hp.wg.Wait()
+}
+
+// dispatch runs every handler with the packet on the pool.
+// If the pool is nil the handlers are run in order on the calling goroutine.
+//
+// If recovered is not nil, panics in the handlers are recovered and passed to it along with the stack trace.
+// The returned channel is closed once every handler has returned
+func (hp *HandlerPool) dispatch(handlers []func(packet PacketInterface), packet PacketInterface, recovered func(value any, stack []byte)) <-chan struct{} {
+	returned := make(chan struct{})
+
+	if hp == nil {
+		for _, handler := range handlers {
+			runHandler(handler, packet, recovered)
+		}
+
+		close(returned)
+
+		return returned
+	}
+
+	if len(handlers) == 0 {
+		close(returned)
+
+		return returned
+	}
+
+	// * The last handler to return closes the channel. Handlers
+	// * submitted after Stop count as returned. Ones which were
+	// * already queued are dropped without ever returning
+	var remaining atomic.Int32
+	remaining.Store(int32(len(handlers)))
+
+	done := func() {
+		if remaining.Add(-1) == 0 {
+			close(returned)
+		}
+	}
+
+	for _, handler := range handlers {
+		submitted := hp.submit(func() {
+			defer done()
+			runHandler(handler, packet, recovered)
+		})
+
+		if !submitted {
+			done()
+		}
+	}
+
+	return returned
+}
+
+func runHandler(handler func(packet PacketInterface), packet PacketInterface, recovered func(value any, stack []byte)) {
+	if recovered != nil {
+		defer func() {
+			if value := recover(); value != nil {
+				recovered(value, debug.Stack())
+			}
+		}()
+	}
+
+	handler(packet)
+}
+
+func (hp *HandlerPool) work() {
+	defer hp.wg.Done()
+
+	for {
+		select {
+		case job := <-hp.jobs:
+			job()
+		case <-hp.quit:
+			return
+		}
+	}
+}
+
+// NewHandlerPool starts a new HandlerPool with the given number of workers and queue size.
+// At least one worker is always started
+func NewHandlerPool(workers, queueSize int) *HandlerPool {
+	if workers < 1 {
+		workers = 1
+	}
+
+	if queueSize < 0 {
+		queueSize = 0
+	}
+
+	hp := &HandlerPool{
+		jobs: make(chan func(), queueSize),
+		quit: make(chan struct{}),
+	}
+
+	hp.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go hp.work()
+	}
+
+	return hp
+}
@@ -0,0 +1,121 @@
+package nex
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandlerPoolRunsAllHandlers(t *testing.T) {
+	pool := NewHandlerPool(4, 8)
+	defer pool.Stop()
+
+	var wg sync.WaitGroup
+	var calls atomic.Int32
+
+	handler := func(packet PacketInterface) {
+		calls.Add(1)
+		wg.Done()
+	}
+
+	wg.Add(30)
+	for i := 0; i < 10; i++ {
+		pool.dispatch([]func(packet PacketInterface){handler, handler, handler}, nil, nil)
+	}
+
+	wg.Wait()
+	assert.Equal(t, int32(30), calls.Load())
+}
+
+func TestHandlerPoolDispatchSignalsReturn(t *testing.T) {
+	pool := NewHandlerPool(2, 2)
+
+	release := make(chan struct{})
+	handler := func(packet PacketInterface) {
+		<-release
+	}
+
+	returned := pool.dispatch([]func(packet PacketInterface){handler, handler}, nil, nil)
+
+	select {
+	case <-returned:
+		t.Fatal("Signalled before the handlers returned")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+
+	select {
+	case <-returned:
+	case <-time.After(time.Second):
+		t.Fatal("Not signalled after the handlers returned")
+	}
+
+	// * Handlers dropped by a stopped pool never run, but still count as returned
+	pool.Stop()
+
+	select {
+	case <-pool.dispatch([]func(packet PacketInterface){handler}, nil, nil):
+	case <-time.After(time.Second):
+		t.Fatal("Not signalled after the handlers were dropped")
+	}
+
+	// * Without a pool the handlers have returned before dispatch does
+	var nilPool *HandlerPool
+
+	select {
+	case <-nilPool.dispatch([]func(packet PacketInterface){handler}, nil, nil):
+	default:
+		t.Fatal("Not signalled after running the handlers inline")
+	}
+}
+
+func TestPRUDPHandlerPanicIsRecovered(t *testing.T) {
+	_, endpoint := newTestPRUDPServer(t)
+	endpoint.HandlerPool = NewHandlerPool(1, 1)
+	defer endpoint.HandlerPool.Stop()
+
+	endpoint.OnData(func(packet PacketInterface) {
+		panic("handler failed")
+	})
+
+	client := newTestPRUDPClient(t, endpoint)
+	client.connect()
+
+	request := NewRMCRequest(endpoint)
+	request.ProtocolID = 0xA
+	request.MethodID = 1
+	request.CallID = 7
+	client.sendRequest(request)
+
+	response := client.receiveRMC()
+	assert.False(t, response.IsSuccess)
+	assert.Equal(t, NewError(ResultCodes.Core.Exception, "").ResultCode, response.ErrorCode)
+	assert.Equal(t, uint16(0xA), response.ProtocolID)
+	assert.Equal(t, uint32(7), response.CallID)
+
+	// * The worker survives the panic, so later requests are still handled
+	request.CallID = 8
+	client.sendRequest(request)
+	assert.Equal(t, uint32(8), client.receiveRMC().CallID)
+}
+
+func TestLateResponseAfterTimeoutIsDropped(t *testing.T) {
+	connection := NewPRUDPConnection(nil)
+
+	call := &rmcCall{cancel: func() {}}
+	connection.calls.Set(7, call)
+
+	response := NewRMCSuccess(nil, nil)
+	response.CallID = 7
+
+	// * The timeout response claims the call first
+	assert.True(t, call.claim())
+	assert.False(t, connection.completeCall(response))
+
+	// * Calls which were never registered are always sent
+	assert.True(t, connection.completeCall(response))
+}
@@ -0,0 +1,257 @@
+package nex
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+
+	"github.com/PretendoNetwork/nex-go/v2/types"
+)
+
+// HPPHTTPClient calls the RMC methods of a HPP server over HTTP, the same way a console does.
+//
+// Unlike HPPClient, which represents a remote console on the server side, HPPHTTPClient is the caller.
+// It signs each request with the access key (signature1) and the accounts Kerberos key (signature2),
+// so it can be used for end-to-end tests and tools against HPP services
+type HPPHTTPClient struct {
+	URL                string       // * Full URL requests are POSTed to, such as "https://example.com/hpp/"
+	PID                types.PID    // * PID of the account making the requests
+	Password           string       // * Password of the account, used to derive the Kerberos key for signature2
+	Token              string       // * Sent in the token header
+	HTTPClient         *http.Client // * Client used to send requests. http.DefaultClient is used if nil
+	accessKey          string
+	libraryVersions    *LibraryVersions
+	byteStreamSettings *ByteStreamSettings
+	useVerboseRMC      bool
+	errorEventHandlers []func(err *Error)
+	callIDCounter      *Counter[uint32]
+}
+
+// NewRequest returns a new RMC request for the given method, with the next call ID
+func (c *HPPHTTPClient) NewRequest(protocolID uint16, methodID uint32, parameters []byte) *RMCMessage {
+	request := NewRMCRequest(c)
+	request.ProtocolID = protocolID
+	request.MethodID = methodID
+	request.CallID = c.callIDCounter.Next()
+	request.Parameters = parameters
+	request.IsHPP = true
+
+	return request
+}
+
+// Call sends an RMC request to the server and returns it's response.
+//
+// If the server responds with an RMC error, the response is returned along with an *Error holding it's result code
+func (c *HPPHTTPClient) Call(ctx context.Context, request *RMCMessage) (*RMCMessage, error) {
+	payload := request.Bytes()
+
+	accessKey, err := hex.DecodeString(c.accessKey)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode access key. %s", err)
+	}
+
+	accessKeySignature, err := calculateHPPSignature(payload, accessKey)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to calculate access key signature. %s", err)
+	}
+
+	passwordSignature, err := calculateHPPSignature(payload, DeriveKerberosKey(c.PID, []byte(c.Password)))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to calculate password signature. %s", err)
+	}
+
+	body := new(bytes.Buffer)
+	form := multipart.NewWriter(body)
+
+	err = form.WriteField("file", string(payload))
+	if err != nil {
+		return nil, err
+	}
+
+	err = form.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("pid", strconv.FormatUint(uint64(c.PID), 10))
+	req.Header.Set("token", c.Token)
+	req.Header.Set("signature1", hex.EncodeToString(accessKeySignature))
+	req.Header.Set("signature2", hex.EncodeToString(passwordSignature))
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HPP server responded with %s", res.Status)
+	}
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read HPP response. %s", err)
+	}
+
+	response, err := c.decodeResponse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	response.ProtocolID = request.ProtocolID
+
+	if response.CallID != request.CallID {
+		return nil, fmt.Errorf("HPP response has call ID %d, expected %d", response.CallID, request.CallID)
+	}
+
+	if !response.IsSuccess {
+		return response, NewError(response.ErrorCode, fmt.Sprintf("RMC call to protocol %d method %d failed", request.ProtocolID, request.MethodID))
+	}
+
+	return response, nil
+}
+
+// decodeResponse decodes a HPP RMC response. Unlike PRUDP, HPP responses do not include the protocol ID
+func (c *HPPHTTPClient) decodeResponse(data []byte) (*RMCMessage, error) {
+	response := NewRMCMessage(c)
+	response.IsHPP = true
+
+	stream := NewByteStreamIn(data, c.libraryVersions, c.byteStreamSettings)
+
+	length, err := stream.ReadUInt32LE()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read HPP response size. %s", err)
+	}
+
+	if stream.Remaining() != uint64(length) {
+		return nil, fmt.Errorf("HPP response has unexpected size")
+	}
+
+	response.IsSuccess, err = stream.ReadBool()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read HPP response error check. %s", err)
+	}
+
+	if response.IsSuccess {
+		response.CallID, err = stream.ReadUInt32LE()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read HPP response call ID. %s", err)
+		}
+
+		response.MethodID, err = stream.ReadUInt32LE()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read HPP response method ID. %s", err)
+		}
+
+		response.MethodID = response.MethodID & ^uint32(0x8000)
+		response.Parameters = stream.ReadRemaining()
+	} else {
+		response.ErrorCode, err = stream.ReadUInt32LE()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read HPP response error code. %s", err)
+		}
+
+		response.CallID, err = stream.ReadUInt32LE()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read HPP response call ID. %s", err)
+		}
+	}
+
+	return response, nil
+}
+
+// AccessKey returns the access key used to sign requests
+func (c *HPPHTTPClient) AccessKey() string {
+	return c.accessKey
+}
+
+// SetAccessKey sets the access key used to sign requests
+func (c *HPPHTTPClient) SetAccessKey(accessKey string) {
+	c.accessKey = accessKey
+}
+
+// Send calls the request in the packets RMC message without waiting for the response.
+// Failures are emitted to the error handlers. Implemented to conform to the EndpointInterface
+func (c *HPPHTTPClient) Send(packet PacketInterface) {
+	go func() {
+		_, err := c.Call(PacketContext(packet), packet.RMCMessage())
+		if err == nil {
+			return
+		}
+
+		if nexError, ok := err.(*Error); ok {
+			c.EmitError(nexError)
+		} else {
+			c.EmitError(NewError(ResultCodes.Transport.Unknown, err.Error()))
+		}
+	}()
+}
+
+// LibraryVersions returns the versions that the client uses
+func (c *HPPHTTPClient) LibraryVersions() *LibraryVersions {
+	return c.libraryVersions
+}
+
+// ByteStreamSettings returns the settings to be used for ByteStreams
+func (c *HPPHTTPClient) ByteStreamSettings() *ByteStreamSettings {
+	return c.byteStreamSettings
+}
+
+// SetByteStreamSettings sets the settings to be used for ByteStreams
+func (c *HPPHTTPClient) SetByteStreamSettings(byteStreamSettings *ByteStreamSettings) {
+	c.byteStreamSettings = byteStreamSettings
+}
+
+// UseVerboseRMC checks whether or not the client uses verbose RMC
+func (c *HPPHTTPClient) UseVerboseRMC() bool {
+	return c.useVerboseRMC
+}
+
+// EnableVerboseRMC enable or disables the use of verbose RMC
+func (c *HPPHTTPClient) EnableVerboseRMC(enable bool) {
+	c.useVerboseRMC = enable
+}
+
+// OnError adds an event handler which is fired when a request sent with Send fails
+func (c *HPPHTTPClient) OnError(handler func(err *Error)) {
+	c.errorEventHandlers = append(c.errorEventHandlers, handler)
+}
+
+// EmitError calls all the clients error event handlers with the provided error
+func (c *HPPHTTPClient) EmitError(err *Error) {
+	for _, handler := range c.errorEventHandlers {
+		go handler(err)
+	}
+}
+
+// NewHPPHTTPClient returns a new HPPHTTPClient which calls the HPP server at the given URL as the given account
+func NewHPPHTTPClient(url, accessKey string, pid types.PID, password, token string) *HPPHTTPClient {
+	return &HPPHTTPClient{
+		URL:                url,
+		PID:                pid,
+		Password:           password,
+		Token:              token,
+		accessKey:          accessKey,
+		libraryVersions:    NewLibraryVersions(),
+		byteStreamSettings: NewByteStreamSettings(),
+		errorEventHandlers: make([]func(err *Error), 0),
+		callIDCounter:      NewCounter[uint32](0),
+	}
+}
@@ -0,0 +1,52 @@
+package nex
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PretendoNetwork/nex-go/v2/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHPPHTTPClientCall(t *testing.T) {
+	server := newTestHPPServer()
+	server.OnData(func(packet PacketInterface) {
+		request := packet.RMCMessage()
+
+		var response *RMCMessage
+		if request.MethodID == 1 {
+			response = NewRMCSuccess(server, append([]byte("echo:"), request.Parameters...))
+		} else {
+			response = NewRMCError(server, ResultCodes.Core.InvalidArgument)
+		}
+
+		response.ProtocolID = request.ProtocolID
+		response.MethodID = request.MethodID
+		response.CallID = request.CallID
+
+		packet.SetRMCMessage(response)
+		server.Send(packet)
+	})
+
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	client := NewHPPHTTPClient(httpServer.URL+"/hpp/", server.AccessKey(), types.NewPID(1000), "password", "token")
+
+	response, err := client.Call(context.Background(), client.NewRequest(0x70, 1, []byte("hello")))
+	assert.NoError(t, err)
+	assert.True(t, response.IsSuccess)
+	assert.Equal(t, uint32(1), response.MethodID)
+	assert.Equal(t, []byte("echo:hello"), response.Parameters)
+
+	response, err = client.Call(context.Background(), client.NewRequest(0x70, 2, nil))
+	assert.Error(t, err)
+	assert.False(t, response.IsSuccess)
+	assert.Equal(t, NewError(ResultCodes.Core.InvalidArgument, "").ResultCode, err.(*Error).ResultCode)
+
+	client.Password = "wrong"
+	response, err = client.Call(context.Background(), client.NewRequest(0x70, 1, nil))
+	assert.Error(t, err)
+	assert.Equal(t, NewError(ResultCodes.PythonCore.ValidationError, "").ResultCode, response.ErrorCode)
+}
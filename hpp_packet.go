@@ -2,6 +2,7 @@ package nex
 
 import (
 	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/md5"
 	"encoding/hex"
@@ -17,6 +18,8 @@ type HPPPacket struct {
 	payload            []byte
 	message            *RMCMessage
 	processed          chan bool
+	trace              *rmcTrace
+	ctx                context.Context
 }
 
 // Sender returns the Client who sent the packet
@@ -62,7 +65,7 @@ func (p *HPPPacket) calculateAccessKeySignature() ([]byte, error) {
 		return nil, err
 	}
 
-	signature, err := p.calculateSignature(p.payload, accessKeyBytes)
+	signature, err := calculateHPPSignature(p.payload, accessKeyBytes)
 	if err != nil {
 		return nil, err
 	}
@@ -70,7 +73,7 @@ func (p *HPPPacket) calculateAccessKeySignature() ([]byte, error) {
 	return signature, nil
 }
 
-func (p *HPPPacket) validatePasswordSignature(signature string) error {
+func (p *HPPPacket) validatePasswordSignature(ctx context.Context, signature string) error {
 	signatureBytes, err := hex.DecodeString(signature)
 	if err != nil {
 		return fmt.Errorf("Failed to decode password signature. %s", err)
@@ -78,7 +81,7 @@ func (p *HPPPacket) validatePasswordSignature(signature string) error {
 
 	p.passwordSignature = signatureBytes
 
-	calculatedSignature, err := p.calculatePasswordSignature()
+	calculatedSignature, err := p.calculatePasswordSignature(ctx)
 	if err != nil {
 		return fmt.Errorf("Failed to calculate password signature. %s", err)
 	}
@@ -90,17 +93,14 @@ func (p *HPPPacket) validatePasswordSignature(signature string) error {
 	return nil
 }
 
-func (p *HPPPacket) calculatePasswordSignature() ([]byte, error) {
+func (p *HPPPacket) calculatePasswordSignature(ctx context.Context) ([]byte, error) {
 	sender := p.Sender()
-	pid := sender.PID()
-	account, _ := sender.Endpoint().(*HPPServer).AccountDetailsByPID(pid)
-	if account == nil {
-		return nil, errors.New("PID does not exist")
+	key, keyError := kerberosKey(ctx, sender.Endpoint().(*HPPServer).accountStore(), sender.PID(), nil)
+	if keyError != nil {
+		return nil, errors.New(keyError.Message)
 	}
 
-	key := DeriveKerberosKey(pid, []byte(account.Password))
-
-	signature, err := p.calculateSignature(p.payload, key)
+	signature, err := calculateHPPSignature(p.payload, key)
 	if err != nil {
 		return nil, err
 	}
@@ -108,7 +108,8 @@ func (p *HPPPacket) calculatePasswordSignature() ([]byte, error) {
 	return signature, nil
 }
 
-func (p *HPPPacket) calculateSignature(buffer []byte, key []byte) ([]byte, error) {
+// calculateHPPSignature calculates the signature of a HPP request payload, as sent in the signature1 and signature2 headers
+func calculateHPPSignature(buffer []byte, key []byte) ([]byte, error) {
 	mac := hmac.New(md5.New, key)
 
 	_, err := mac.Write(buffer)
@@ -131,12 +132,22 @@ func (p *HPPPacket) SetRMCMessage(message *RMCMessage) {
 	p.message = message
 }
 
+// Context returns the context of the request the packet carries. It is cancelled when the client
+// disconnects, or once the request times out or is responded to
+func (p *HPPPacket) Context() context.Context {
+	if p.ctx == nil {
+		return context.Background()
+	}
+
+	return p.ctx
+}
+
 // NewHPPPacket creates and returns a new HPPPacket using the provided Client and payload
 func NewHPPPacket(client *HPPClient, payload []byte) (*HPPPacket, error) {
 	hppPacket := &HPPPacket{
 		sender:    client,
 		payload:   payload,
-		processed: make(chan bool),
+		processed: make(chan bool, 1),
 	}
 
 	if payload != nil {
@@ -0,0 +1,117 @@
+package nex
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+)
+
+// DefaultHPPMaxRequestSize is the largest HPP request body accepted by default
+const DefaultHPPMaxRequestSize = 1 << 20
+
+// hppRequestError is an error reading a HPP request body, with the HTTP status to respond with
+type hppRequestError struct {
+	status int
+	err    error
+}
+
+func (e *hppRequestError) Error() string {
+	return e.err.Error()
+}
+
+// readHPPRequestPayload reads the RMC request from a HPP request body.
+//
+// Most titles upload the request as the "file" field of a multipart form, but some send it as the raw
+// request body instead. URL encoded forms are accepted for the same field
+func readHPPRequestPayload(w http.ResponseWriter, req *http.Request, maxSize int64) ([]byte, error) {
+	if maxSize > 0 {
+		req.Body = http.MaxBytesReader(w, req.Body, maxSize)
+	}
+
+	mediaType := ""
+	if contentType := req.Header.Get("Content-Type"); contentType != "" {
+		var err error
+		mediaType, _, err = mime.ParseMediaType(contentType)
+		if err != nil {
+			return nil, &hppRequestError{http.StatusBadRequest, fmt.Errorf("Invalid Content-Type. %s", err)}
+		}
+	}
+
+	switch mediaType {
+	case "multipart/form-data":
+		// * Anything over maxSize is rejected by MaxBytesReader,
+		// * so the whole form can be held in memory
+		maxMemory := maxSize
+		if maxMemory <= 0 {
+			maxMemory = 32 << 20 // * The default used by Request.FormValue
+		}
+
+		err := req.ParseMultipartForm(maxMemory)
+		if err != nil {
+			return nil, hppBodyError("Failed to parse multipart form", err)
+		}
+
+		if values := req.MultipartForm.Value["file"]; len(values) > 0 {
+			return []byte(values[0]), nil
+		}
+
+		if files := req.MultipartForm.File["file"]; len(files) > 0 {
+			return readHPPFormFile(files[0])
+		}
+	case "application/x-www-form-urlencoded":
+		err := req.ParseForm()
+		if err != nil {
+			return nil, hppBodyError("Failed to parse form", err)
+		}
+
+		if values := req.PostForm["file"]; len(values) > 0 {
+			return []byte(values[0]), nil
+		}
+	case "", "application/octet-stream":
+		payload, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, hppBodyError("Failed to read request body", err)
+		}
+
+		if len(payload) > 0 {
+			return payload, nil
+		}
+	default:
+		return nil, &hppRequestError{http.StatusUnsupportedMediaType, fmt.Errorf("Unsupported Content-Type %q", mediaType)}
+	}
+
+	return nil, &hppRequestError{http.StatusBadRequest, errors.New("Request does not contain an RMC request")}
+}
+
+func readHPPFormFile(header *multipart.FileHeader) ([]byte, error) {
+	file, err := header.Open()
+	if err != nil {
+		return nil, &hppRequestError{http.StatusBadRequest, fmt.Errorf("Failed to open uploaded file. %s", err)}
+	}
+
+	defer file.Close()
+
+	payload, err := io.ReadAll(file)
+	if err != nil {
+		return nil, &hppRequestError{http.StatusBadRequest, fmt.Errorf("Failed to read uploaded file. %s", err)}
+	}
+
+	return payload, nil
+}
+
+// hppBodyError maps an error reading the request body to the HTTP status to respond with
+func hppBodyError(message string, err error) *hppRequestError {
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		return &hppRequestError{http.StatusRequestEntityTooLarge, fmt.Errorf("%s. Request body is larger than %d bytes", message, maxBytesError.Limit)}
+	}
+
+	if errors.Is(err, multipart.ErrMessageTooLarge) {
+		return &hppRequestError{http.StatusRequestEntityTooLarge, fmt.Errorf("%s. %s", message, err)}
+	}
+
+	return &hppRequestError{http.StatusBadRequest, fmt.Errorf("%s. %s", message, err)}
+}
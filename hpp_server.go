@@ -1,15 +1,23 @@
 package nex
 
 import (
+	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net"
 	"net/http"
 	"strconv"
+	"sync/atomic"
+	"time"
 
 	"github.com/PretendoNetwork/nex-go/v2/types"
 )
 
+// DefaultHPPHandlerTimeout is how long a HPP request may go without a response by default
+const DefaultHPPHandlerTimeout = 30 * time.Second
+
 // HPPServer represents a bare-bones HPP server
 type HPPServer struct {
 	server                   *http.Server
@@ -18,9 +26,32 @@ type HPPServer struct {
 	dataHandlers             []func(packet PacketInterface)
 	errorEventHandlers       []func(err *Error)
 	byteStreamSettings       *ByteStreamSettings
-	AccountDetailsByPID      func(pid types.PID) (*Account, *Error)
-	AccountDetailsByUsername func(username string) (*Account, *Error)
+	AccountStore             AccountStore                             // * Looks up accounts to check password signatures. Wrap it with NewCachingAccountStore to avoid deriving a key on every request
+	AccountDetailsByPID      func(pid types.PID) (*Account, *Error)   // * Deprecated: Use AccountStore. Only used if AccountStore is nil
+	AccountDetailsByUsername func(username string) (*Account, *Error) // * Deprecated: Use AccountStore. Only used if AccountStore is nil
+	ValidateToken            func(pid types.PID, token string) *Error // * Checks the token header of signed requests before they are dispatched. The error is returned to the client as an RMC error. Tokens are not checked if nil
 	useVerboseRMC            bool
+	metrics                  *Metrics
+	banList                  *BanList
+	rateLimiter              *RateLimiter
+	logger                   Logger
+	logLevel                 *slog.LevelVar
+	tracer                   Tracer
+	handlerPool              *HandlerPool
+	handlerTimeout           atomic.Int64 // * time.Duration. Atomic, as ServerConfig.Reload may change it while requests are handled
+	maxRequestSize           atomic.Int64
+	mountPaths               []string
+}
+
+// hppStatusRecorder records the status code written to a ResponseWriter
+type hppStatusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *hppStatusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
 }
 
 // RegisterServiceProtocol registers a NEX service with the HPP server
@@ -29,7 +60,8 @@ func (s *HPPServer) RegisterServiceProtocol(protocol ServiceProtocol) {
 	s.OnData(protocol.HandlePacket)
 }
 
-// OnData adds an event handler which is fired when a new HPP request is received
+// OnData adds an event handler which is fired when a new HPP request is received.
+// Handlers must respond before returning. If every handler returns without responding, the client is sent a Core::NotImplemented error
 func (s *HPPServer) OnData(handler func(packet PacketInterface)) {
 	s.dataHandlers = append(s.dataHandlers, handler)
 }
@@ -41,9 +73,88 @@ func (s *HPPServer) EmitError(err *Error) {
 	}
 }
 
+// failRequest responds with an RMC error to a request which the data handlers did not respond to,
+// and emits the error to the servers error handlers.
+//
+// The request message is passed separately, since handlers may still replace the packets message with a late response
+func (s *HPPServer) failRequest(w http.ResponseWriter, packet *HPPPacket, request *RMCMessage, resultCode uint32, reason string) {
+	nexError := NewError(resultCode, fmt.Sprintf("RMC call %d to protocol %d method %d failed: %s", request.CallID, request.ProtocolID, request.MethodID, reason))
+
+	s.log().Warn(nexError.Error(),
+		"address", packet.Sender().Address().String(),
+		"pid", uint64(packet.Sender().PID()),
+		"call_id", request.CallID,
+	)
+
+	packet.trace.recordError(nexError)
+	s.EmitError(nexError)
+
+	errorResponse := NewRMCError(s, resultCode)
+	errorResponse.ProtocolID = request.ProtocolID
+	errorResponse.MethodID = request.MethodID
+	errorResponse.CallID = request.CallID
+
+	s.writeRMCError(w, packet, errorResponse)
+}
+
+// writeRMCError writes an RMC error response to the HTTP response
+func (s *HPPServer) writeRMCError(w http.ResponseWriter, packet *HPPPacket, errorResponse *RMCMessage) {
+	errorResponse.IsHPP = true
+
+	s.metrics.rmcResponse("hpp", errorResponse)
+
+	_, err := w.Write(errorResponse.Bytes())
+	if err != nil {
+		s.handleError(ResultCodes.Transport.IOError, packet, err)
+	}
+}
+
+// handleError logs the error and emits it to the servers error handlers with the packet attached
+func (s *HPPServer) handleError(resultCode uint32, packet *HPPPacket, err error) {
+	nexError := NewError(resultCode, err.Error())
+	nexError.Packet = packet
+
+	packet.trace.recordError(nexError)
+	s.log().Error(nexError.Error(), hppPacketLogFields(packet)...)
+	s.EmitError(nexError)
+}
+
+// ServeHTTP handles a HPP request. The request is handled regardless of it's path,
+// so the server can be mounted into an existing HTTP server at any path
+func (s *HPPServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.handleRequest(w, req)
+}
+
 func (s *HPPServer) handleRequest(w http.ResponseWriter, req *http.Request) {
-	if req.Method != "POST" {
-		w.WriteHeader(http.StatusBadRequest)
+	receivedAt := time.Now()
+
+	if s.metrics != nil {
+		start := receivedAt
+		recorder := &hppStatusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		defer func() {
+			s.metrics.hppRequestHandled(recorder.status, time.Since(start))
+		}()
+
+		w = recorder
+	}
+
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	address := requestAddr(req)
+	if s.banList.AddressBanned(address) {
+		s.log().Debug("Rejected HPP request from banned address", "address", req.RemoteAddr)
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	if !s.rateLimiter.Allow(address) {
+		s.log().Debug("Rejected rate limited HPP request", "address", req.RemoteAddr)
+		w.WriteHeader(http.StatusTooManyRequests)
 		return
 	}
 
@@ -53,7 +164,7 @@ func (s *HPPServer) handleRequest(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	// * The server checks that the header exists, but doesn't verify the value
+	// * The value is checked by ValidateToken, if it is set, once the request has been read
 	token := req.Header.Get("token")
 	if token == "" {
 		w.WriteHeader(http.StatusBadRequest)
@@ -78,14 +189,23 @@ func (s *HPPServer) handleRequest(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	rmcRequestString := req.FormValue("file")
+	if s.banList.PIDBanned(types.PID(pid)) {
+		s.log().Debug("Rejected HPP request from banned PID", "address", req.RemoteAddr, "pid", pid)
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
 
-	rmcRequestBytes := []byte(rmcRequestString)
+	rmcRequestBytes, err := readHPPRequestPayload(w, req, s.MaxRequestSize())
+	if err != nil {
+		s.log().Warn(err.Error(), "address", req.RemoteAddr, "pid", pid)
+		w.WriteHeader(err.(*hppRequestError).status)
+		return
+	}
 
 	tcpAddr, err := net.ResolveTCPAddr("tcp", req.RemoteAddr)
 	if err != nil {
 		// * Should never happen?
-		logger.Error(err.Error())
+		s.log().Error(err.Error(), "address", req.RemoteAddr, "pid", pid)
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
@@ -95,47 +215,132 @@ func (s *HPPServer) handleRequest(w http.ResponseWriter, req *http.Request) {
 
 	hppPacket, err := NewHPPPacket(client, rmcRequestBytes)
 	if err != nil {
-		logger.Error(err.Error())
+		s.log().Error(err.Error(), "address", req.RemoteAddr, "pid", pid)
+		s.EmitError(NewError(ResultCodes.Core.InvalidArgument, err.Error()))
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
+	s.metrics.rmcRequest("hpp", hppPacket.RMCMessage())
+
+	hppPacket.trace = startRMCTrace(req.Context(), s.tracer, hppPacket.RMCMessage(), receivedAt, append(hppPacketLogFields(hppPacket), "transport", "hpp", "size", len(rmcRequestBytes))...)
+	defer hppPacket.trace.end()
+
 	err = hppPacket.validateAccessKeySignature(accessKeySignature)
 	if err != nil {
-		logger.Error(err.Error())
+		s.handleError(ResultCodes.Core.AccessDenied, hppPacket, err)
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	err = hppPacket.validatePasswordSignature(passwordSignature)
+	err = hppPacket.validatePasswordSignature(req.Context(), passwordSignature)
 	if err != nil {
-		logger.Error(err.Error())
+		s.handleError(ResultCodes.PythonCore.ValidationError, hppPacket, err)
 
 		rmcMessage := hppPacket.RMCMessage()
 
 		// HPP returns PythonCore::ValidationError if password is missing or invalid
 		errorResponse := NewRMCError(s, ResultCodes.PythonCore.ValidationError)
 		errorResponse.CallID = rmcMessage.CallID
-		errorResponse.IsHPP = true
 
-		_, err = w.Write(errorResponse.Bytes())
-		if err != nil {
-			logger.Error(err.Error())
+		s.writeRMCError(w, hppPacket, errorResponse)
+
+		return
+	}
+
+	if s.ValidateToken != nil {
+		if tokenError := s.ValidateToken(client.PID(), token); tokenError != nil {
+			s.handleError(tokenError.ResultCode, hppPacket, errors.New(tokenError.Message))
+
+			rmcMessage := hppPacket.RMCMessage()
+
+			errorResponse := NewRMCError(s, tokenError.ResultCode)
+			errorResponse.ProtocolID = rmcMessage.ProtocolID
+			errorResponse.MethodID = rmcMessage.MethodID
+			errorResponse.CallID = rmcMessage.CallID
+
+			s.writeRMCError(w, hppPacket, errorResponse)
+
+			return
 		}
+	}
+
+	// * Handlers may replace the packets message with their response
+	request := hppPacket.RMCMessage()
+
+	ctx := req.Context()
+	if hppPacket.trace != nil {
+		ctx = hppPacket.trace.ctx
+	}
+
+	handlerTimeout := s.HandlerTimeout()
+
+	var cancel context.CancelFunc
+	if handlerTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, handlerTimeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+
+	defer cancel()
+
+	hppPacket.ctx = ctx
 
+	if len(s.dataHandlers) == 0 {
+		s.failRequest(w, hppPacket, request, ResultCodes.Core.NotImplemented, "no data handlers are registered")
 		return
 	}
 
-	for _, dataHandler := range s.dataHandlers {
-		go dataHandler(hppPacket)
+	panicked := make(chan any, 1)
+	recovered := func(value any, stack []byte) {
+		s.log().Error(fmt.Sprintf("Recovered from panic in HPP data handler: %v\n%s", value, stack), "call_id", request.CallID)
+
+		// * Only the first panic is needed to fail the request
+		select {
+		case panicked <- value:
+		default:
+		}
 	}
 
-	<-hppPacket.processed
+	// * Dispatched from a new goroutine so this one is
+	// * free to watch for the deadline and disconnects
+	returned := make(chan struct{})
+	go func() {
+		<-s.handlerPool.dispatch(s.dataHandlers, hppPacket, recovered)
+		close(returned)
+	}()
+
+	select {
+	case <-hppPacket.processed:
+	case <-returned:
+		// * Every handler returned without responding, so
+		// * none of them implement the requested method
+		select {
+		case <-hppPacket.processed:
+		case value := <-panicked:
+			s.failRequest(w, hppPacket, request, ResultCodes.Core.Exception, fmt.Sprintf("handler panicked: %v", value))
+			return
+		default:
+			s.failRequest(w, hppPacket, request, ResultCodes.Core.NotImplemented, "no data handler responded")
+			return
+		}
+	case value := <-panicked:
+		s.failRequest(w, hppPacket, request, ResultCodes.Core.Exception, fmt.Sprintf("handler panicked: %v", value))
+		return
+	case <-ctx.Done():
+		if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			// * The client disconnected, there is no one to respond to
+			return
+		}
+
+		s.failRequest(w, hppPacket, request, ResultCodes.Core.Timeout, fmt.Sprintf("not responded to within %s", handlerTimeout))
+		return
+	}
 
 	if len(hppPacket.payload) > 0 {
 		_, err = w.Write(hppPacket.payload)
 		if err != nil {
-			logger.Error(err.Error())
+			s.handleError(ResultCodes.Transport.IOError, hppPacket, err)
 		}
 	}
 }
@@ -166,7 +371,19 @@ func (s *HPPServer) Send(packet PacketInterface) {
 		packet.message.IsHPP = true
 		packet.payload = packet.message.Bytes()
 
-		packet.processed <- true
+		s.metrics.rmcResponse("hpp", packet.message)
+
+		packet.trace.addEvent("nex.rmc.response",
+			"success", packet.message.IsSuccess,
+			"error_code", packet.message.ErrorCode,
+			"size", len(packet.payload),
+		)
+
+		// * Never block, the request may have already timed out
+		select {
+		case packet.processed <- true:
+		default:
+		}
 	}
 }
 
@@ -185,6 +402,18 @@ func (s *HPPServer) SetAccessKey(accessKey string) {
 	s.accessKey = accessKey
 }
 
+// accountStore returns the AccountStore of the server, falling back to the deprecated lookup functions
+func (s *HPPServer) accountStore() AccountStore {
+	if s.AccountStore != nil {
+		return s.AccountStore
+	}
+
+	return AccountStoreFuncs{
+		ByPID:      s.AccountDetailsByPID,
+		ByUsername: s.AccountDetailsByUsername,
+	}
+}
+
 // ByteStreamSettings returns the settings to be used for ByteStreams
 func (s *HPPServer) ByteStreamSettings() *ByteStreamSettings {
 	return s.byteStreamSettings
@@ -205,6 +434,106 @@ func (s *HPPServer) EnableVerboseRMC(enable bool) {
 	s.useVerboseRMC = enable
 }
 
+// Metrics returns the metrics the server records to. May be nil
+func (s *HPPServer) Metrics() *Metrics {
+	return s.metrics
+}
+
+// SetMetrics sets the metrics the server records to. Set to nil to disable metrics
+func (s *HPPServer) SetMetrics(metrics *Metrics) {
+	s.metrics = metrics
+}
+
+// BanList returns the addresses and PIDs the server refuses requests from
+func (s *HPPServer) BanList() *BanList {
+	return s.banList
+}
+
+// RateLimiter returns the limit on how many requests each address may send. Not limited by default
+func (s *HPPServer) RateLimiter() *RateLimiter {
+	return s.rateLimiter
+}
+
+// Logger returns the logger the server writes warnings and errors to
+func (s *HPPServer) Logger() Logger {
+	return s.logger
+}
+
+// SetLogger sets the logger the server writes warnings and errors to. A *slog.Logger may be used
+func (s *HPPServer) SetLogger(logger Logger) {
+	s.logger = logger
+}
+
+// LogLevel returns the level below which messages are not passed to the logger. Passes every message by default
+func (s *HPPServer) LogLevel() *slog.LevelVar {
+	return s.logLevel
+}
+
+// log returns the logger, filtered by the LogLevel
+func (s *HPPServer) log() Logger {
+	return levelLogger{logger: s.logger, level: s.logLevel}
+}
+
+// Tracer returns the tracer used to trace RMC calls. May be nil
+func (s *HPPServer) Tracer() Tracer {
+	return s.tracer
+}
+
+// SetTracer sets the tracer used to trace RMC calls. Set to nil to disable tracing
+func (s *HPPServer) SetTracer(tracer Tracer) {
+	s.tracer = tracer
+}
+
+// HandlerPool returns the pool which runs the data handlers. May be nil
+func (s *HPPServer) HandlerPool() *HandlerPool {
+	return s.handlerPool
+}
+
+// SetHandlerPool sets the pool which runs the data handlers. If nil, all handlers for a request run on a new goroutine
+func (s *HPPServer) SetHandlerPool(handlerPool *HandlerPool) {
+	s.handlerPool = handlerPool
+}
+
+// HandlerTimeout returns how long a request may go without a response before the client is sent a Core::Timeout error
+func (s *HPPServer) HandlerTimeout() time.Duration {
+	return time.Duration(s.handlerTimeout.Load())
+}
+
+// SetHandlerTimeout sets how long a request may go without a response before the client is sent a Core::Timeout error.
+// Defaults to DefaultHPPHandlerTimeout. If 0, requests which are never responded to stay open until the client disconnects
+func (s *HPPServer) SetHandlerTimeout(timeout time.Duration) {
+	s.handlerTimeout.Store(int64(timeout))
+}
+
+// MaxRequestSize returns the largest request body the server accepts, in bytes
+func (s *HPPServer) MaxRequestSize() int64 {
+	return s.maxRequestSize.Load()
+}
+
+// SetMaxRequestSize sets the largest request body the server accepts, in bytes. Larger requests are
+// rejected with 413 Request Entity Too Large. Defaults to DefaultHPPMaxRequestSize. If 0, there is no limit
+func (s *HPPServer) SetMaxRequestSize(size int64) {
+	s.maxRequestSize.Store(size)
+}
+
+// MountPaths returns the paths the server handles requests on when using Listen or ListenSecure
+func (s *HPPServer) MountPaths() []string {
+	return s.mountPaths
+}
+
+// SetMountPaths sets the paths the server handles requests on when using Listen or ListenSecure. Defaults to "/hpp/".
+// Paths use the http.ServeMux pattern syntax, so paths ending in a slash match everything under them
+func (s *HPPServer) SetMountPaths(paths ...string) {
+	s.mountPaths = paths
+
+	mux := http.NewServeMux()
+	for _, path := range paths {
+		mux.Handle(path, s)
+	}
+
+	s.server.Handler = mux
+}
+
 // NewHPPServer returns a new HPP server
 func NewHPPServer() *HPPServer {
 	s := &HPPServer{
@@ -212,19 +541,22 @@ func NewHPPServer() *HPPServer {
 		errorEventHandlers: make([]func(err *Error), 0),
 		libraryVersions:    NewLibraryVersions(),
 		byteStreamSettings: NewByteStreamSettings(),
+		banList:            NewBanList(),
+		rateLimiter:        NewRateLimiter(0, 0),
+		logger:             defaultLogger,
+		logLevel:           newLogLevel(),
 	}
 
-	mux := http.NewServeMux()
-	mux.HandleFunc("/hpp/", s.handleRequest)
-
 	httpServer := &http.Server{
-		Handler: mux,
 		TLSConfig: &tls.Config{
 			MinVersion: tls.VersionTLS11, // * The 3DS and Wii U only support up to TLS 1.1 natively
 		},
 	}
 
 	s.server = httpServer
+	s.SetMountPaths("/hpp/")
+	s.SetHandlerTimeout(DefaultHPPHandlerTimeout)
+	s.SetMaxRequestSize(DefaultHPPMaxRequestSize)
 
 	return s
 }
@@ -0,0 +1,213 @@
+package nex
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/PretendoNetwork/nex-go/v2/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestHPPServer() *HPPServer {
+	server := NewHPPServer()
+	server.SetAccessKey("76f26496")
+	server.AccountDetailsByPID = func(pid types.PID) (*Account, *Error) {
+		return NewAccount(pid, "test", "password"), nil
+	}
+
+	return server
+}
+
+// newTestHPPRequest builds a signed HPP request. If raw is true, the RMC request is sent as the request body
+func newTestHPPRequest(server *HPPServer, parameters []byte, raw bool) *http.Request {
+	request := NewRMCRequest(server)
+	request.ProtocolID = 0x70
+	request.MethodID = 1
+	request.CallID = 1
+	request.Parameters = parameters
+	payload := request.Bytes()
+
+	sign := func(key []byte) string {
+		mac := hmac.New(md5.New, key)
+		mac.Write(payload)
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+
+	accessKey, _ := hex.DecodeString(server.AccessKey())
+
+	var req *http.Request
+	if raw {
+		req = httptest.NewRequest(http.MethodPost, "/hpp/", bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/octet-stream")
+	} else {
+		body := new(bytes.Buffer)
+		form := multipart.NewWriter(body)
+		part, _ := form.CreateFormField("file")
+		part.Write(payload)
+		form.Close()
+
+		req = httptest.NewRequest(http.MethodPost, "/hpp/", body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+	}
+
+	req.Header.Set("pid", "1000")
+	req.Header.Set("token", "token")
+	req.Header.Set("signature1", sign(accessKey))
+	req.Header.Set("signature2", sign(DeriveKerberosKey(types.NewPID(1000), []byte("password"))))
+
+	return req
+}
+
+// testHPPRequest sends an RMC request to the server and returns the error code of the response
+func testHPPRequest(t *testing.T, server *HPPServer) uint32 {
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, newTestHPPRequest(server, nil, false))
+
+	response := recorder.Body.Bytes()
+	if !assert.Len(t, response, 13) {
+		return 0
+	}
+
+	assert.Equal(t, uint8(0), response[4]) // * IsSuccess
+
+	return binary.LittleEndian.Uint32(response[5:9])
+}
+
+func TestHPPRequestWithoutHandlersIsNotImplemented(t *testing.T) {
+	server := newTestHPPServer()
+
+	assert.Equal(t, NewError(ResultCodes.Core.NotImplemented, "").ResultCode, testHPPRequest(t, server))
+}
+
+func TestHPPRequestNotRespondedToIsNotImplemented(t *testing.T) {
+	server := newTestHPPServer()
+	server.OnData(func(packet PacketInterface) {
+		// * Handlers ignore requests for protocols they don't implement
+	})
+
+	start := time.Now()
+
+	assert.Equal(t, NewError(ResultCodes.Core.NotImplemented, "").ResultCode, testHPPRequest(t, server))
+	assert.Less(t, time.Since(start), time.Second, "request waited for the handler timeout")
+}
+
+func TestHPPHandlerPanicIsRecovered(t *testing.T) {
+	server := newTestHPPServer()
+	server.OnData(func(packet PacketInterface) {
+		panic("handler failed")
+	})
+
+	assert.Equal(t, NewError(ResultCodes.Core.Exception, "").ResultCode, testHPPRequest(t, server))
+}
+
+func TestHPPRequestTimesOut(t *testing.T) {
+	server := newTestHPPServer()
+	server.SetHandlerTimeout(50 * time.Millisecond)
+	server.OnData(func(packet PacketInterface) {
+		<-PacketContext(packet).Done()
+	})
+
+	assert.Equal(t, NewError(ResultCodes.Core.Timeout, "").ResultCode, testHPPRequest(t, server))
+}
+
+func TestHPPRawBodyRequest(t *testing.T) {
+	server := newTestHPPServer()
+	server.OnData(func(packet PacketInterface) {
+		packet.SetRMCMessage(NewRMCSuccess(server, nil))
+		server.Send(packet)
+	})
+
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, newTestHPPRequest(server, nil, true))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, uint8(1), recorder.Body.Bytes()[4]) // * IsSuccess
+}
+
+func TestHPPRequestSizeLimit(t *testing.T) {
+	server := newTestHPPServer()
+	server.SetMaxRequestSize(64)
+
+	for _, raw := range []bool{true, false} {
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, newTestHPPRequest(server, make([]byte, 128), raw))
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, recorder.Code)
+	}
+}
+
+func TestHPPRequestMethodAndContentType(t *testing.T) {
+	server := newTestHPPServer()
+
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/hpp/", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+
+	req := newTestHPPRequest(server, nil, true)
+	req.Header.Set("Content-Type", "text/plain")
+
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusUnsupportedMediaType, recorder.Code)
+}
+
+func TestHPPTokenValidation(t *testing.T) {
+	server := newTestHPPServer()
+	server.ValidateToken = func(pid types.PID, token string) *Error {
+		assert.Equal(t, types.NewPID(1000), pid)
+		assert.Equal(t, "token", token)
+
+		return NewError(ResultCodes.Authentication.TokenExpired, "token expired")
+	}
+
+	server.OnData(func(packet PacketInterface) {
+		t.Error("Request with an invalid token was dispatched")
+	})
+
+	server.SetMetrics(NewMetrics())
+
+	resultCode := NewError(ResultCodes.Authentication.TokenExpired, "").ResultCode
+	assert.Equal(t, resultCode, testHPPRequest(t, server))
+
+	// * HPP responses don't carry the protocol and method IDs, but they are still recorded
+	assert.Equal(t, float64(1), server.Metrics().RMCResponses.WithLabelValues("hpp", "112", "1", "0x"+strconv.FormatUint(uint64(resultCode), 16)).Value())
+}
+
+func TestHPPRequestBansAndRateLimit(t *testing.T) {
+	server := newTestHPPServer()
+	server.OnData(func(packet PacketInterface) {
+		t.Error("Refused request was dispatched")
+	})
+
+	server.BanList().Set(nil, []types.PID{types.NewPID(1000)})
+
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, newTestHPPRequest(server, nil, false))
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+
+	// * httptest requests come from 192.0.2.1
+	server.BanList().Set([]netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")}, nil)
+
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, newTestHPPRequest(server, nil, false))
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+
+	server.BanList().Set(nil, nil)
+	server.RateLimiter().SetLimit(1, 1)
+	server.RateLimiter().Allow(&net.TCPAddr{IP: net.IPv4(192, 0, 2, 1)})
+
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, newTestHPPRequest(server, nil, false))
+	assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+}
@@ -2,13 +2,11 @@ package nex
 
 import (
 	"github.com/PretendoNetwork/nex-go/v2/types"
-	"github.com/PretendoNetwork/plogger-go"
 )
 
-var logger = plogger.NewLogger()
-
 func init() {
 	initResultCodes()
+	registerBuiltinGameProfiles()
 
 	types.RegisterVariantType(1, types.NewInt64(0))
 	types.RegisterVariantType(2, types.NewDouble(0))
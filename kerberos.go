@@ -116,7 +116,7 @@ func (ti *KerberosTicketInternalData) Encrypt(key []byte, stream *ByteStreamOut)
 			return nil, fmt.Errorf("Failed to generate ticket key. %s", err.Error())
 		}
 
-		hash := md5.Sum(append(key, ticketKey...))
+		hash := md5.Sum(append(append([]byte{}, key...), ticketKey...))
 		finalKey := hash[:]
 
 		encryption := NewKerberosEncryption(finalKey)
@@ -148,11 +148,11 @@ func (ti *KerberosTicketInternalData) Decrypt(stream *ByteStreamIn, key []byte)
 		}
 
 		data := types.NewBuffer(nil)
-		if err := ticketKey.ExtractFrom(stream); err != nil {
+		if err := data.ExtractFrom(stream); err != nil {
 			return fmt.Errorf("Failed to read Kerberos ticket internal data. %s", err.Error())
 		}
 
-		hash := md5.Sum(append(key, ticketKey...))
+		hash := md5.Sum(append(append([]byte{}, key...), ticketKey...))
 		key = hash[:]
 
 		stream = NewByteStreamIn(data, stream.LibraryVersions, stream.Settings)
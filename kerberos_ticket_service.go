@@ -0,0 +1,178 @@
+package nex
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"time"
+
+	"github.com/PretendoNetwork/nex-go/v2/types"
+)
+
+// KerberosTicketService issues the Kerberos tickets which grant users access to a secure server,
+// and validates them when they are presented to it.
+//
+// Tickets are issued by the authentication server and validated by the secure server, so both
+// must use the same KerberosTicketVersion and SessionKeyLength
+type KerberosTicketService struct {
+	Server         *PRUDPServer  // * Provides the KerberosTicketVersion, SessionKeyLength, LibraryVersions and ByteStreamSettings of tickets
+	AccountStore   AccountStore  // * Used to get cached Kerberos keys if it is a KerberosKeyStore. Otherwise keys are derived from the accounts passwords
+	TicketLifetime time.Duration // * How long after being issued a ticket is accepted
+	ClockSkew      time.Duration // * How far the clocks of the issuing and validating servers may differ. Widens both ends of a tickets lifetime
+}
+
+// DefaultKerberosTicketLifetime is how long a Kerberos ticket is accepted after being issued by default
+const DefaultKerberosTicketLifetime = 2 * time.Minute
+
+// DefaultKerberosClockSkew is how far the clocks of the servers issuing and validating Kerberos tickets may differ by default
+const DefaultKerberosClockSkew = 30 * time.Second
+
+// ValidatedKerberosTicket is the content of a ticket and request which passed KerberosTicketService.ValidateTicket
+type ValidatedKerberosTicket struct {
+	SourcePID  types.PID // * PID of the user the ticket was issued to
+	SessionKey []byte
+	Issued     types.DateTime
+	ExpiresAt  time.Time // * When the ticket stops being accepted, including the allowed clock skew
+	CheckValue uint32    // * Random value sent by the client, which the server proves it could decrypt by answering with CheckValue+1
+}
+
+// IssueTicket generates a new session key and returns a ticket granting the source account access to the target account,
+// usually the secure server. The ticket is encrypted with the source accounts key, and contains the internal data which
+// is encrypted with the target accounts key.
+//
+// Tickets are returned to the client by TicketGranting::Login, TicketGranting::LoginEx and TicketGranting::RequestTicket
+func (kts *KerberosTicketService) IssueTicket(ctx context.Context, source, target *Account) ([]byte, error) {
+	sourceKey, err := kts.key(ctx, kts.AccountStore, source)
+	if err != nil {
+		return nil, err
+	}
+
+	targetKey, err := kts.key(ctx, kts.AccountStore, target)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionKey := make([]byte, kts.Server.SessionKeyLength)
+	if _, err := rand.Read(sessionKey); err != nil {
+		return nil, fmt.Errorf("Failed to generate session key. %s", err.Error())
+	}
+
+	internalData := NewKerberosTicketInternalData(kts.Server)
+	internalData.Issued = types.NewDateTime(0).Now()
+	internalData.SourcePID = source.PID
+	internalData.SessionKey = sessionKey
+
+	encryptedInternalData, err := internalData.Encrypt(targetKey, NewByteStreamOut(kts.Server.LibraryVersions, kts.Server.ByteStreamSettings))
+	if err != nil {
+		return nil, err
+	}
+
+	ticket := NewKerberosTicket()
+	ticket.SessionKey = sessionKey
+	ticket.TargetPID = target.PID
+	ticket.InternalData = types.NewBuffer(encryptedInternalData)
+
+	return ticket.Encrypt(sourceKey, NewByteStreamOut(kts.Server.LibraryVersions, kts.Server.ByteStreamSettings))
+}
+
+// ValidateTicket decrypts the ticket internal data sent by a client with the target accounts key, and checks the request
+// data, which the client encrypts with the session key from the ticket. This is the counterpart of IssueTicket.
+//
+// The ticket internal data and request data are the two buffers sent in the payload of a secure CONNECT packet.
+// The returned error is always a *Error, whose result code tells why the ticket was rejected:
+//   - Core::InvalidArgument if the ticket or request are malformed
+//   - Transport::IncorrectRemoteAuthentication if the ticket was not issued for the target account, or the request was not encrypted with it's session key
+//   - Authentication::TokenExpired if the ticket was issued more than TicketLifetime ago
+//   - Authentication::ValidationFailed if the ticket was issued in the future
+//   - Authentication::PrincipalIDUnmatched if the request was not sent by the user the ticket was issued to
+//
+// ValidateTicket does not check if the ticket was used before. PRUDPEndPoint rejects replayed tickets itself
+func (kts *KerberosTicketService) ValidateTicket(ctx context.Context, target *Account, ticketData, requestData []byte) (*ValidatedKerberosTicket, error) {
+	return kts.validateTicket(ctx, kts.AccountStore, target, ticketData, requestData)
+}
+
+// validateTicket is ValidateTicket, getting the target accounts key from the given account store instead of the services
+func (kts *KerberosTicketService) validateTicket(ctx context.Context, accountStore AccountStore, target *Account, ticketData, requestData []byte) (*ValidatedKerberosTicket, error) {
+	targetKey, err := kts.key(ctx, accountStore, target)
+	if err != nil {
+		return nil, err
+	}
+
+	internalData := NewKerberosTicketInternalData(kts.Server)
+	if err := internalData.Decrypt(NewByteStreamIn(ticketData, kts.Server.LibraryVersions, kts.Server.ByteStreamSettings), targetKey); err != nil {
+		return nil, NewError(ResultCodes.Transport.IncorrectRemoteAuthentication, err.Error())
+	}
+
+	issued := internalData.Issued.Standard()
+	serverTime := time.Now().UTC()
+	expiresAt := issued.Add(kts.TicketLifetime + kts.ClockSkew)
+
+	if serverTime.After(expiresAt) {
+		return nil, NewError(ResultCodes.Authentication.TokenExpired, fmt.Sprintf("Kerberos ticket expired at %s", expiresAt.Format(time.RFC3339)))
+	}
+
+	if issued.After(serverTime.Add(kts.ClockSkew)) {
+		return nil, NewError(ResultCodes.Authentication.ValidationFailed, fmt.Sprintf("Kerberos ticket issued in the future at %s", issued.Format(time.RFC3339)))
+	}
+
+	kerberos := NewKerberosEncryption(internalData.SessionKey)
+
+	decryptedRequestData, err := kerberos.Decrypt(requestData)
+	if err != nil {
+		return nil, NewError(ResultCodes.Transport.IncorrectRemoteAuthentication, err.Error())
+	}
+
+	checkDataStream := NewByteStreamIn(decryptedRequestData, kts.Server.LibraryVersions, kts.Server.ByteStreamSettings)
+
+	userPID := types.NewPID(0)
+	if err := userPID.ExtractFrom(checkDataStream); err != nil {
+		return nil, NewError(ResultCodes.Core.InvalidArgument, fmt.Sprintf("Failed to read Kerberos request user PID. %s", err.Error()))
+	}
+
+	if userPID != internalData.SourcePID {
+		return nil, NewError(ResultCodes.Authentication.PrincipalIDUnmatched, "User PID and ticket source PID mismatch")
+	}
+
+	_, err = checkDataStream.ReadUInt32LE() // * CID of secure server station url
+	if err != nil {
+		return nil, NewError(ResultCodes.Core.InvalidArgument, fmt.Sprintf("Failed to read Kerberos request CID. %s", err.Error()))
+	}
+
+	checkValue, err := checkDataStream.ReadUInt32LE()
+	if err != nil {
+		return nil, NewError(ResultCodes.Core.InvalidArgument, fmt.Sprintf("Failed to read Kerberos request check value. %s", err.Error()))
+	}
+
+	return &ValidatedKerberosTicket{
+		SourcePID:  internalData.SourcePID,
+		SessionKey: internalData.SessionKey,
+		Issued:     internalData.Issued,
+		ExpiresAt:  expiresAt,
+		CheckValue: checkValue,
+	}, nil
+}
+
+// key returns the Kerberos key of the account, using the account store if it is a KerberosKeyStore
+func (kts *KerberosTicketService) key(ctx context.Context, accountStore AccountStore, account *Account) ([]byte, error) {
+	if account == nil {
+		return nil, NewError(ResultCodes.RendezVous.InvalidPID, "Missing Kerberos ticket account")
+	}
+
+	key, err := kerberosKey(ctx, accountStore, account.PID, account)
+	if err != nil {
+		return nil, NewError(err.ResultCode, fmt.Sprintf("Failed to get Kerberos key of PID %d. %s", account.PID, err.Message))
+	}
+
+	return key, nil
+}
+
+// NewKerberosTicketService returns a new KerberosTicketService using the settings of the server,
+// which accepts tickets for DefaultKerberosTicketLifetime with DefaultKerberosClockSkew
+func NewKerberosTicketService(server *PRUDPServer, accountStore AccountStore) *KerberosTicketService {
+	return &KerberosTicketService{
+		Server:         server,
+		AccountStore:   accountStore,
+		TicketLifetime: DefaultKerberosTicketLifetime,
+		ClockSkew:      DefaultKerberosClockSkew,
+	}
+}
@@ -0,0 +1,258 @@
+package nex
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/PretendoNetwork/nex-go/v2/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// openTestTicket decrypts a ticket as the client would, and returns the session key and internal data
+func openTestTicket(t *testing.T, server *PRUDPServer, source *Account, encrypted []byte) ([]byte, []byte) {
+	decrypted, err := NewKerberosEncryption(DeriveKerberosKey(source.PID, []byte(source.Password))).Decrypt(encrypted)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	stream := NewByteStreamIn(decrypted, server.LibraryVersions, server.ByteStreamSettings)
+	sessionKey := stream.ReadBytesNext(int64(server.SessionKeyLength))
+
+	targetPID := types.NewPID(0)
+	internalData := types.NewBuffer(nil)
+	assert.NoError(t, targetPID.ExtractFrom(stream))
+	assert.NoError(t, internalData.ExtractFrom(stream))
+
+	return sessionKey, internalData
+}
+
+// newTestTicketRequest builds the request data a client sends along with a ticket when connecting to the secure server
+func newTestTicketRequest(server *PRUDPServer, pid types.PID, sessionKey []byte, checkValue uint32) []byte {
+	stream := NewByteStreamOut(server.LibraryVersions, server.ByteStreamSettings)
+	pid.WriteTo(stream)
+	stream.WriteUInt32LE(1)
+	stream.WriteUInt32LE(checkValue)
+
+	return NewKerberosEncryption(sessionKey).Encrypt(stream.Bytes())
+}
+
+func TestKerberosTicketService(t *testing.T) {
+	source := NewAccount(types.NewPID(1800000000), "1800000000", "nexuserpassword")
+	target := NewAccount(types.NewPID(2), "Quazal Rendez-Vous", "securepassword")
+
+	for _, version := range []int{0, 1} {
+		server := NewPRUDPServer()
+		server.KerberosTicketVersion = version
+
+		service := NewKerberosTicketService(server, nil)
+
+		encrypted, err := service.IssueTicket(context.Background(), source, target)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		sessionKey, internalData := openTestTicket(t, server, source, encrypted)
+		assert.Len(t, sessionKey, server.SessionKeyLength)
+
+		ticket, err := service.ValidateTicket(context.Background(), target, internalData, newTestTicketRequest(server, source.PID, sessionKey, 1234))
+		if !assert.NoError(t, err, "ticket version %d", version) {
+			continue
+		}
+
+		assert.Equal(t, source.PID, ticket.SourcePID)
+		assert.Equal(t, sessionKey, ticket.SessionKey)
+		assert.Equal(t, uint32(1234), ticket.CheckValue)
+
+		// * Tickets are only valid for their target, and only for the PID they were issued to
+		_, err = service.ValidateTicket(context.Background(), source, internalData, newTestTicketRequest(server, source.PID, sessionKey, 1234))
+		assert.Error(t, err)
+
+		_, err = service.ValidateTicket(context.Background(), target, internalData, newTestTicketRequest(server, target.PID, sessionKey, 1234))
+		assert.ErrorContains(t, err, "PID mismatch")
+	}
+}
+
+func TestKerberosTicketServiceLifetime(t *testing.T) {
+	source := NewAccount(types.NewPID(1800000000), "1800000000", "nexuserpassword")
+	target := NewAccount(types.NewPID(2), "Quazal Rendez-Vous", "securepassword")
+
+	server := NewPRUDPServer()
+	service := NewKerberosTicketService(server, nil)
+	service.ClockSkew = time.Minute
+
+	// * Validates a ticket issued by a server with a different clock
+	validate := func(issued time.Time) error {
+		sessionKey := make([]byte, server.SessionKeyLength)
+
+		issuedAt := types.NewDateTime(0)
+
+		internalData := NewKerberosTicketInternalData(server)
+		internalData.Issued = issuedAt.FromTimestamp(issued.UTC())
+		internalData.SourcePID = source.PID
+		internalData.SessionKey = sessionKey
+
+		encrypted, _ := internalData.Encrypt(DeriveKerberosKey(target.PID, []byte(target.Password)), NewByteStreamOut(server.LibraryVersions, server.ByteStreamSettings))
+
+		_, err := service.ValidateTicket(context.Background(), target, encrypted, newTestTicketRequest(server, source.PID, sessionKey, 1))
+
+		return err
+	}
+
+	resultCode := func(err error) uint32 {
+		var nexError *Error
+		if !assert.ErrorAs(t, err, &nexError) {
+			return 0
+		}
+
+		return nexError.ResultCode
+	}
+
+	// * Within the lifetime, allowing for the skew
+	assert.NoError(t, validate(time.Now().Add(-service.TicketLifetime-30*time.Second)))
+	assert.NoError(t, validate(time.Now().Add(30*time.Second)))
+
+	assert.Equal(t, NewError(ResultCodes.Authentication.TokenExpired, "").ResultCode, resultCode(validate(time.Now().Add(-service.TicketLifetime-2*time.Minute))))
+	assert.Equal(t, NewError(ResultCodes.Authentication.ValidationFailed, "").ResultCode, resultCode(validate(time.Now().Add(2*time.Minute))))
+}
+
+func TestKerberosTicketReplay(t *testing.T) {
+	source := NewAccount(types.NewPID(1800000000), "1800000000", "nexuserpassword")
+	target := NewAccount(types.NewPID(2), "Quazal Rendez-Vous", "securepassword")
+
+	server, endpoint := newTestSessionServer()
+	endpoint.ServerAccount = target
+
+	encrypted, err := NewKerberosTicketService(server, nil).IssueTicket(context.Background(), source, target)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	sessionKey, internalData := openTestTicket(t, server, source, encrypted)
+
+	connectPayload := func(checkValue uint32) []byte {
+		stream := NewByteStreamOut(server.LibraryVersions, server.ByteStreamSettings)
+		types.NewBuffer(internalData).WriteTo(stream)
+		types.NewBuffer(newTestTicketRequest(server, source.PID, sessionKey, checkValue)).WriteTo(stream)
+
+		return stream.Bytes()
+	}
+
+	client := NewPRUDPConnection(NewSocketConnection(server, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 60000}, nil))
+	attacker := NewPRUDPConnection(NewSocketConnection(server, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 2), Port: 60000}, nil))
+
+	_, pid, _, ticketError := endpoint.readKerberosTicket(client, connectPayload(1))
+	assert.Nil(t, ticketError)
+	assert.Equal(t, source.PID, pid)
+
+	// * Resent CONNECTs from the same client are accepted
+	_, _, _, ticketError = endpoint.readKerberosTicket(client, connectPayload(1))
+	assert.Nil(t, ticketError)
+
+	_, _, _, ticketError = endpoint.readKerberosTicket(attacker, connectPayload(1))
+	if assert.NotNil(t, ticketError) {
+		assert.Equal(t, NewError(ResultCodes.RendezVous.PermissionDenied, "").ResultCode, ticketError.ResultCode)
+	}
+
+	// * The same ticket may be used again with a new check value
+	_, _, _, ticketError = endpoint.readKerberosTicket(attacker, connectPayload(2))
+	assert.Nil(t, ticketError)
+}
+
+func TestKerberosTicketFromBannedPID(t *testing.T) {
+	source := NewAccount(types.NewPID(1800000000), "1800000000", "nexuserpassword")
+	target := NewAccount(types.NewPID(2), "Quazal Rendez-Vous", "securepassword")
+
+	server, endpoint := newTestSessionServer()
+	endpoint.ServerAccount = target
+	server.BanList.Set(nil, []types.PID{source.PID})
+
+	encrypted, err := NewKerberosTicketService(server, nil).IssueTicket(context.Background(), source, target)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	sessionKey, internalData := openTestTicket(t, server, source, encrypted)
+
+	stream := NewByteStreamOut(server.LibraryVersions, server.ByteStreamSettings)
+	types.NewBuffer(internalData).WriteTo(stream)
+	types.NewBuffer(newTestTicketRequest(server, source.PID, sessionKey, 1)).WriteTo(stream)
+
+	client := NewPRUDPConnection(NewSocketConnection(server, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 60000}, nil))
+
+	_, _, _, ticketError := endpoint.readKerberosTicket(client, stream.Bytes())
+	if assert.NotNil(t, ticketError) {
+		assert.Equal(t, NewError(ResultCodes.RendezVous.AccountDisabled, "").ResultCode, ticketError.ResultCode)
+	}
+}
+
+func TestEndpointKerberosTicketServiceIsKept(t *testing.T) {
+	_, endpoint := newTestSessionServer()
+
+	service := endpoint.kerberosTicketService()
+	assert.Same(t, service, endpoint.kerberosTicketService())
+	assert.Equal(t, DefaultKerberosTicketLifetime, service.TicketLifetime)
+
+	// * Setting the fields directly replaces the service on the next CONNECT
+	endpoint.KerberosClockSkew = time.Minute
+
+	replaced := endpoint.kerberosTicketService()
+	assert.NotSame(t, service, replaced)
+	assert.Equal(t, time.Minute, replaced.ClockSkew)
+
+	// * So does reloading them
+	reloaded := NewPRUDPEndPoint(endpoint.StreamID)
+	reloaded.KerberosTicketLifetime = 5 * time.Minute
+	reloaded.KerberosClockSkew = time.Minute
+	endpoint.reloadSettings(reloaded)
+
+	service = endpoint.kerberosTicketService()
+	assert.NotSame(t, replaced, service)
+	assert.Equal(t, 5*time.Minute, service.TicketLifetime)
+	assert.Equal(t, time.Minute, service.ClockSkew)
+
+	// * The service which was replaced is left untouched, since tickets may still be validated with it
+	assert.Equal(t, DefaultKerberosTicketLifetime, replaced.TicketLifetime)
+}
+
+func TestEndpointKerberosTicketServiceUsesCurrentAccountStore(t *testing.T) {
+	source := NewAccount(types.NewPID(1800000000), "1800000000", "nexuserpassword")
+	target := NewAccount(types.NewPID(2), "Quazal Rendez-Vous", "securepassword")
+
+	server, endpoint := newTestSessionServer()
+	endpoint.ServerAccount = target
+
+	encrypted, err := NewKerberosTicketService(server, nil).IssueTicket(context.Background(), source, target)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	sessionKey, internalData := openTestTicket(t, server, source, encrypted)
+
+	connectPayload := func(checkValue uint32) []byte {
+		stream := NewByteStreamOut(server.LibraryVersions, server.ByteStreamSettings)
+		types.NewBuffer(internalData).WriteTo(stream)
+		types.NewBuffer(newTestTicketRequest(server, source.PID, sessionKey, checkValue)).WriteTo(stream)
+
+		return stream.Bytes()
+	}
+
+	client := NewPRUDPConnection(NewSocketConnection(server, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 60000}, nil))
+
+	_, _, _, ticketError := endpoint.readKerberosTicket(client, connectPayload(1))
+	assert.Nil(t, ticketError)
+
+	// * A store set after the first CONNECT is used by the next one. Here it holds
+	// * a key for the server account which the ticket was not encrypted with
+	endpoint.AccountStore = NewCachingAccountStore(AccountStoreFuncs{
+		ByPID: func(pid types.PID) (*Account, *Error) {
+			return NewAccount(pid, target.Username, "rotatedpassword"), nil
+		},
+	}, time.Hour)
+
+	_, _, _, ticketError = endpoint.readKerberosTicket(client, connectPayload(2))
+	if assert.NotNil(t, ticketError) {
+		assert.Equal(t, NewError(ResultCodes.Transport.IncorrectRemoteAuthentication, "").ResultCode, ticketError.ResultCode)
+	}
+}
@@ -0,0 +1,178 @@
+package nex
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/PretendoNetwork/plogger-go"
+)
+
+// Logger is the interface used by servers to log warnings and errors.
+//
+// The methods match those of *slog.Logger, so a *slog.Logger can be used directly.
+// args are alternating key/value pairs of structured fields, such as "connection_id", 1
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// defaultLogger is the Logger used by servers which have not been given one.
+// It writes to the plogger logger used by previous versions of the library
+var defaultLogger Logger = &ploggerLogger{}
+
+// ploggerLogger is a Logger which writes to a plogger logger, appending the structured fields to the message.
+// The plogger logger creates it's log files in ./log, so it is only created once something is logged
+type ploggerLogger struct {
+	once   sync.Once
+	logger *plogger.Logger
+}
+
+func (pl *ploggerLogger) get() *plogger.Logger {
+	pl.once.Do(func() {
+		pl.logger = plogger.NewLogger()
+	})
+
+	return pl.logger
+}
+
+// Debug logs a message at the info level, as plogger has no debug level
+func (pl *ploggerLogger) Debug(msg string, args ...any) {
+	pl.get().Info(formatLogFields(msg, args))
+}
+
+// Info logs a message at the info level
+func (pl *ploggerLogger) Info(msg string, args ...any) {
+	pl.get().Info(formatLogFields(msg, args))
+}
+
+// Warn logs a message at the warning level
+func (pl *ploggerLogger) Warn(msg string, args ...any) {
+	pl.get().Warning(formatLogFields(msg, args))
+}
+
+// Error logs a message at the error level
+func (pl *ploggerLogger) Error(msg string, args ...any) {
+	pl.get().Error(formatLogFields(msg, args))
+}
+
+// levelLogger passes the messages at or above a level to a Logger.
+// The level is a *slog.LevelVar, so it can be changed while the server is running
+type levelLogger struct {
+	logger Logger
+	level  *slog.LevelVar
+}
+
+func (ll levelLogger) enabled(level slog.Level) bool {
+	return ll.level == nil || level >= ll.level.Level()
+}
+
+// Debug logs a message at the debug level
+func (ll levelLogger) Debug(msg string, args ...any) {
+	if ll.enabled(slog.LevelDebug) {
+		ll.logger.Debug(msg, args...)
+	}
+}
+
+// Info logs a message at the info level
+func (ll levelLogger) Info(msg string, args ...any) {
+	if ll.enabled(slog.LevelInfo) {
+		ll.logger.Info(msg, args...)
+	}
+}
+
+// Warn logs a message at the warning level
+func (ll levelLogger) Warn(msg string, args ...any) {
+	if ll.enabled(slog.LevelWarn) {
+		ll.logger.Warn(msg, args...)
+	}
+}
+
+// Error logs a message at the error level
+func (ll levelLogger) Error(msg string, args ...any) {
+	if ll.enabled(slog.LevelError) {
+		ll.logger.Error(msg, args...)
+	}
+}
+
+// newLogLevel returns a LevelVar which passes every message to the Logger, leaving the filtering to it
+func newLogLevel() *slog.LevelVar {
+	level := new(slog.LevelVar)
+	level.Set(slog.LevelDebug)
+
+	return level
+}
+
+// formatLogFields appends the key/value pairs to the message as key=value
+func formatLogFields(msg string, args []any) string {
+	if len(args) == 0 {
+		return msg
+	}
+
+	var b strings.Builder
+
+	b.WriteString(msg)
+
+	for i := 0; i < len(args); i += 2 {
+		if i+1 < len(args) {
+			fmt.Fprintf(&b, " %v=%v", args[i], args[i+1])
+		} else {
+			fmt.Fprintf(&b, " %v", args[i])
+		}
+	}
+
+	return b.String()
+}
+
+// connectionLogFields returns the structured fields describing a connection
+func connectionLogFields(connection *PRUDPConnection) []any {
+	if connection == nil {
+		return nil
+	}
+
+	fields := []any{
+		"connection_id", connection.ID,
+		"stream_id", connection.StreamID,
+		"pid", uint64(connection.PID()),
+	}
+
+	if connection.Socket != nil && connection.Socket.Address != nil {
+		fields = append(fields, "address", connection.Socket.Address.String())
+	}
+
+	return fields
+}
+
+// packetLogFields returns the structured fields describing a packet and the connection it belongs to
+func packetLogFields(packet PRUDPPacketInterface) []any {
+	var fields []any
+
+	if connection, ok := packet.Sender().(*PRUDPConnection); ok {
+		fields = connectionLogFields(connection)
+	}
+
+	fields = append(fields, "substream_id", packet.SubstreamID())
+
+	if message := packet.RMCMessage(); message != nil {
+		fields = append(fields, "call_id", message.CallID)
+	}
+
+	return fields
+}
+
+// hppPacketLogFields returns the structured fields describing an HPP request
+func hppPacketLogFields(packet *HPPPacket) []any {
+	fields := []any{
+		"address", packet.Sender().Address().String(),
+		"pid", uint64(packet.Sender().PID()),
+	}
+
+	if message := packet.RMCMessage(); message != nil {
+		fields = append(fields, "call_id", message.CallID)
+	}
+
+	return fields
+}
@@ -0,0 +1,54 @@
+package nex
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMain discards the logs of servers created by tests, so they don't write to ./log
+func TestMain(m *testing.M) {
+	defaultLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	os.Exit(m.Run())
+}
+
+func TestSlogLoggerReceivesStructuredFields(t *testing.T) {
+	output := new(bytes.Buffer)
+
+	server := NewPRUDPServer()
+	server.Logger = slog.New(slog.NewTextHandler(output, nil))
+
+	server.BindPRUDPEndPoint(NewPRUDPEndPoint(1))
+	server.BindPRUDPEndPoint(NewPRUDPEndPoint(1))
+
+	assert.Contains(t, output.String(), "level=WARN")
+	assert.Contains(t, output.String(), "stream_id=1")
+}
+
+func TestLogLevelFiltersMessages(t *testing.T) {
+	output := new(bytes.Buffer)
+
+	server := NewPRUDPServer()
+	server.Logger = slog.New(slog.NewTextHandler(output, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	// * Every message is passed to the Logger by default
+	server.log().Debug("first")
+	assert.Contains(t, output.String(), "msg=first")
+
+	server.LogLevel.Set(slog.LevelWarn)
+	server.log().Info("second")
+	server.log().Warn("third")
+
+	assert.NotContains(t, output.String(), "msg=second")
+	assert.Contains(t, output.String(), "msg=third")
+}
+
+func TestFormatLogFields(t *testing.T) {
+	assert.Equal(t, "message", formatLogFields("message", nil))
+	assert.Equal(t, "message connection_id=5 pid=10", formatLogFields("message", []any{"connection_id", 5, "pid", 10}))
+}
@@ -0,0 +1,164 @@
+package nex
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/PretendoNetwork/nex-go/v2/constants"
+	"github.com/PretendoNetwork/nex-go/v2/metrics"
+)
+
+// Metrics holds the instruments used to record server, endpoint and connection metrics.
+//
+// A single Metrics may be shared between a PRUDPServer and a HPPServer. Metrics implements
+// http.Handler and serves all metrics in the Prometheus text exposition format, so it can be
+// scraped without depending on any specific metrics library. Servers with no Metrics set
+// record nothing
+type Metrics struct {
+	Registry               *metrics.Registry
+	ActiveConnections      *metrics.GaugeVec     // * Labels: stream_id
+	PacketsReceived        *metrics.CounterVec   // * Labels: type, version
+	PacketsSent            *metrics.CounterVec   // * Labels: type, version
+	Retransmissions        *metrics.CounterVec   // * Labels: stream_id
+	TimeoutDrops           *metrics.CounterVec   // * Labels: stream_id
+	RTT                    *metrics.HistogramVec // * Labels: stream_id
+	RMCRequests            *metrics.CounterVec   // * Labels: transport, protocol_id, method_id
+	RMCResponses           *metrics.CounterVec   // * Labels: transport, protocol_id, method_id, result_code. Only recorded for PRUDP packets with an RMCMessage set
+	HPPRequestDuration     *metrics.HistogramVec // * Labels: status
+	FragmentReassemblySize *metrics.HistogramVec // * Labels: stream_id
+}
+
+// ServeHTTP serves all metrics in the Prometheus text exposition format
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.Registry.ServeHTTP(w, r)
+}
+
+func (m *Metrics) connectionOpened(endpoint *PRUDPEndPoint) {
+	if m == nil {
+		return
+	}
+
+	m.ActiveConnections.WithLabelValues(strconv.Itoa(int(endpoint.StreamID))).Inc()
+}
+
+func (m *Metrics) connectionClosed(endpoint *PRUDPEndPoint) {
+	if m == nil {
+		return
+	}
+
+	m.ActiveConnections.WithLabelValues(strconv.Itoa(int(endpoint.StreamID))).Dec()
+}
+
+func (m *Metrics) packetReceived(packet PRUDPPacketInterface) {
+	if m == nil {
+		return
+	}
+
+	m.PacketsReceived.WithLabelValues(packetTypeName(packet.Type()), strconv.Itoa(packet.Version())).Inc()
+}
+
+func (m *Metrics) packetSent(packet PRUDPPacketInterface) {
+	if m == nil {
+		return
+	}
+
+	m.PacketsSent.WithLabelValues(packetTypeName(packet.Type()), strconv.Itoa(packet.Version())).Inc()
+}
+
+func (m *Metrics) packetRetransmitted(endpoint *PRUDPEndPoint) {
+	if m == nil {
+		return
+	}
+
+	m.Retransmissions.WithLabelValues(strconv.Itoa(int(endpoint.StreamID))).Inc()
+}
+
+func (m *Metrics) connectionTimedOut(endpoint *PRUDPEndPoint) {
+	if m == nil {
+		return
+	}
+
+	m.TimeoutDrops.WithLabelValues(strconv.Itoa(int(endpoint.StreamID))).Inc()
+}
+
+func (m *Metrics) rttMeasured(endpoint *PRUDPEndPoint, rtt time.Duration) {
+	if m == nil {
+		return
+	}
+
+	m.RTT.WithLabelValues(strconv.Itoa(int(endpoint.StreamID))).Observe(rtt.Seconds())
+}
+
+func (m *Metrics) rmcRequest(transport string, message *RMCMessage) {
+	if m == nil || message == nil || !message.IsRequest {
+		return
+	}
+
+	m.RMCRequests.WithLabelValues(transport, strconv.Itoa(int(message.ProtocolID)), strconv.Itoa(int(message.MethodID))).Inc()
+}
+
+func (m *Metrics) rmcResponse(transport string, message *RMCMessage) {
+	if m == nil || message == nil || message.IsRequest {
+		return
+	}
+
+	resultCode := ResultCodes.Core.Unknown // * Success responses use Core::Unknown as their result
+	if !message.IsSuccess {
+		resultCode = message.ErrorCode
+	}
+
+	m.RMCResponses.WithLabelValues(transport, strconv.Itoa(int(message.ProtocolID)), strconv.Itoa(int(message.MethodID)), "0x"+strconv.FormatUint(uint64(resultCode), 16)).Inc()
+}
+
+func (m *Metrics) hppRequestHandled(status int, duration time.Duration) {
+	if m == nil {
+		return
+	}
+
+	m.HPPRequestDuration.WithLabelValues(strconv.Itoa(status)).Observe(duration.Seconds())
+}
+
+func (m *Metrics) fragmentsReassembled(endpoint *PRUDPEndPoint, size int) {
+	if m == nil {
+		return
+	}
+
+	m.FragmentReassemblySize.WithLabelValues(strconv.Itoa(int(endpoint.StreamID))).Observe(float64(size))
+}
+
+func packetTypeName(packetType uint16) string {
+	switch packetType {
+	case constants.SynPacket:
+		return "syn"
+	case constants.ConnectPacket:
+		return "connect"
+	case constants.DataPacket:
+		return "data"
+	case constants.DisconnectPacket:
+		return "disconnect"
+	case constants.PingPacket:
+		return "ping"
+	default:
+		return strconv.Itoa(int(packetType))
+	}
+}
+
+// NewMetrics returns a new Metrics with all instruments registered to a new Registry
+func NewMetrics() *Metrics {
+	registry := metrics.NewRegistry()
+
+	return &Metrics{
+		Registry:               registry,
+		ActiveConnections:      registry.NewGaugeVec("nex_prudp_active_connections", "Number of PRUDP connections currently held by an endpoint", "stream_id"),
+		PacketsReceived:        registry.NewCounterVec("nex_prudp_packets_received_total", "Number of PRUDP packets received", "type", "version"),
+		PacketsSent:            registry.NewCounterVec("nex_prudp_packets_sent_total", "Number of PRUDP packets sent, excluding retransmissions", "type", "version"),
+		Retransmissions:        registry.NewCounterVec("nex_prudp_retransmissions_total", "Number of reliable PRUDP packets retransmitted", "stream_id"),
+		TimeoutDrops:           registry.NewCounterVec("nex_prudp_timeout_drops_total", "Number of connections dropped after too many retransmissions", "stream_id"),
+		RTT:                    registry.NewHistogramVec("nex_prudp_rtt_seconds", "Measured PRUDP round trip times", metrics.DefaultDurationBuckets, "stream_id"),
+		RMCRequests:            registry.NewCounterVec("nex_rmc_requests_total", "Number of RMC requests received", "transport", "protocol_id", "method_id"),
+		RMCResponses:           registry.NewCounterVec("nex_rmc_responses_total", "Number of RMC responses sent", "transport", "protocol_id", "method_id", "result_code"),
+		HPPRequestDuration:     registry.NewHistogramVec("nex_hpp_request_duration_seconds", "Time taken to respond to HPP requests", metrics.DefaultDurationBuckets, "status"),
+		FragmentReassemblySize: registry.NewHistogramVec("nex_prudp_fragment_reassembly_bytes", "Size of reassembled reliable DATA payloads", metrics.DefaultSizeBuckets, "stream_id"),
+	}
+}
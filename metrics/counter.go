@@ -0,0 +1,129 @@
+package metrics
+
+import (
+	"bufio"
+	"math"
+	"sync/atomic"
+)
+
+// atomicFloat is a float64 which can be updated from multiple goroutines
+type atomicFloat struct {
+	bits atomic.Uint64
+}
+
+func (af *atomicFloat) add(delta float64) {
+	for {
+		old := af.bits.Load()
+		updated := math.Float64bits(math.Float64frombits(old) + delta)
+
+		if af.bits.CompareAndSwap(old, updated) {
+			return
+		}
+	}
+}
+
+func (af *atomicFloat) set(value float64) {
+	af.bits.Store(math.Float64bits(value))
+}
+
+func (af *atomicFloat) load() float64 {
+	return math.Float64frombits(af.bits.Load())
+}
+
+// Counter is a value which only ever increases
+type Counter struct {
+	value atomicFloat
+}
+
+// Inc increments the counter by 1
+func (c *Counter) Inc() {
+	c.value.add(1)
+}
+
+// Add increments the counter by the given value. Negative values are ignored
+func (c *Counter) Add(delta float64) {
+	if delta < 0 {
+		return
+	}
+
+	c.value.add(delta)
+}
+
+// Value returns the current value of the counter
+func (c *Counter) Value() float64 {
+	return c.value.load()
+}
+
+// CounterVec is a set of Counters sharing a name, partitioned by label values
+type CounterVec struct {
+	*family[*Counter]
+}
+
+// WithLabelValues returns the Counter for the given label values, creating it if needed
+func (cv *CounterVec) WithLabelValues(labelValues ...string) *Counter {
+	return cv.with(labelValues)
+}
+
+// DeleteLabelValues removes the Counter for the given label values
+func (cv *CounterVec) DeleteLabelValues(labelValues ...string) {
+	cv.remove(labelValues)
+}
+
+func (cv *CounterVec) writeTo(w *bufio.Writer) {
+	cv.writeHeader(w)
+	cv.each(func(labelValues []string, counter *Counter) {
+		writeSample(w, cv.metricName, cv.labelNames, labelValues, counter.Value())
+	})
+}
+
+// Gauge is a value which may increase or decrease
+type Gauge struct {
+	value atomicFloat
+}
+
+// Inc increments the gauge by 1
+func (g *Gauge) Inc() {
+	g.value.add(1)
+}
+
+// Dec decrements the gauge by 1
+func (g *Gauge) Dec() {
+	g.value.add(-1)
+}
+
+// Add adds the given value to the gauge
+func (g *Gauge) Add(delta float64) {
+	g.value.add(delta)
+}
+
+// Set sets the gauge to the given value
+func (g *Gauge) Set(value float64) {
+	g.value.set(value)
+}
+
+// Value returns the current value of the gauge
+func (g *Gauge) Value() float64 {
+	return g.value.load()
+}
+
+// GaugeVec is a set of Gauges sharing a name, partitioned by label values
+type GaugeVec struct {
+	*family[*Gauge]
+}
+
+// WithLabelValues returns the Gauge for the given label values, creating it if needed
+func (gv *GaugeVec) WithLabelValues(labelValues ...string) *Gauge {
+	return gv.with(labelValues)
+}
+
+// DeleteLabelValues removes the Gauge for the given label values
+func (gv *GaugeVec) DeleteLabelValues(labelValues ...string) {
+	gv.remove(labelValues)
+}
+
+func (gv *GaugeVec) writeTo(w *bufio.Writer) {
+	gv.writeHeader(w)
+	gv.each(func(labelValues []string, gauge *Gauge) {
+		writeSample(w, gv.metricName, gv.labelNames, labelValues, gauge.Value())
+	})
+}
@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"bufio"
+	"math"
+	"sort"
+	"sync/atomic"
+)
+
+// DefaultDurationBuckets are histogram upper bounds, in seconds, suited to network round trip and request times
+var DefaultDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// DefaultSizeBuckets are histogram upper bounds, in bytes, suited to PRUDP payload sizes
+var DefaultSizeBuckets = []float64{64, 256, 1024, 4096, 16384, 65536, 262144, 1048576}
+
+// Histogram counts observations into configurable buckets
+type Histogram struct {
+	upperBounds []float64
+	counts      []atomic.Uint64
+	count       atomic.Uint64
+	sum         atomicFloat
+}
+
+// Observe adds a single observation to the histogram
+func (h *Histogram) Observe(value float64) {
+	// * Buckets are stored non-cumulatively and summed
+	// * when rendered, so only one bucket is updated here
+	i := sort.SearchFloat64s(h.upperBounds, value)
+	if i < len(h.counts) {
+		h.counts[i].Add(1)
+	}
+
+	h.count.Add(1)
+	h.sum.add(value)
+}
+
+// Count returns the total number of observations
+func (h *Histogram) Count() uint64 {
+	return h.count.Load()
+}
+
+// Sum returns the sum of all observations
+func (h *Histogram) Sum() float64 {
+	return h.sum.load()
+}
+
+func newHistogram(upperBounds []float64) *Histogram {
+	return &Histogram{
+		upperBounds: upperBounds,
+		counts:      make([]atomic.Uint64, len(upperBounds)),
+	}
+}
+
+// HistogramVec is a set of Histograms sharing a name and buckets, partitioned by label values
+type HistogramVec struct {
+	*family[*Histogram]
+}
+
+// WithLabelValues returns the Histogram for the given label values, creating it if needed
+func (hv *HistogramVec) WithLabelValues(labelValues ...string) *Histogram {
+	return hv.with(labelValues)
+}
+
+// DeleteLabelValues removes the Histogram for the given label values
+func (hv *HistogramVec) DeleteLabelValues(labelValues ...string) {
+	hv.remove(labelValues)
+}
+
+func (hv *HistogramVec) writeTo(w *bufio.Writer) {
+	hv.writeHeader(w)
+
+	bucketLabelNames := append(append([]string(nil), hv.labelNames...), "le")
+
+	hv.each(func(labelValues []string, histogram *Histogram) {
+		bucketLabelValues := append(append([]string(nil), labelValues...), "")
+
+		var cumulative uint64
+		for i, upperBound := range histogram.upperBounds {
+			cumulative += histogram.counts[i].Load()
+			bucketLabelValues[len(bucketLabelValues)-1] = formatFloat(upperBound)
+			writeSample(w, hv.metricName+"_bucket", bucketLabelNames, bucketLabelValues, float64(cumulative))
+		}
+
+		count := histogram.Count()
+		bucketLabelValues[len(bucketLabelValues)-1] = formatFloat(math.Inf(1))
+		writeSample(w, hv.metricName+"_bucket", bucketLabelNames, bucketLabelValues, float64(count))
+		writeSample(w, hv.metricName+"_sum", hv.labelNames, labelValues, histogram.Sum())
+		writeSample(w, hv.metricName+"_count", hv.labelNames, labelValues, float64(count))
+	})
+}
@@ -0,0 +1,255 @@
+// Package metrics provides a small, dependency free, set of metric types
+// which can be exposed in the Prometheus text exposition format
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// collector defines all the methods a metric family should have to be rendered by a Registry
+type collector interface {
+	name() string
+	writeTo(w *bufio.Writer)
+}
+
+// Registry holds a set of metric families and renders them in the Prometheus text exposition format.
+// Registry implements http.Handler, so it may be mounted directly on an HTTP server
+type Registry struct {
+	sync.Mutex
+	collectors []collector
+}
+
+func (r *Registry) register(c collector) {
+	r.Lock()
+	defer r.Unlock()
+
+	for _, existing := range r.collectors {
+		if existing.name() == c.name() {
+			panic(fmt.Sprintf("metric %s is already registered", c.name()))
+		}
+	}
+
+	r.collectors = append(r.collectors, c)
+}
+
+// NewCounterVec registers and returns a new CounterVec with the given label names
+func (r *Registry) NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	cv := &CounterVec{family: newFamily[*Counter](name, help, "counter", labelNames, func() *Counter { return &Counter{} })}
+	r.register(cv)
+
+	return cv
+}
+
+// NewGaugeVec registers and returns a new GaugeVec with the given label names
+func (r *Registry) NewGaugeVec(name, help string, labelNames ...string) *GaugeVec {
+	gv := &GaugeVec{family: newFamily[*Gauge](name, help, "gauge", labelNames, func() *Gauge { return &Gauge{} })}
+	r.register(gv)
+
+	return gv
+}
+
+// NewHistogramVec registers and returns a new HistogramVec with the given upper bounds and label names
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	hv := &HistogramVec{family: newFamily[*Histogram](name, help, "histogram", labelNames, func() *Histogram { return newHistogram(buckets) })}
+	r.register(hv)
+
+	return hv
+}
+
+// WriteTo writes every registered metric to w in the Prometheus text exposition format
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.Lock()
+	collectors := append([]collector(nil), r.collectors...)
+	r.Unlock()
+
+	counter := &countingWriter{w: w}
+	buffered := bufio.NewWriter(counter)
+
+	for _, c := range collectors {
+		c.writeTo(buffered)
+	}
+
+	err := buffered.Flush()
+
+	return counter.n, err
+}
+
+// ServeHTTP serves the registered metrics in the Prometheus text exposition format
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = r.WriteTo(w)
+}
+
+// NewRegistry returns a new, empty, Registry
+func NewRegistry() *Registry {
+	return &Registry{
+		collectors: make([]collector, 0),
+	}
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+
+	return n, err
+}
+
+// family holds every labelled child of a single metric
+type family[T any] struct {
+	mutex      sync.RWMutex
+	metricName string
+	help       string
+	metricType string
+	labelNames []string
+	children   map[string]T
+	labels     map[string][]string
+	create     func() T
+}
+
+func (f *family[T]) name() string {
+	return f.metricName
+}
+
+func (f *family[T]) with(labelValues []string) T {
+	if len(labelValues) != len(f.labelNames) {
+		panic(fmt.Sprintf("metric %s expects %d label values, got %d", f.metricName, len(f.labelNames), len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+
+	f.mutex.RLock()
+	child, ok := f.children[key]
+	f.mutex.RUnlock()
+
+	if ok {
+		return child
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if child, ok := f.children[key]; ok {
+		return child
+	}
+
+	child = f.create()
+	f.children[key] = child
+	f.labels[key] = append([]string(nil), labelValues...)
+
+	return child
+}
+
+func (f *family[T]) remove(labelValues []string) {
+	key := strings.Join(labelValues, "\xff")
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	delete(f.children, key)
+	delete(f.labels, key)
+}
+
+// each calls callback for every child, sorted by label values so output is stable
+func (f *family[T]) each(callback func(labelValues []string, child T)) {
+	f.mutex.RLock()
+	keys := make([]string, 0, len(f.children))
+	for key := range f.children {
+		keys = append(keys, key)
+	}
+	f.mutex.RUnlock()
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		f.mutex.RLock()
+		child, ok := f.children[key]
+		labelValues := f.labels[key]
+		f.mutex.RUnlock()
+
+		if ok {
+			callback(labelValues, child)
+		}
+	}
+}
+
+func (f *family[T]) writeHeader(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", f.metricName, escapeHelp(f.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.metricName, f.metricType)
+}
+
+func newFamily[T any](name, help, metricType string, labelNames []string, create func() T) *family[T] {
+	return &family[T]{
+		metricName: name,
+		help:       help,
+		metricType: metricType,
+		labelNames: append([]string(nil), labelNames...),
+		children:   make(map[string]T),
+		labels:     make(map[string][]string),
+		create:     create,
+	}
+}
+
+func writeSample(w *bufio.Writer, name string, labelNames, labelValues []string, value float64) {
+	w.WriteString(name)
+
+	if len(labelNames) != 0 {
+		w.WriteByte('{')
+
+		for i, labelName := range labelNames {
+			if i != 0 {
+				w.WriteByte(',')
+			}
+
+			w.WriteString(labelName)
+			w.WriteString(`="`)
+			w.WriteString(escapeLabelValue(labelValues[i]))
+			w.WriteByte('"')
+		}
+
+		w.WriteByte('}')
+	}
+
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(value))
+	w.WriteByte('\n')
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+}
+
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
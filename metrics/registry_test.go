@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistryExposition(t *testing.T) {
+	registry := NewRegistry()
+
+	packets := registry.NewCounterVec("nex_packets_total", "Total packets", "direction")
+	connections := registry.NewGaugeVec("nex_connections", "Active connections")
+	rtt := registry.NewHistogramVec("nex_rtt_seconds", "Round trip time", []float64{0.1, 0.5}, "stream_id")
+
+	packets.WithLabelValues("in").Add(3)
+	packets.WithLabelValues("out\"").Inc()
+	connections.WithLabelValues().Set(2)
+	connections.WithLabelValues().Dec()
+	rtt.WithLabelValues("1").Observe(0.05)
+	rtt.WithLabelValues("1").Observe(0.3)
+	rtt.WithLabelValues("1").Observe(2)
+
+	var output strings.Builder
+	_, err := registry.WriteTo(&output)
+	assert.NoError(t, err)
+
+	expected := `# HELP nex_packets_total Total packets
+# TYPE nex_packets_total counter
+nex_packets_total{direction="in"} 3
+nex_packets_total{direction="out\""} 1
+# HELP nex_connections Active connections
+# TYPE nex_connections gauge
+nex_connections 1
+# HELP nex_rtt_seconds Round trip time
+# TYPE nex_rtt_seconds histogram
+nex_rtt_seconds_bucket{stream_id="1",le="0.1"} 1
+nex_rtt_seconds_bucket{stream_id="1",le="0.5"} 2
+nex_rtt_seconds_bucket{stream_id="1",le="+Inf"} 3
+nex_rtt_seconds_sum{stream_id="1"} 2.35
+nex_rtt_seconds_count{stream_id="1"} 3
+`
+
+	assert.Equal(t, expected, output.String())
+}
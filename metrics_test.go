@@ -0,0 +1,53 @@
+package nex
+
+import (
+	"testing"
+	"time"
+
+	"github.com/PretendoNetwork/nex-go/v2/constants"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsRecordServerActivity(t *testing.T) {
+	server, endpoint := newTestPRUDPServer(t)
+	server.Metrics = NewMetrics()
+	endpoint.DefaultStreamSettings.InitialRTT = 20
+	endpoint.DefaultStreamSettings.MaxPacketRetransmissions = 2
+
+	client := newTestPRUDPClient(t, endpoint)
+	connection := client.connect()
+
+	activeConnections := server.Metrics.ActiveConnections.WithLabelValues("1")
+	assert.Equal(t, float64(1), activeConnections.Value())
+	assert.Equal(t, float64(1), server.Metrics.PacketsReceived.WithLabelValues("syn", "1").Value())
+	assert.Equal(t, float64(1), server.Metrics.PacketsReceived.WithLabelValues("connect", "1").Value())
+	assert.Equal(t, float64(1), server.Metrics.PacketsSent.WithLabelValues("syn", "1").Value())
+	assert.Equal(t, float64(1), server.Metrics.PacketsSent.WithLabelValues("connect", "1").Value())
+
+	// * Reliable packets which are not acknowledged are retransmitted
+	endpoint.sendRMCMessage(connection, nil, []byte{1, 2, 3})
+
+	data := client.receive(constants.DataPacket)
+	retransmitted := client.receive(constants.DataPacket)
+	assert.Equal(t, data.SequenceID(), retransmitted.SequenceID())
+
+	client.acknowledge(retransmitted)
+
+	assert.Equal(t, float64(1), server.Metrics.PacketsSent.WithLabelValues("data", "1").Value())
+	assert.GreaterOrEqual(t, server.Metrics.Retransmissions.WithLabelValues("1").Value(), float64(1))
+	assert.Equal(t, float64(1), server.Metrics.PacketsReceived.WithLabelValues("data", "1").Value())
+
+	client.send(client.newPacket(constants.DisconnectPacket))
+
+	assert.Equal(t, float64(0), activeConnections.Value())
+
+	// * Connections which never acknowledge are dropped once the retransmissions run out
+	client = newTestPRUDPClient(t, endpoint)
+	endpoint.sendRMCMessage(client.connect(), nil, []byte{1, 2, 3})
+
+	assert.Eventually(t, func() bool {
+		return server.Metrics.TimeoutDrops.WithLabelValues("1").Value() == 1
+	}, 2*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, float64(0), activeConnections.Value())
+}
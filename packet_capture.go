@@ -0,0 +1,172 @@
+package nex
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PretendoNetwork/nex-go/v2/pcapng"
+)
+
+// sessionKeyCommentPrefix prefixes the packet comment used to record a connection's session key
+const sessionKeyCommentPrefix = "nex-session-key:"
+
+// PacketCapture records the raw datagrams sent and received by a PRUDPServer into a pcapng file.
+//
+// Datagrams are wrapped in IP and UDP headers built from the socket addresses so the capture can be opened by Wireshark.
+// WebSocket connections are recorded the same way, using the addresses of the underlying TCP connection
+type PacketCapture struct {
+	mutex              sync.Mutex
+	writer             *pcapng.Writer
+	localAddress       *net.UDPAddr
+	pendingSessionKeys map[string][]byte
+	IncludeSessionKeys bool // * If true, session keys are recorded as a comment on the CONNECT acknowledgement sent to the client
+}
+
+// LocalAddress returns the address the server is recorded as using in the capture
+func (pc *PacketCapture) LocalAddress() *net.UDPAddr {
+	return pc.localAddress
+}
+
+func (pc *PacketCapture) recordInbound(data []byte, address net.Addr) error {
+	if pc == nil {
+		return nil
+	}
+
+	return pc.record(data, captureAddress(address), pc.localAddress, pcapng.DirectionInbound)
+}
+
+func (pc *PacketCapture) recordOutbound(data []byte, address net.Addr) error {
+	if pc == nil {
+		return nil
+	}
+
+	return pc.record(data, pc.localAddress, captureAddress(address), pcapng.DirectionOutbound)
+}
+
+// recordSessionKey stores a session key to be attached to the next datagram sent to the address
+func (pc *PacketCapture) recordSessionKey(address net.Addr, sessionKey []byte) {
+	if pc == nil || !pc.IncludeSessionKeys {
+		return
+	}
+
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+
+	pc.pendingSessionKeys[address.String()] = sessionKey
+}
+
+func (pc *PacketCapture) record(data []byte, source, destination *net.UDPAddr, direction pcapng.Direction) error {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+
+	packet := pcapng.Packet{
+		Timestamp: time.Now(),
+		Direction: direction,
+		Data:      pcapng.EncodeUDP(source, destination, data),
+	}
+
+	if direction == pcapng.DirectionOutbound {
+		if sessionKey, ok := pc.pendingSessionKeys[destination.String()]; ok {
+			packet.Comments = []string{sessionKeyCommentPrefix + hex.EncodeToString(sessionKey)}
+			delete(pc.pendingSessionKeys, destination.String())
+		}
+	}
+
+	return pc.writer.WritePacket(packet)
+}
+
+// captureAddress converts a socket address into a UDP address for the capture
+func captureAddress(address net.Addr) *net.UDPAddr {
+	switch address := address.(type) {
+	case *net.UDPAddr:
+		return address
+	case *net.TCPAddr:
+		return &net.UDPAddr{IP: address.IP, Port: address.Port, Zone: address.Zone}
+	}
+
+	// * Fallback for address types without a known layout
+	if udpAddress, err := net.ResolveUDPAddr("udp", address.String()); err == nil {
+		return udpAddress
+	}
+
+	return &net.UDPAddr{IP: net.IPv4zero}
+}
+
+// CapturedSessionKey returns the session key recorded in a packet read from a capture, if any
+func CapturedSessionKey(packet pcapng.Packet) ([]byte, bool) {
+	for _, comment := range packet.Comments {
+		if encoded, ok := strings.CutPrefix(comment, sessionKeyCommentPrefix); ok {
+			sessionKey, err := hex.DecodeString(encoded)
+			if err != nil {
+				return nil, false
+			}
+
+			return sessionKey, true
+		}
+	}
+
+	return nil, false
+}
+
+// ReplayCapture feeds the inbound datagrams of a pcapng capture back through the servers packet pipeline,
+// in the order they were recorded, as if they had been read from the socket.
+//
+// If realtime is true, the original delay between datagrams is preserved.
+// Any responses are sent using the servers sockets, if it is listening, so replays should generally be run
+// against a server which is not. To reproduce PRUDPv1 connections, PRUDPv1ConnectionSignatureKey, or the
+// ConnectionSignatureKeyRing, must be set to the one used by the server which made the capture
+func (ps *PRUDPServer) ReplayCapture(r io.Reader, realtime bool) error {
+	reader := pcapng.NewReader(r)
+
+	var lastTimestamp time.Time
+
+	for {
+		packet, linkType, err := reader.ReadPacket()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if linkType != pcapng.LinkTypeRaw || packet.Direction != pcapng.DirectionInbound {
+			continue
+		}
+
+		source, _, payload, err := pcapng.DecodeUDP(packet.Data)
+		if err != nil {
+			return fmt.Errorf("Failed to decode captured datagram. %s", err.Error())
+		}
+
+		if realtime && !lastTimestamp.IsZero() {
+			time.Sleep(packet.Timestamp.Sub(lastTimestamp))
+		}
+
+		lastTimestamp = packet.Timestamp
+
+		// * Errors are already logged by the pipeline, a single bad or truncated datagram should not stop the replay
+		_ = ps.handleSocketMessage(payload, source, nil)
+	}
+}
+
+// NewPacketCapture writes the pcapng headers to w and returns a new PacketCapture.
+// The local address is used as the servers address in the captured datagrams
+func NewPacketCapture(w io.Writer, localAddress *net.UDPAddr) (*PacketCapture, error) {
+	writer, err := pcapng.NewWriter(w, pcapng.LinkTypeRaw)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PacketCapture{
+		writer:             writer,
+		localAddress:       localAddress,
+		pendingSessionKeys: make(map[string][]byte),
+	}, nil
+}
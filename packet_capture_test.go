@@ -0,0 +1,34 @@
+package nex
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/PretendoNetwork/nex-go/v2/constants"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplayCaptureSkipsTruncatedDatagrams(t *testing.T) {
+	server, endpoint := newTestPRUDPServer(t)
+	client := newTestPRUDPClient(t, endpoint)
+
+	syn := client.newPacket(constants.SynPacket, constants.PacketFlagNeedsAck)
+	syn.setSignature(make([]byte, 16))
+
+	buffer := new(bytes.Buffer)
+	capture, err := NewPacketCapture(buffer, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 60000})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// * Datagrams too short to hold a header are reported, and the ones after them still replayed
+	for _, datagram := range [][]byte{{}, {0xEA}, syn.Bytes()} {
+		assert.NoError(t, capture.recordInbound(datagram, client.address))
+	}
+
+	assert.Error(t, server.handleSocketMessage([]byte{0xEA}, client.address, nil))
+	assert.NoError(t, server.ReplayCapture(buffer, false))
+
+	client.receive(constants.SynPacket)
+}
@@ -1,5 +1,7 @@
 package nex
 
+import "context"
+
 // PacketInterface defines all the methods a packet for both PRUDP and HPP should have
 type PacketInterface interface {
 	Sender() ConnectionInterface
@@ -8,3 +10,19 @@ type PacketInterface interface {
 	RMCMessage() *RMCMessage
 	SetRMCMessage(message *RMCMessage)
 }
+
+// ContextPacket is implemented by packets which carry the context of the request they belong to.
+// Both PRUDP and HPP packets implement it. Use PacketContext to get the context of any packet
+type ContextPacket interface {
+	PacketInterface
+	Context() context.Context
+}
+
+// PacketContext returns the context of the packet if it implements ContextPacket, otherwise context.Background
+func PacketContext(packet PacketInterface) context.Context {
+	if packet, ok := packet.(ContextPacket); ok {
+		return packet.Context()
+	}
+
+	return context.Background()
+}
@@ -0,0 +1,141 @@
+package pcapng
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// PacketReader is implemented by Reader and PcapReader
+type PacketReader interface {
+	// ReadPacket returns the next packet and the link type of the interface it was captured on.
+	// Returns io.EOF once there are no more packets
+	ReadPacket() (Packet, uint16, error)
+}
+
+// PcapReader reads packets from a classic pcap file, as written by tcpdump and older versions of Wireshark
+type PcapReader struct {
+	r          io.Reader
+	byteOrder  binary.ByteOrder
+	resolution time.Duration
+	linkType   uint16
+	headerRead bool
+}
+
+// ReadPacket returns the next packet in the file. Classic pcap files do not store directions,
+// so Direction is always DirectionUnknown. Returns io.EOF once there are no more packets
+func (pr *PcapReader) ReadPacket() (Packet, uint16, error) {
+	if !pr.headerRead {
+		if err := pr.readHeader(); err != nil {
+			return Packet{}, 0, err
+		}
+
+		pr.headerRead = true
+	}
+
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(pr.r, header); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return Packet{}, 0, fmt.Errorf("truncated pcap record header: %w", err)
+		}
+
+		return Packet{}, 0, err
+	}
+
+	seconds := pr.byteOrder.Uint32(header[0:4])
+	fraction := pr.byteOrder.Uint32(header[4:8])
+	capturedLength := pr.byteOrder.Uint32(header[8:12])
+
+	data := make([]byte, capturedLength)
+	if _, err := io.ReadFull(pr.r, data); err != nil {
+		return Packet{}, 0, fmt.Errorf("truncated pcap record: %w", err)
+	}
+
+	return Packet{
+		Timestamp: time.Unix(int64(seconds), 0).Add(time.Duration(fraction) * pr.resolution),
+		Data:      data,
+	}, pr.linkType, nil
+}
+
+func (pr *PcapReader) readHeader() error {
+	header := make([]byte, 24)
+	if _, err := io.ReadFull(pr.r, header); err != nil {
+		return fmt.Errorf("truncated pcap header: %w", err)
+	}
+
+	pr.byteOrder = binary.LittleEndian
+	pr.resolution = time.Microsecond
+
+	switch binary.LittleEndian.Uint32(header[0:4]) {
+	case pcapMagicMicroseconds:
+	case pcapMagicNanoseconds:
+		pr.resolution = time.Nanosecond
+	default:
+		pr.byteOrder = binary.BigEndian
+
+		switch binary.BigEndian.Uint32(header[0:4]) {
+		case pcapMagicMicroseconds:
+		case pcapMagicNanoseconds:
+			pr.resolution = time.Nanosecond
+		default:
+			return errors.New("invalid pcap magic")
+		}
+	}
+
+	pr.linkType = uint16(pr.byteOrder.Uint32(header[20:24]))
+
+	return nil
+}
+
+// NewPcapReader returns a new PcapReader reading from r
+func NewPcapReader(r io.Reader) *PcapReader {
+	return &PcapReader{r: r}
+}
+
+const (
+	pcapMagicMicroseconds uint32 = 0xA1B2C3D4
+	pcapMagicNanoseconds  uint32 = 0xA1B23C4D
+)
+
+// IsCaptureMagic reports whether the first 4 bytes of a file are the magic of a pcapng or classic pcap file
+func IsCaptureMagic(magic []byte) bool {
+	if len(magic) < 4 {
+		return false
+	}
+
+	if binary.LittleEndian.Uint32(magic) == blockTypeSectionHeader {
+		return true
+	}
+
+	for _, byteOrder := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		switch byteOrder.Uint32(magic) {
+		case pcapMagicMicroseconds, pcapMagicNanoseconds:
+			return true
+		}
+	}
+
+	return false
+}
+
+// NewCaptureReader returns a Reader or a PcapReader reading from r, depending on the magic at the start of the file
+func NewCaptureReader(r io.Reader) (PacketReader, error) {
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return nil, fmt.Errorf("truncated capture magic: %w", err)
+	}
+
+	if !IsCaptureMagic(magic) {
+		return nil, errors.New("invalid capture magic")
+	}
+
+	r = io.MultiReader(bytes.NewReader(magic), r)
+
+	if binary.LittleEndian.Uint32(magic) == blockTypeSectionHeader {
+		return NewReader(r), nil
+	}
+
+	return NewPcapReader(r), nil
+}
@@ -0,0 +1,158 @@
+package pcapng
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriterReaderRoundTrip(t *testing.T) {
+	buffer := new(bytes.Buffer)
+
+	writer, err := NewWriter(buffer, LinkTypeRaw)
+	assert.NoError(t, err)
+
+	timestamp := time.UnixMicro(1700000000123456)
+	packet := Packet{
+		Timestamp: timestamp,
+		Direction: DirectionOutbound,
+		Data:      []byte{1, 2, 3, 4, 5},
+		Comments:  []string{"hello"},
+	}
+
+	assert.NoError(t, writer.WritePacket(packet))
+
+	reader := NewReader(buffer)
+
+	read, linkType, err := reader.ReadPacket()
+	assert.NoError(t, err)
+	assert.Equal(t, LinkTypeRaw, linkType)
+	assert.True(t, timestamp.Equal(read.Timestamp))
+	assert.Equal(t, DirectionOutbound, read.Direction)
+	assert.Equal(t, packet.Data, read.Data)
+	assert.Equal(t, packet.Comments, read.Comments)
+
+	_, _, err = reader.ReadPacket()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestEncodeDecodeUDP(t *testing.T) {
+	payload := []byte{0xEA, 0xD0, 0x01}
+
+	for _, addresses := range [][2]*net.UDPAddr{
+		{{IP: net.IPv4(10, 0, 0, 1), Port: 60000}, {IP: net.IPv4(192, 168, 1, 2), Port: 12345}},
+		{{IP: net.ParseIP("2001:db8::1"), Port: 60000}, {IP: net.ParseIP("2001:db8::2"), Port: 12345}},
+	} {
+		source, destination, decoded, err := DecodeUDP(EncodeUDP(addresses[0], addresses[1], payload))
+		assert.NoError(t, err)
+		assert.True(t, addresses[0].IP.Equal(source.IP))
+		assert.Equal(t, addresses[0].Port, source.Port)
+		assert.True(t, addresses[1].IP.Equal(destination.IP))
+		assert.Equal(t, addresses[1].Port, destination.Port)
+		assert.Equal(t, payload, decoded)
+	}
+}
+
+// newTestPcap returns a classic pcap file holding a single packet
+func newTestPcap(byteOrder binary.AppendByteOrder, magic uint32, data []byte) []byte {
+	file := byteOrder.AppendUint32(nil, magic)
+	file = byteOrder.AppendUint16(file, 2)
+	file = byteOrder.AppendUint16(file, 4)
+	file = append(file, make([]byte, 12)...) // * Timezone, accuracy and snap length
+	file = byteOrder.AppendUint32(file, uint32(LinkTypeRaw))
+
+	file = byteOrder.AppendUint32(file, 1700000000)
+	file = byteOrder.AppendUint32(file, 123456)
+	file = byteOrder.AppendUint32(file, uint32(len(data)))
+	file = byteOrder.AppendUint32(file, uint32(len(data)))
+
+	return append(file, data...)
+}
+
+func TestPcapReader(t *testing.T) {
+	data := []byte{1, 2, 3, 4, 5}
+
+	for _, byteOrder := range []binary.AppendByteOrder{binary.LittleEndian, binary.BigEndian} {
+		reader, err := NewCaptureReader(bytes.NewReader(newTestPcap(byteOrder, pcapMagicMicroseconds, data)))
+		if !assert.NoError(t, err) {
+			continue
+		}
+
+		assert.IsType(t, &PcapReader{}, reader)
+
+		packet, linkType, err := reader.ReadPacket()
+		assert.NoError(t, err)
+		assert.Equal(t, LinkTypeRaw, linkType)
+		assert.Equal(t, data, packet.Data)
+		assert.True(t, time.UnixMicro(1700000000123456).Equal(packet.Timestamp))
+
+		_, _, err = reader.ReadPacket()
+		assert.ErrorIs(t, err, io.EOF)
+	}
+
+	reader, _ := NewCaptureReader(bytes.NewReader(newTestPcap(binary.LittleEndian, pcapMagicNanoseconds, data)))
+	packet, _, _ := reader.ReadPacket()
+	assert.True(t, time.Unix(1700000000, 123456).Equal(packet.Timestamp))
+
+	// * Truncated records are errors, not EOF
+	truncated := newTestPcap(binary.LittleEndian, pcapMagicMicroseconds, data)
+	reader, _ = NewCaptureReader(bytes.NewReader(truncated[:len(truncated)-1]))
+	_, _, err := reader.ReadPacket()
+	assert.ErrorContains(t, err, "truncated pcap record")
+}
+
+func TestNewCaptureReader(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	_, _ = NewWriter(buffer, LinkTypeRaw)
+
+	reader, err := NewCaptureReader(buffer)
+	assert.NoError(t, err)
+	assert.IsType(t, &Reader{}, reader)
+
+	_, err = NewCaptureReader(bytes.NewReader([]byte{0xD4, 0xC3}))
+	assert.ErrorContains(t, err, "truncated capture magic")
+
+	_, err = NewCaptureReader(bytes.NewReader([]byte{1, 2, 3, 4, 5}))
+	assert.ErrorContains(t, err, "invalid capture magic")
+
+	assert.False(t, IsCaptureMagic([]byte{0xD4}))
+}
+
+// newTestBlock returns a little endian pcapng block with the given body, which must be padded to 4 bytes
+func newTestBlock(blockType uint32, body []byte) []byte {
+	length := uint32(12 + len(body))
+
+	block := binary.LittleEndian.AppendUint32(nil, blockType)
+	block = binary.LittleEndian.AppendUint32(block, length)
+	block = append(block, body...)
+
+	return binary.LittleEndian.AppendUint32(block, length)
+}
+
+func TestReaderRejectsMalformedBlocks(t *testing.T) {
+	sectionHeader := binary.LittleEndian.AppendUint32(nil, byteOrderMagic)
+	sectionHeader = binary.LittleEndian.AppendUint16(sectionHeader, 1)
+	sectionHeader = binary.LittleEndian.AppendUint16(sectionHeader, 0)
+	sectionHeader = binary.LittleEndian.AppendUint64(sectionHeader, 0xFFFFFFFFFFFFFFFF)
+
+	// * A 16 byte section header only has room for the byte order magic
+	_, _, err := NewReader(bytes.NewReader(newTestBlock(blockTypeSectionHeader, sectionHeader[:4]))).ReadPacket()
+	assert.ErrorContains(t, err, "invalid pcapng section header length 16")
+
+	// * Timestamp resolutions finer than a nanosecond
+	for _, resolution := range []byte{10, 100, 0x80 | 30, 0x80 | 64} {
+		interfaceDescription := binary.LittleEndian.AppendUint16(nil, LinkTypeRaw)
+		interfaceDescription = append(interfaceDescription, 0, 0, 0, 0, 0, 0)
+		interfaceDescription = append(interfaceDescription, 9, 0, 1, 0, resolution, 0, 0, 0, 0, 0, 0, 0)
+
+		file := append(newTestBlock(blockTypeSectionHeader, sectionHeader), newTestBlock(blockTypeInterfaceDescription, interfaceDescription)...)
+
+		_, _, err := NewReader(bytes.NewReader(file)).ReadPacket()
+		assert.ErrorContains(t, err, "unsupported pcapng timestamp resolution")
+	}
+}
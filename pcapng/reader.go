@@ -0,0 +1,238 @@
+package pcapng
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Reader reads packets from a pcapng file
+type Reader struct {
+	r          io.Reader
+	byteOrder  binary.ByteOrder
+	interfaces []readerInterface
+}
+
+// readerInterface holds the settings of an interface described in the file
+type readerInterface struct {
+	linkType   uint16
+	resolution time.Duration
+}
+
+// ReadPacket returns the next packet in the file. Blocks other than packets are skipped.
+// Returns io.EOF once there are no more packets
+func (pr *Reader) ReadPacket() (Packet, uint16, error) {
+	for {
+		blockType, body, err := pr.readBlock()
+		if err != nil {
+			return Packet{}, 0, err
+		}
+
+		switch blockType {
+		case blockTypeSectionHeader:
+			if err := pr.readSectionHeader(body); err != nil {
+				return Packet{}, 0, err
+			}
+		case blockTypeInterfaceDescription:
+			if err := pr.readInterfaceDescription(body); err != nil {
+				return Packet{}, 0, err
+			}
+		case blockTypeEnhancedPacket:
+			return pr.readEnhancedPacket(body)
+		}
+	}
+}
+
+func (pr *Reader) readBlock() (uint32, []byte, error) {
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(pr.r, header); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, nil, fmt.Errorf("truncated pcapng block header: %w", err)
+		}
+
+		return 0, nil, err
+	}
+
+	// * The byte order magic in a section header decides the
+	// * byte order of every block in the section, including
+	// * the section header itself, so peek at it first
+	if binary.LittleEndian.Uint32(header[0:4]) == blockTypeSectionHeader {
+		magic := make([]byte, 4)
+		if _, err := io.ReadFull(pr.r, magic); err != nil {
+			return 0, nil, fmt.Errorf("truncated pcapng section header: %w", err)
+		}
+
+		if binary.LittleEndian.Uint32(magic) == byteOrderMagic {
+			pr.byteOrder = binary.LittleEndian
+		} else if binary.BigEndian.Uint32(magic) == byteOrderMagic {
+			pr.byteOrder = binary.BigEndian
+		} else {
+			return 0, nil, errors.New("invalid pcapng byte order magic")
+		}
+
+		// * A section header is at least 28 bytes, the byte order
+		// * magic, version and section length between the two
+		// * block lengths
+		length := pr.byteOrder.Uint32(header[4:8])
+		if length < 28 || length%4 != 0 {
+			return 0, nil, fmt.Errorf("invalid pcapng section header length %d", length)
+		}
+
+		body := make([]byte, length-12)
+		copy(body, magic)
+
+		if _, err := io.ReadFull(pr.r, body[4:]); err != nil {
+			return 0, nil, fmt.Errorf("truncated pcapng section header: %w", err)
+		}
+
+		if _, err := io.ReadFull(pr.r, make([]byte, 4)); err != nil {
+			return 0, nil, fmt.Errorf("truncated pcapng section header: %w", err)
+		}
+
+		return blockTypeSectionHeader, body, nil
+	}
+
+	if pr.byteOrder == nil {
+		return 0, nil, errors.New("pcapng file does not start with a section header")
+	}
+
+	blockType := pr.byteOrder.Uint32(header[0:4])
+	length := pr.byteOrder.Uint32(header[4:8])
+	if length < 12 || length%4 != 0 {
+		return 0, nil, fmt.Errorf("invalid pcapng block length %d", length)
+	}
+
+	body := make([]byte, length-8)
+	if _, err := io.ReadFull(pr.r, body); err != nil {
+		return 0, nil, fmt.Errorf("truncated pcapng block: %w", err)
+	}
+
+	// * Drop the trailing block length
+	return blockType, body[:len(body)-4], nil
+}
+
+func (pr *Reader) readSectionHeader(body []byte) error {
+	if len(body) < 6 {
+		return errors.New("truncated pcapng section header")
+	}
+
+	if pr.byteOrder.Uint16(body[4:6]) != 1 {
+		return fmt.Errorf("unsupported pcapng major version %d", pr.byteOrder.Uint16(body[4:6]))
+	}
+
+	// * Interfaces are scoped to a section
+	pr.interfaces = pr.interfaces[:0]
+
+	return nil
+}
+
+func (pr *Reader) readInterfaceDescription(body []byte) error {
+	if len(body) < 8 {
+		return errors.New("truncated pcapng interface description")
+	}
+
+	iface := readerInterface{
+		linkType:   pr.byteOrder.Uint16(body[0:2]),
+		resolution: time.Microsecond,
+	}
+
+	options := pr.readOptions(body[8:])
+	if value, ok := options[9]; ok && len(value[0]) == 1 { // * if_tsresol
+		resolution := value[0][0]
+		if resolution&0x80 != 0 {
+			// * Negative power of 2. Anything past 2^-29 is finer than a nanosecond
+			if resolution&0x7F > 29 {
+				return fmt.Errorf("unsupported pcapng timestamp resolution 2^-%d", resolution&0x7F)
+			}
+
+			iface.resolution = time.Second / time.Duration(uint64(1)<<(resolution&0x7F))
+		} else {
+			// * Negative power of 10. Anything past 10^-9 is finer than a nanosecond
+			if resolution > 9 {
+				return fmt.Errorf("unsupported pcapng timestamp resolution 10^-%d", resolution)
+			}
+
+			divisor := time.Duration(1)
+			for i := uint8(0); i < resolution; i++ {
+				divisor *= 10
+			}
+
+			iface.resolution = time.Second / divisor
+		}
+	}
+
+	pr.interfaces = append(pr.interfaces, iface)
+
+	return nil
+}
+
+func (pr *Reader) readEnhancedPacket(body []byte) (Packet, uint16, error) {
+	if len(body) < 20 {
+		return Packet{}, 0, errors.New("truncated pcapng enhanced packet")
+	}
+
+	interfaceID := pr.byteOrder.Uint32(body[0:4])
+	if int(interfaceID) >= len(pr.interfaces) {
+		return Packet{}, 0, fmt.Errorf("pcapng packet references unknown interface %d", interfaceID)
+	}
+
+	iface := pr.interfaces[interfaceID]
+
+	timestamp := uint64(pr.byteOrder.Uint32(body[4:8]))<<32 | uint64(pr.byteOrder.Uint32(body[8:12]))
+	capturedLength := int(pr.byteOrder.Uint32(body[12:16]))
+	if 20+capturedLength > len(body) {
+		return Packet{}, 0, errors.New("pcapng packet data exceeds block length")
+	}
+
+	packet := Packet{
+		Timestamp: time.Unix(0, 0).Add(time.Duration(timestamp) * iface.resolution),
+		Data:      append([]byte(nil), body[20:20+capturedLength]...),
+	}
+
+	optionsOffset := 20 + capturedLength
+	optionsOffset += (4 - optionsOffset%4) % 4
+
+	if optionsOffset < len(body) {
+		options := pr.readOptions(body[optionsOffset:])
+
+		if flags, ok := options[optionEPBFlags]; ok && len(flags[0]) == 4 {
+			packet.Direction = Direction(pr.byteOrder.Uint32(flags[0]) & 0x3)
+		}
+
+		for _, comment := range options[optionComment] {
+			packet.Comments = append(packet.Comments, string(comment))
+		}
+	}
+
+	return packet, iface.linkType, nil
+}
+
+func (pr *Reader) readOptions(data []byte) map[uint16][][]byte {
+	options := make(map[uint16][][]byte)
+
+	for len(data) >= 4 {
+		code := pr.byteOrder.Uint16(data[0:2])
+		length := int(pr.byteOrder.Uint16(data[2:4]))
+
+		if code == optionEndOfOptions || 4+length > len(data) {
+			break
+		}
+
+		options[code] = append(options[code], data[4:4+length])
+
+		data = data[4+length:]
+		data = data[min((4-length%4)%4, len(data)):]
+	}
+
+	return options
+}
+
+// NewReader returns a new Reader reading from r
+func NewReader(r io.Reader) *Reader {
+	return &Reader{
+		r:          r,
+		interfaces: make([]readerInterface, 0),
+	}
+}
@@ -0,0 +1,151 @@
+package pcapng
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+)
+
+const protocolUDP = 17
+
+// EncodeUDP wraps a UDP payload in IP and UDP headers so it can be written to a LinkTypeRaw capture.
+// An IPv6 header is used if either address is not an IPv4 address
+func EncodeUDP(source, destination *net.UDPAddr, payload []byte) []byte {
+	sourceIP := source.IP.To4()
+	destinationIP := destination.IP.To4()
+
+	udp := make([]byte, 8, 8+len(payload))
+	binary.BigEndian.PutUint16(udp[0:2], uint16(source.Port))
+	binary.BigEndian.PutUint16(udp[2:4], uint16(destination.Port))
+	binary.BigEndian.PutUint16(udp[4:6], uint16(8+len(payload)))
+	udp = append(udp, payload...)
+
+	if sourceIP != nil && destinationIP != nil {
+		header := make([]byte, 20)
+		header[0] = 0x45 // * Version 4, 5 word header
+		binary.BigEndian.PutUint16(header[2:4], uint16(20+len(udp)))
+		header[8] = 64 // * TTL
+		header[9] = protocolUDP
+		copy(header[12:16], sourceIP)
+		copy(header[16:20], destinationIP)
+		binary.BigEndian.PutUint16(header[10:12], checksum(header, 0))
+
+		// * The UDP checksum is optional over IPv4, leave it as 0
+		return append(header, udp...)
+	}
+
+	sourceIP = source.IP.To16()
+	destinationIP = destination.IP.To16()
+
+	header := make([]byte, 40)
+	header[0] = 0x60 // * Version 6
+	binary.BigEndian.PutUint16(header[4:6], uint16(len(udp)))
+	header[6] = protocolUDP
+	header[7] = 64 // * Hop limit
+	copy(header[8:24], sourceIP)
+	copy(header[24:40], destinationIP)
+
+	// * The UDP checksum is mandatory over IPv6 and covers a pseudo header
+	pseudoHeader := make([]byte, 0, 40)
+	pseudoHeader = append(pseudoHeader, sourceIP...)
+	pseudoHeader = append(pseudoHeader, destinationIP...)
+	pseudoHeader = binary.BigEndian.AppendUint32(pseudoHeader, uint32(len(udp)))
+	pseudoHeader = binary.BigEndian.AppendUint32(pseudoHeader, protocolUDP)
+
+	sum := checksum(udp, checksumPartial(pseudoHeader, 0))
+	if sum == 0 {
+		sum = 0xFFFF
+	}
+
+	binary.BigEndian.PutUint16(udp[6:8], sum)
+
+	return append(header, udp...)
+}
+
+// DecodeUDP strips the IP and UDP headers from a LinkTypeRaw packet,
+// returning the source and destination addresses and the UDP payload
+func DecodeUDP(data []byte) (*net.UDPAddr, *net.UDPAddr, []byte, error) {
+	if len(data) < 1 {
+		return nil, nil, nil, errors.New("empty IP packet")
+	}
+
+	var sourceIP, destinationIP net.IP
+	var udp []byte
+
+	switch data[0] >> 4 {
+	case 4:
+		if len(data) < 20 {
+			return nil, nil, nil, errors.New("truncated IPv4 header")
+		}
+
+		headerLength := int(data[0]&0x0F) * 4
+		totalLength := int(binary.BigEndian.Uint16(data[2:4]))
+		if headerLength < 20 || totalLength < headerLength || totalLength > len(data) {
+			return nil, nil, nil, errors.New("invalid IPv4 header length")
+		}
+
+		if data[9] != protocolUDP {
+			return nil, nil, nil, errors.New("IPv4 packet is not UDP")
+		}
+
+		sourceIP = net.IP(append([]byte(nil), data[12:16]...))
+		destinationIP = net.IP(append([]byte(nil), data[16:20]...))
+		udp = data[headerLength:totalLength]
+	case 6:
+		if len(data) < 40 {
+			return nil, nil, nil, errors.New("truncated IPv6 header")
+		}
+
+		// * Extension headers are not supported, they are never written by EncodeUDP
+		if data[6] != protocolUDP {
+			return nil, nil, nil, errors.New("IPv6 packet is not UDP")
+		}
+
+		payloadLength := int(binary.BigEndian.Uint16(data[4:6]))
+		if 40+payloadLength > len(data) {
+			return nil, nil, nil, errors.New("invalid IPv6 payload length")
+		}
+
+		sourceIP = net.IP(append([]byte(nil), data[8:24]...))
+		destinationIP = net.IP(append([]byte(nil), data[24:40]...))
+		udp = data[40 : 40+payloadLength]
+	default:
+		return nil, nil, nil, errors.New("unknown IP version")
+	}
+
+	if len(udp) < 8 {
+		return nil, nil, nil, errors.New("truncated UDP header")
+	}
+
+	udpLength := int(binary.BigEndian.Uint16(udp[4:6]))
+	if udpLength < 8 || udpLength > len(udp) {
+		return nil, nil, nil, errors.New("invalid UDP length")
+	}
+
+	source := &net.UDPAddr{IP: sourceIP, Port: int(binary.BigEndian.Uint16(udp[0:2]))}
+	destination := &net.UDPAddr{IP: destinationIP, Port: int(binary.BigEndian.Uint16(udp[2:4]))}
+
+	return source, destination, udp[8:udpLength], nil
+}
+
+func checksumPartial(data []byte, sum uint32) uint32 {
+	for i := 0; i+1 < len(data); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(data[i : i+2]))
+	}
+
+	if len(data)%2 == 1 {
+		sum += uint32(data[len(data)-1]) << 8
+	}
+
+	return sum
+}
+
+func checksum(data []byte, sum uint32) uint16 {
+	sum = checksumPartial(data, sum)
+
+	for sum>>16 != 0 {
+		sum = (sum & 0xFFFF) + (sum >> 16)
+	}
+
+	return ^uint16(sum)
+}
@@ -0,0 +1,144 @@
+// Package pcapng implements a minimal reader and writer for the pcapng capture file format,
+// enough to record and replay raw IP datagrams in files which can be opened by Wireshark.
+// Classic pcap files can also be read, with PcapReader
+package pcapng
+
+import (
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+const (
+	blockTypeSectionHeader        uint32 = 0x0A0D0D0A
+	blockTypeInterfaceDescription uint32 = 0x00000001
+	blockTypeEnhancedPacket       uint32 = 0x00000006
+	byteOrderMagic                uint32 = 0x1A2B3C4D
+
+	optionEndOfOptions uint16 = 0
+	optionComment      uint16 = 1
+	optionEPBFlags     uint16 = 2
+)
+
+// LinkTypeRaw is the link type for packets which begin directly with an IPv4 or IPv6 header
+const LinkTypeRaw uint16 = 101
+
+// Direction is the direction a packet was travelling, stored in the epb_flags option
+type Direction uint32
+
+const (
+	// DirectionUnknown indicates the direction of the packet is not known
+	DirectionUnknown Direction = 0
+
+	// DirectionInbound indicates the packet was received
+	DirectionInbound Direction = 1
+
+	// DirectionOutbound indicates the packet was sent
+	DirectionOutbound Direction = 2
+)
+
+// Packet is a single captured packet
+type Packet struct {
+	Timestamp time.Time
+	Direction Direction
+	Data      []byte
+	Comments  []string
+}
+
+// Writer writes packets to a pcapng file with a single section and a single interface
+type Writer struct {
+	w        io.Writer
+	linkType uint16
+}
+
+// WritePacket writes a packet as an Enhanced Packet Block
+func (pw *Writer) WritePacket(packet Packet) error {
+	timestamp := uint64(packet.Timestamp.UnixMicro()) // * The default timestamp resolution is microseconds
+
+	body := make([]byte, 20, 20+len(packet.Data)+16)
+	binary.LittleEndian.PutUint32(body[0:4], 0) // * Interface ID
+	binary.LittleEndian.PutUint32(body[4:8], uint32(timestamp>>32))
+	binary.LittleEndian.PutUint32(body[8:12], uint32(timestamp))
+	binary.LittleEndian.PutUint32(body[12:16], uint32(len(packet.Data))) // * Captured length
+	binary.LittleEndian.PutUint32(body[16:20], uint32(len(packet.Data))) // * Original length
+
+	body = append(body, packet.Data...)
+	body = pad(body)
+
+	flags := make([]byte, 4)
+	binary.LittleEndian.PutUint32(flags, uint32(packet.Direction))
+
+	body = appendOption(body, optionEPBFlags, flags)
+
+	for _, comment := range packet.Comments {
+		body = appendOption(body, optionComment, []byte(comment))
+	}
+
+	body = appendOption(body, optionEndOfOptions, nil)
+
+	return pw.writeBlock(blockTypeEnhancedPacket, body)
+}
+
+func (pw *Writer) writeBlock(blockType uint32, body []byte) error {
+	length := uint32(12 + len(body))
+	block := make([]byte, 0, length)
+
+	block = binary.LittleEndian.AppendUint32(block, blockType)
+	block = binary.LittleEndian.AppendUint32(block, length)
+	block = append(block, body...)
+	block = binary.LittleEndian.AppendUint32(block, length)
+
+	_, err := pw.w.Write(block)
+
+	return err
+}
+
+func (pw *Writer) writeHeader() error {
+	sectionHeader := make([]byte, 0, 16)
+	sectionHeader = binary.LittleEndian.AppendUint32(sectionHeader, byteOrderMagic)
+	sectionHeader = binary.LittleEndian.AppendUint16(sectionHeader, 1) // * Major version
+	sectionHeader = binary.LittleEndian.AppendUint16(sectionHeader, 0) // * Minor version
+	sectionHeader = binary.LittleEndian.AppendUint64(sectionHeader, 0xFFFFFFFFFFFFFFFF)
+
+	if err := pw.writeBlock(blockTypeSectionHeader, sectionHeader); err != nil {
+		return err
+	}
+
+	interfaceDescription := make([]byte, 0, 8)
+	interfaceDescription = binary.LittleEndian.AppendUint16(interfaceDescription, pw.linkType)
+	interfaceDescription = binary.LittleEndian.AppendUint16(interfaceDescription, 0) // * Reserved
+	interfaceDescription = binary.LittleEndian.AppendUint32(interfaceDescription, 0) // * No snapshot length limit
+
+	return pw.writeBlock(blockTypeInterfaceDescription, interfaceDescription)
+}
+
+func appendOption(body []byte, code uint16, value []byte) []byte {
+	body = binary.LittleEndian.AppendUint16(body, code)
+	body = binary.LittleEndian.AppendUint16(body, uint16(len(value)))
+	body = append(body, value...)
+
+	return pad(body)
+}
+
+// pad pads the data with zeros to a 32 bit boundary
+func pad(data []byte) []byte {
+	for len(data)%4 != 0 {
+		data = append(data, 0)
+	}
+
+	return data
+}
+
+// NewWriter writes the pcapng section and interface headers to w and returns a new Writer
+func NewWriter(w io.Writer, linkType uint16) (*Writer, error) {
+	pw := &Writer{
+		w:        w,
+		linkType: linkType,
+	}
+
+	if err := pw.writeHeader(); err != nil {
+		return nil, err
+	}
+
+	return pw, nil
+}
@@ -1,8 +1,10 @@
 package nex
 
 import (
+	"context"
 	"crypto/md5"
 	"net"
+	"sync"
 	"time"
 
 	"github.com/PretendoNetwork/nex-go/v2/constants"
@@ -13,9 +15,10 @@ import (
 // Does not necessarily represent a socket connection.
 // A single network socket may be used to open multiple PRUDP virtual connections
 type PRUDPConnection struct {
-	Socket                              *SocketConnection // * The connections parent socket
-	endpoint                            *PRUDPEndPoint    // * The PRUDP endpoint the connection is connected to
-	ConnectionState                     ConnectionState
+	Socket                              *SocketConnection                      // * The connections parent socket
+	endpoint                            *PRUDPEndPoint                         // * The PRUDP endpoint the connection is connected to
+	ConnectionState                     ConnectionState                        // * Changed by the packet handlers while the connection is active
+	stateLock                           sync.RWMutex                           // * Guards ConnectionState and the PID once the connection is active
 	ID                                  uint32                                 // * Connection ID
 	SessionID                           uint8                                  // * Random value generated at the start of the session. Client and server IDs do not need to match
 	ServerSessionID                     uint8                                  // * Random value generated at the start of the session. Client and server IDs do not need to match
@@ -24,7 +27,8 @@ type PRUDPConnection struct {
 	DefaultPRUDPVersion                 int                                    // * The PRUDP version the connection was established with. Used for sending PING packets
 	StreamType                          constants.StreamType                   // * rdv::Stream::Type used in this connection
 	StreamID                            uint8                                  // * rdv::Stream ID, also called the "port number", used in this connection. 0-15 on PRUDPv0/v1, and 0-31 on PRUDPLite
-	StreamSettings                      *StreamSettings                        // * Settings for this virtual connection
+	StreamSettings                      *StreamSettings                        // * Settings for this virtual connection. Replaced by PRUDPEndPoint.UpdateStreamSettings while the connection is active
+	streamSettingsLock                  sync.RWMutex                           // * Guards StreamSettings once the connection is active
 	Signature                           []byte                                 // * Connection signature for packets coming from the client, as seen by the server
 	ServerConnectionSignature           []byte                                 // * Connection signature for packets coming from the server, as seen by the client
 	UnreliablePacketBaseKey             []byte                                 // * The base key used for encrypting unreliable DATA packets
@@ -32,12 +36,19 @@ type PRUDPConnection struct {
 	slidingWindows                      *MutexMap[uint8, *SlidingWindow]       // * Outbound reliable packet substreams
 	packetDispatchQueues                *MutexMap[uint8, *PacketDispatchQueue] // * Inbound reliable packet substreams
 	incomingFragmentBuffers             *MutexMap[uint8, []byte]               // * Buffers which store the incoming payloads from fragmented DATA packets
+	fragmentReassemblies                *MutexMap[uint8, fragmentReassembly]   // * When the fragments in the incoming buffers started arriving, for tracing
+	traces                              *MutexMap[uint32, *rmcTrace]           // * Traces of the RMC calls currently being handled, by call ID
+	calls                               *MutexMap[uint32, *rmcCall]            // * RMC requests waiting for a response, by call ID
+	ctx                                 context.Context                        // * Cancelled when the connection ends. Parent of all request contexts
+	cancel                              context.CancelFunc
 	outgoingUnreliableSequenceIDCounter *Counter[uint16]
 	outgoingPingSequenceIDCounter       *Counter[uint16]
 	lastSentPingTime                    time.Time
 	heartbeatTimer                      *time.Timer
 	pingKickTimer                       *time.Timer
 	StationURLs                         types.List[types.StationURL]
+	counters                            connectionCounters // * Running totals used by Stats
+	createdAt                           time.Time
 }
 
 // Endpoint returns the PRUDP endpoint the connections socket is connected to
@@ -52,17 +63,111 @@ func (pc *PRUDPConnection) Address() net.Addr {
 
 // PID returns the clients unique PID
 func (pc *PRUDPConnection) PID() types.PID {
+	pc.stateLock.RLock()
+	defer pc.stateLock.RUnlock()
+
 	return pc.pid
 }
 
 // SetPID sets the clients unique PID
 func (pc *PRUDPConnection) SetPID(pid types.PID) {
+	pc.stateLock.Lock()
+	defer pc.stateLock.Unlock()
+
 	pc.pid = pid
 }
 
+// state returns the ConnectionState
+func (pc *PRUDPConnection) state() ConnectionState {
+	pc.stateLock.RLock()
+	defer pc.stateLock.RUnlock()
+
+	return pc.ConnectionState
+}
+
+// setState sets the ConnectionState
+func (pc *PRUDPConnection) setState(state ConnectionState) {
+	pc.stateLock.Lock()
+	defer pc.stateLock.Unlock()
+
+	pc.ConnectionState = state
+}
+
+// Stats returns a snapshot of the connections statistics
+func (pc *PRUDPConnection) Stats() PRUDPConnectionStats {
+	stats := PRUDPConnectionStats{
+		ID:                  pc.ID,
+		PID:                 pc.PID(),
+		Address:             pc.Socket.Address.String(),
+		State:               pc.state(),
+		BytesSent:           pc.counters.bytesSent.Load(),
+		BytesReceived:       pc.counters.bytesReceived.Load(),
+		PacketsSent:         pc.counters.packetsSent.Load(),
+		PacketsReceived:     pc.counters.packetsReceived.Load(),
+		Retransmissions:     pc.counters.retransmissions.Load(),
+		PendingUnacked:      make(map[uint8]int),
+		FragmentBufferSizes: make(map[uint8]int),
+		Age:                 time.Since(pc.createdAt),
+	}
+
+	if lastSeen := pc.counters.lastSeen.Load(); lastSeen != 0 {
+		stats.LastSeen = time.Unix(0, lastSeen)
+	}
+
+	pc.rtt.Lock()
+	if pc.rtt.initialized {
+		stats.SmoothedRTT = time.Duration(pc.rtt.average)
+		stats.RTTDeviation = time.Duration(pc.rtt.variance)
+	}
+	pc.rtt.Unlock()
+
+	pc.slidingWindows.Each(func(substreamID uint8, slidingWindow *SlidingWindow) bool {
+		stats.PendingUnacked[substreamID] = slidingWindow.TimeoutManager.packets.Size()
+		return false
+	})
+
+	pc.incomingFragmentBuffers.Each(func(substreamID uint8, buffer []byte) bool {
+		stats.FragmentBufferSizes[substreamID] = len(buffer)
+		return false
+	})
+
+	return stats
+}
+
+// streamSettings returns the connections StreamSettings. The returned settings are never modified,
+// as updates replace them, so they may be used after the lock is released
+func (pc *PRUDPConnection) streamSettings() *StreamSettings {
+	pc.streamSettingsLock.RLock()
+	defer pc.streamSettingsLock.RUnlock()
+
+	return pc.StreamSettings
+}
+
+// updateStreamSettings applies the settings which are safe to change mid-stream. Settings negotiated when the
+// connection was established, such as the encryption and compression algorithms, are kept.
+//
+// The settings are replaced rather than modified, so settings returned by streamSettings before the update are left as they were
+func (pc *PRUDPConnection) updateStreamSettings(settings *StreamSettings) {
+	pc.streamSettingsLock.Lock()
+	defer pc.streamSettingsLock.Unlock()
+
+	updated := *pc.StreamSettings
+
+	updated.ExtraRetransmitTimeoutTrigger = settings.ExtraRetransmitTimeoutTrigger
+	updated.MaxPacketRetransmissions = settings.MaxPacketRetransmissions
+	updated.KeepAliveTimeout = settings.KeepAliveTimeout
+	updated.FaultDetectionEnabled = settings.FaultDetectionEnabled
+	updated.ExtraRetransmitTimeoutMultiplier = settings.ExtraRetransmitTimeoutMultiplier
+	updated.RTTRetransmit = settings.RTTRetransmit
+	updated.RetransmitTimeoutMultiplier = settings.RetransmitTimeoutMultiplier
+	updated.MaxSilenceTime = settings.MaxSilenceTime
+
+	pc.StreamSettings = &updated
+}
+
 // reset resets the connection state to all zero values
 func (pc *PRUDPConnection) reset() {
-	pc.ConnectionState = StateNotConnected
+	pc.setState(StateNotConnected)
 	pc.packetDispatchQueues.Clear(func(_ uint8, packetDispatchQueue *PacketDispatchQueue) {
 		packetDispatchQueue.Purge()
 	})
@@ -84,13 +189,18 @@ func (pc *PRUDPConnection) cleanup() {
 
 	pc.stopHeartbeatTimers()
 
+	pc.cancel()
+	pc.calls.Clear(func(_ uint32, _ *rmcCall) {})
+	pc.endTraces("nex.connection.closed")
+
 	pc.endpoint.emitConnectionEnded(pc)
 }
 
 // InitializeSlidingWindows initializes the SlidingWindows for all substreams
 func (pc *PRUDPConnection) InitializeSlidingWindows(maxSubstreamID uint8) {
-	// * Nuke any existing SlidingWindows
-	pc.slidingWindows = NewMutexMap[uint8, *SlidingWindow]()
+	// * Nuke any existing SlidingWindows. The map is cleared
+	// * rather than replaced, as Stats may be reading it
+	pc.slidingWindows.Clear(func(_ uint8, _ *SlidingWindow) {})
 
 	for i := 0; i < int(maxSubstreamID+1); i++ {
 		pc.CreateSlidingWindow(uint8(i))
@@ -100,7 +210,7 @@ func (pc *PRUDPConnection) InitializeSlidingWindows(maxSubstreamID uint8) {
 // InitializePacketDispatchQueues initializes the PacketDispatchQueues for all substreams
 func (pc *PRUDPConnection) InitializePacketDispatchQueues(maxSubstreamID uint8) {
 	// * Nuke any existing PacketDispatchQueues
-	pc.packetDispatchQueues = NewMutexMap[uint8, *PacketDispatchQueue]()
+	pc.packetDispatchQueues.Clear(func(_ uint8, _ *PacketDispatchQueue) {})
 
 	for i := 0; i < int(maxSubstreamID+1); i++ {
 		pc.CreatePacketDispatchQueue(uint8(i))
@@ -112,7 +222,7 @@ func (pc *PRUDPConnection) InitializePacketDispatchQueues(maxSubstreamID uint8)
 func (pc *PRUDPConnection) CreateSlidingWindow(substreamID uint8) *SlidingWindow {
 	slidingWindow := NewSlidingWindow()
 	slidingWindow.sequenceIDCounter = NewCounter[uint16](0) // * First DATA packet from the server has sequence ID 1 (start counter at 0 and is incremeneted)
-	slidingWindow.streamSettings = pc.StreamSettings.Copy()
+	slidingWindow.streamSettings = pc.streamSettings().Copy()
 
 	pc.slidingWindows.Set(substreamID, slidingWindow)
 
@@ -202,7 +312,7 @@ func (pc *PRUDPConnection) resetHeartbeat() {
 
 	if pc.heartbeatTimer != nil {
 		// TODO: This may not be accurate, needs more research
-		pc.heartbeatTimer.Reset(time.Duration(pc.StreamSettings.MaxSilenceTime) * time.Millisecond)
+		pc.heartbeatTimer.Reset(time.Duration(pc.streamSettings().MaxSilenceTime) * time.Millisecond)
 	}
 }
 
@@ -225,13 +335,14 @@ func (pc *PRUDPConnection) SetIncomingFragmentBuffer(substreamID uint8, buffer [
 // Clears the outgoing buffer for a given substream
 func (pc *PRUDPConnection) ClearOutgoingBuffer(substreamID uint8) {
 	pc.incomingFragmentBuffers.Set(substreamID, make([]byte, 0))
+	pc.fragmentReassemblies.Delete(substreamID)
 }
 
 func (pc *PRUDPConnection) startHeartbeat() {
 	endpoint := pc.endpoint
 
 	// TODO: This may not be accurate, needs more research
-	maxSilenceTime := time.Duration(pc.StreamSettings.MaxSilenceTime) * time.Millisecond
+	maxSilenceTime := time.Duration(pc.streamSettings().MaxSilenceTime) * time.Millisecond
 
 	// * Every time a packet is sent, connection.resetHeartbeat()
 	// * is called which resets this timer. If this function
@@ -272,8 +383,14 @@ func NewPRUDPConnection(socket *SocketConnection) *PRUDPConnection {
 		outgoingUnreliableSequenceIDCounter: NewCounter[uint16](1),
 		outgoingPingSequenceIDCounter:       NewCounter[uint16](0),
 		incomingFragmentBuffers:             NewMutexMap[uint8, []byte](),
+		fragmentReassemblies:                NewMutexMap[uint8, fragmentReassembly](),
+		traces:                              NewMutexMap[uint32, *rmcTrace](),
+		calls:                               NewMutexMap[uint32, *rmcCall](),
 		StationURLs:                         types.NewList[types.StationURL](),
+		createdAt:                           time.Now(),
 	}
 
+	pc.ctx, pc.cancel = context.WithCancel(context.Background())
+
 	return pc
 }
@@ -0,0 +1,53 @@
+package nex
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/PretendoNetwork/nex-go/v2/types"
+)
+
+// PRUDPConnectionStats is a point in time snapshot of the statistics of a PRUDPConnection
+type PRUDPConnectionStats struct {
+	ID                  uint32          // * Connection ID
+	PID                 types.PID       // * PID of the user. 0 if the connection has not authenticated
+	Address             string          // * Socket address of the connection
+	State               ConnectionState // * Current connection state
+	BytesSent           uint64          // * Total bytes sent to the connection, including retransmissions
+	BytesReceived       uint64          // * Total bytes received from the connection
+	PacketsSent         uint64          // * Total packets sent to the connection, including retransmissions
+	PacketsReceived     uint64          // * Total packets received from the connection
+	Retransmissions     uint64          // * Total reliable packets resent to the connection
+	SmoothedRTT         time.Duration   // * Smoothed round trip time. 0 if no RTT has been measured yet
+	RTTDeviation        time.Duration   // * Smoothed round trip time deviation. 0 if no RTT has been measured yet
+	PendingUnacked      map[uint8]int   // * Number of reliable packets waiting for an acknowledgement, by substream ID
+	FragmentBufferSizes map[uint8]int   // * Size of the incoming fragment buffers, by substream ID
+	Age                 time.Duration   // * Time since the connection was first seen
+	LastSeen            time.Time       // * Time the last packet was received from the connection
+}
+
+// connectionCounters holds the running totals used to build a PRUDPConnectionStats
+type connectionCounters struct {
+	bytesSent       atomic.Uint64
+	bytesReceived   atomic.Uint64
+	packetsSent     atomic.Uint64
+	packetsReceived atomic.Uint64
+	retransmissions atomic.Uint64
+	lastSeen        atomic.Int64
+}
+
+func (cc *connectionCounters) received(size int) {
+	cc.packetsReceived.Add(1)
+	cc.bytesReceived.Add(uint64(size))
+	cc.lastSeen.Store(time.Now().UnixNano())
+}
+
+func (cc *connectionCounters) sent(size int) {
+	cc.packetsSent.Add(1)
+	cc.bytesSent.Add(uint64(size))
+}
+
+func (cc *connectionCounters) retransmitted(size int) {
+	cc.retransmissions.Add(1)
+	cc.sent(size)
+}
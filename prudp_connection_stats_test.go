@@ -0,0 +1,95 @@
+package nex
+
+import (
+	"testing"
+	"time"
+
+	"github.com/PretendoNetwork/nex-go/v2/constants"
+	"github.com/PretendoNetwork/nex-go/v2/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPRUDPConnectionStats(t *testing.T) {
+	_, endpoint := newTestPRUDPServer(t)
+	endpoint.DefaultStreamSettings.InitialRTT = 50
+
+	client := newTestPRUDPClient(t, endpoint)
+	connection := client.connect()
+
+	stats := connection.Stats()
+	assert.Equal(t, StateConnected, stats.State)
+	assert.Equal(t, client.address.String(), stats.Address)
+	assert.Equal(t, uint64(2), stats.PacketsReceived)
+	assert.Equal(t, uint64(2), stats.PacketsSent)
+	assert.Zero(t, stats.SmoothedRTT)
+
+	endpoint.sendRMCMessage(connection, nil, []byte{1, 2, 3})
+
+	data := client.receive(constants.DataPacket)
+	assert.Equal(t, 1, connection.Stats().PendingUnacked[0])
+
+	// * RTTs are only measured from packets which were resent, see StreamSettings.RTTRetransmit
+	retransmitted := client.receive(constants.DataPacket)
+	assert.Equal(t, data.SequenceID(), retransmitted.SequenceID())
+
+	client.acknowledge(retransmitted)
+
+	stats = connection.Stats()
+	assert.Equal(t, uint64(1), stats.Retransmissions)
+	assert.Equal(t, uint64(4), stats.PacketsSent)
+	assert.Equal(t, uint64(3), stats.PacketsReceived)
+	assert.Equal(t, uint64(client.bytesReceived), stats.BytesSent)
+	assert.Equal(t, uint64(client.bytesSent), stats.BytesReceived)
+	assert.Equal(t, 0, stats.PendingUnacked[0])
+	assert.Greater(t, stats.SmoothedRTT, time.Duration(0))
+	assert.False(t, stats.LastSeen.IsZero())
+}
+
+func TestPRUDPConnectionStatsDuringHandshake(t *testing.T) {
+	_, endpoint := newTestPRUDPServer(t)
+
+	client := newTestPRUDPClient(t, endpoint)
+	client.syn()
+
+	connection := client.connection()
+	if !assert.NotNil(t, connection) {
+		return
+	}
+
+	// * Stats may be read, e.g. by the admin handler, while the packet handlers change the connection
+	started := make(chan struct{})
+	done := make(chan struct{})
+	read := make(chan struct{})
+
+	go func() {
+		defer close(read)
+
+		_ = connection.Stats()
+		close(started)
+
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				_ = connection.Stats()
+			}
+		}
+	}()
+
+	<-started
+
+	client.send(client.newConnect(client.connectionSignature))
+	client.receive(constants.ConnectPacket)
+
+	for pid := uint64(1800000000); pid <= 1800000100; pid++ {
+		connection.SetPID(types.NewPID(pid))
+	}
+
+	close(done)
+	<-read
+
+	stats := connection.Stats()
+	assert.Equal(t, StateConnected, stats.State)
+	assert.Equal(t, types.NewPID(1800000100), stats.PID)
+}
@@ -0,0 +1,407 @@
+package nex
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/PretendoNetwork/nex-go/v2/constants"
+)
+
+// PRUDPDissector decodes raw PRUDP datagrams outside of a running server, for debugging.
+//
+// Reliable DATA payloads are encrypted using RC4 streams, so the dissector tracks the cipher state of every
+// virtual connection it sees. Datagrams must be dissected in the order they were captured for payloads to decrypt
+type PRUDPDissector struct {
+	Server         *PRUDPServer    // * Server whose settings are used to decode packets, such as the access key and PRUDPv0 settings
+	StreamSettings *StreamSettings // * Settings used for new virtual connections, such as the compression algorithm
+	SessionKey     []byte          // * Session key used for connections without a key set by SetSessionKey. Leave nil for non-secure connections
+	streams        map[string]*dissectorStream
+	endpoints      map[uint8]*PRUDPEndPoint
+}
+
+// dissectorStream is the state of one direction of a virtual connection
+type dissectorStream struct {
+	connection *PRUDPConnection
+	synced     map[uint8]bool // * Whether the PacketDispatchQueue of a substream has seen it's first packet
+}
+
+// DissectedPacket is a single PRUDP packet decoded by a PRUDPDissector
+type DissectedPacket struct {
+	Packet     PRUDPPacketInterface
+	FromClient bool          // * Whether the packet was sent by the client or the server
+	Payload    []byte        // * Decrypted and decompressed payload. nil if the packet is not DATA or is still waiting on earlier packets
+	Messages   []*RMCMessage // * RMC messages completed by this packet. A reordered packet may complete more than one message
+	Errors     []error       // * Errors encountered while decrypting, decompressing or decoding the RMC messages
+	Options    []string      // * Decoded packet options, formatted as "name=value"
+}
+
+// String returns a multi-line human readable description of the packet
+func (dp *DissectedPacket) String() string {
+	packet := dp.Packet
+
+	direction := "server -> client"
+	if dp.FromClient {
+		direction = "client -> server"
+	}
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "PRUDP%s %s %s\n", prudpVersionName(packet.Version()), strings.ToUpper(packetTypeName(packet.Type())), direction)
+	fmt.Fprintf(&b, "  source:      %s %d\n", packet.SourceVirtualPortStreamType(), packet.SourceVirtualPortStreamID())
+	fmt.Fprintf(&b, "  destination: %s %d\n", packet.DestinationVirtualPortStreamType(), packet.DestinationVirtualPortStreamID())
+	fmt.Fprintf(&b, "  flags:       %s\n", prudpFlagNames(packet.Flags()))
+	fmt.Fprintf(&b, "  session ID:  %d\n", packet.SessionID())
+	fmt.Fprintf(&b, "  substream:   %d\n", packet.SubstreamID())
+	fmt.Fprintf(&b, "  sequence ID: %d\n", packet.SequenceID())
+
+	for _, option := range dp.Options {
+		fmt.Fprintf(&b, "  option:      %s\n", option)
+	}
+
+	if len(packet.Payload()) != 0 {
+		fmt.Fprintf(&b, "  raw payload: %s\n", hex.EncodeToString(packet.Payload()))
+	}
+
+	if dp.Payload != nil {
+		fmt.Fprintf(&b, "  payload:     %s\n", hex.EncodeToString(dp.Payload))
+	}
+
+	for _, message := range dp.Messages {
+		b.WriteString(formatRMCMessage(message))
+	}
+
+	for _, err := range dp.Errors {
+		fmt.Fprintf(&b, "  error:       %s\n", err.Error())
+	}
+
+	return b.String()
+}
+
+// SetSessionKey sets the session key of both directions of the virtual connection between the client and the endpoint
+func (pd *PRUDPDissector) SetSessionKey(client net.Addr, streamID uint8, sessionKey []byte) {
+	for _, fromClient := range []bool{true, false} {
+		pd.stream(client, streamID, fromClient).connection.setSessionKey(sessionKey)
+	}
+}
+
+// Dissect decodes every PRUDP packet in a datagram, detecting the PRUDP version the same way the server does.
+// client is the address of the client side of the datagram, regardless of direction
+func (pd *PRUDPDissector) Dissect(data []byte, client net.Addr, fromClient bool) ([]*DissectedPacket, error) {
+	if len(data) < 2 {
+		return nil, errors.New("datagram too short to be a PRUDP packet")
+	}
+
+	readStream := NewByteStreamIn(data, pd.Server.LibraryVersions, pd.Server.ByteStreamSettings)
+
+	var packets []PRUDPPacketInterface
+	var err error
+
+	// * Same detection as PRUDPServer.handleSocketMessage, though
+	// * PRUDPLite is always allowed since the dissector has no
+	// * way to know if the datagram came from a WebSocket
+	if data[0] == 0x80 {
+		packets, err = NewPRUDPPacketsLite(pd.Server, nil, readStream)
+	} else if bytes.Equal(data[:2], []byte{0xEA, 0xD0}) {
+		packets, err = NewPRUDPPacketsV1(pd.Server, nil, readStream)
+	} else {
+		packets, err = NewPRUDPPacketsV0(pd.Server, nil, readStream)
+	}
+
+	dissected := make([]*DissectedPacket, 0, len(packets))
+
+	for _, packet := range packets {
+		dissected = append(dissected, pd.dissectPacket(packet, client, fromClient))
+	}
+
+	return dissected, err
+}
+
+func (pd *PRUDPDissector) dissectPacket(packet PRUDPPacketInterface, client net.Addr, fromClient bool) *DissectedPacket {
+	streamID := packet.SourceVirtualPortStreamID()
+	if fromClient {
+		streamID = packet.DestinationVirtualPortStreamID()
+	}
+
+	stream := pd.stream(client, streamID, fromClient)
+	connection := stream.connection
+
+	packet.SetSender(connection)
+
+	dissected := &DissectedPacket{
+		Packet:     packet,
+		FromClient: fromClient,
+		Options:    prudpPacketOptions(packet),
+	}
+
+	if packet.Type() == constants.ConnectPacket {
+		// * The server sets up the substreams of a connection
+		// * when handling the CONNECT, so do the same for this
+		// * direction of the connection
+		var maximumSubstreamID uint8
+
+		switch packet := packet.(type) {
+		case *PRUDPPacketV1:
+			maximumSubstreamID = packet.maximumSubstreamID
+		case *PRUDPPacketLite:
+			maximumSubstreamID = packet.maximumSubstreamID
+		}
+
+		connection.InitializeSlidingWindows(maximumSubstreamID)
+		connection.InitializePacketDispatchQueues(maximumSubstreamID)
+		clear(stream.synced)
+
+		if connection.SessionKey != nil {
+			connection.setSessionKey(connection.SessionKey)
+		} else if pd.SessionKey != nil {
+			connection.setSessionKey(pd.SessionKey)
+		}
+
+		return dissected
+	}
+
+	if packet.Type() != constants.DataPacket || packet.HasFlag(constants.PacketFlagAck) || packet.HasFlag(constants.PacketFlagMultiAck) {
+		return dissected
+	}
+
+	if !packet.HasFlag(constants.PacketFlagReliable) {
+		payload := packet.Payload()
+
+		// * Unreliable payloads can only be decrypted with a session key
+		if len(connection.UnreliablePacketBaseKey) != 0 {
+			payload = packet.processUnreliableCrypto()
+		}
+
+		dissected.Payload = payload
+		pd.decodeMessage(dissected, payload)
+
+		return dissected
+	}
+
+	substreamID := packet.SubstreamID()
+	packetDispatchQueue := connection.PacketDispatchQueue(substreamID)
+
+	// * Captures may start part way through a connection, and the first
+	// * sequence ID is not the same in both directions, so sync the queue
+	// * to the first reliable packet seen on each substream
+	if !stream.synced[substreamID] {
+		packetDispatchQueue.nextExpectedSequenceId = NewCounter[uint16](packet.SequenceID())
+		stream.synced[substreamID] = true
+	}
+
+	packetDispatchQueue.Queue(packet)
+
+	for nextPacket, ok := packetDispatchQueue.GetNextToDispatch(); ok; nextPacket, ok = packetDispatchQueue.GetNextToDispatch() {
+		var decryptedPayload []byte
+
+		if nextPacket.Version() != 2 {
+			decryptedPayload = nextPacket.decryptPayload()
+		} else {
+			// * PRUDPLite does not encrypt payloads
+			decryptedPayload = nextPacket.Payload()
+		}
+
+		decompressedPayload, err := connection.StreamSettings.CompressionAlgorithm.Decompress(decryptedPayload)
+		if err != nil {
+			dissected.Errors = append(dissected.Errors, err)
+		}
+
+		if nextPacket == packet {
+			dissected.Payload = decompressedPayload
+		}
+
+		incomingFragmentBuffer := connection.GetIncomingFragmentBuffer(substreamID)
+		incomingFragmentBuffer = append(incomingFragmentBuffer, decompressedPayload...)
+		connection.SetIncomingFragmentBuffer(substreamID, incomingFragmentBuffer)
+
+		if nextPacket.getFragmentID() == 0 {
+			pd.decodeMessage(dissected, incomingFragmentBuffer)
+			connection.ClearOutgoingBuffer(substreamID)
+		}
+
+		packetDispatchQueue.Dispatched(nextPacket)
+	}
+
+	return dissected
+}
+
+func (pd *PRUDPDissector) decodeMessage(dissected *DissectedPacket, payload []byte) {
+	message := NewRMCMessage(pd.endpoint(dissected.Packet.Sender().(*PRUDPConnection).StreamID))
+
+	if err := message.FromBytes(payload); err != nil {
+		dissected.Errors = append(dissected.Errors, err)
+		return
+	}
+
+	dissected.Messages = append(dissected.Messages, message)
+}
+
+func (pd *PRUDPDissector) stream(client net.Addr, streamID uint8, fromClient bool) *dissectorStream {
+	key := fmt.Sprintf("%s/%d/%t", client.String(), streamID, fromClient)
+
+	if stream, ok := pd.streams[key]; ok {
+		return stream
+	}
+
+	connection := NewPRUDPConnection(NewSocketConnection(pd.Server, client, nil))
+	connection.endpoint = pd.endpoint(streamID)
+	connection.StreamID = streamID
+	connection.StreamSettings = pd.StreamSettings.Copy()
+	connection.InitializeSlidingWindows(0)
+	connection.InitializePacketDispatchQueues(0)
+
+	if pd.SessionKey != nil {
+		connection.setSessionKey(pd.SessionKey)
+	}
+
+	stream := &dissectorStream{
+		connection: connection,
+		synced:     make(map[uint8]bool),
+	}
+
+	pd.streams[key] = stream
+
+	return stream
+}
+
+func (pd *PRUDPDissector) endpoint(streamID uint8) *PRUDPEndPoint {
+	if endpoint, ok := pd.endpoints[streamID]; ok {
+		return endpoint
+	}
+
+	// * Only used to give RMC messages access to the server
+	// * settings, so it is never bound to the server
+	endpoint := NewPRUDPEndPoint(streamID)
+	endpoint.Server = pd.Server
+
+	pd.endpoints[streamID] = endpoint
+
+	return endpoint
+}
+
+func prudpVersionName(version int) string {
+	if version == 2 {
+		return "Lite"
+	}
+
+	return fmt.Sprintf("v%d", version)
+}
+
+func prudpFlagNames(flags uint16) string {
+	names := make([]string, 0)
+
+	for _, flag := range []struct {
+		flag uint16
+		name string
+	}{
+		{constants.PacketFlagAck, "ACK"},
+		{constants.PacketFlagReliable, "RELIABLE"},
+		{constants.PacketFlagNeedsAck, "NEED_ACK"},
+		{constants.PacketFlagHasSize, "HAS_SIZE"},
+		{constants.PacketFlagMultiAck, "MULTI_ACK"},
+	} {
+		if flags&flag.flag != 0 {
+			names = append(names, flag.name)
+			flags &^= flag.flag
+		}
+	}
+
+	if flags != 0 {
+		names = append(names, fmt.Sprintf("0x%X", flags))
+	}
+
+	if len(names) == 0 {
+		return "none"
+	}
+
+	return strings.Join(names, "|")
+}
+
+func prudpPacketOptions(packet PRUDPPacketInterface) []string {
+	options := make([]string, 0)
+
+	if signature := packet.getConnectionSignature(); len(signature) != 0 {
+		options = append(options, "connection_signature="+hex.EncodeToString(signature))
+	}
+
+	if packet.Type() == constants.DataPacket {
+		options = append(options, fmt.Sprintf("fragment_id=%d", packet.getFragmentID()))
+	}
+
+	var minorVersion, supportedFunctions uint32
+	var maximumSubstreamID uint8
+	var initialUnreliableSequenceID uint16
+
+	switch packet := packet.(type) {
+	case *PRUDPPacketV1:
+		minorVersion, supportedFunctions = packet.minorVersion, packet.supportedFunctions
+		maximumSubstreamID, initialUnreliableSequenceID = packet.maximumSubstreamID, packet.initialUnreliableSequenceID
+	case *PRUDPPacketLite:
+		minorVersion, supportedFunctions = packet.minorVersion, packet.supportedFunctions
+		maximumSubstreamID, initialUnreliableSequenceID = packet.maximumSubstreamID, packet.initialUnreliableSequenceID
+	default:
+		return options
+	}
+
+	if packet.Type() == constants.SynPacket || packet.Type() == constants.ConnectPacket {
+		options = append(options,
+			fmt.Sprintf("minor_version=%d", minorVersion),
+			fmt.Sprintf("supported_functions=0x%X", supportedFunctions),
+			fmt.Sprintf("maximum_substream_id=%d", maximumSubstreamID),
+		)
+	}
+
+	if packet.Type() == constants.ConnectPacket {
+		options = append(options, fmt.Sprintf("initial_unreliable_sequence_id=%d", initialUnreliableSequenceID))
+	}
+
+	return options
+}
+
+func formatRMCMessage(message *RMCMessage) string {
+	var b strings.Builder
+
+	kind := "response"
+	if message.IsRequest {
+		kind = "request"
+	}
+
+	fmt.Fprintf(&b, "  RMC %s\n", kind)
+
+	if message.Endpoint.UseVerboseRMC() {
+		fmt.Fprintf(&b, "    protocol:   %s\n", string(message.ProtocolName))
+		fmt.Fprintf(&b, "    method:     %s\n", string(message.MethodName))
+	} else {
+		fmt.Fprintf(&b, "    protocol:   %d (0x%X)\n", message.ProtocolID, message.ProtocolID)
+		fmt.Fprintf(&b, "    method:     %d\n", message.MethodID)
+	}
+
+	fmt.Fprintf(&b, "    call ID:    %d\n", message.CallID)
+
+	if !message.IsRequest {
+		if message.IsSuccess {
+			b.WriteString("    result:     success\n")
+		} else {
+			fmt.Fprintf(&b, "    result:     %s (0x%08X)\n", ResultCodeToName(message.ErrorCode), message.ErrorCode)
+		}
+	}
+
+	if len(message.Parameters) != 0 {
+		fmt.Fprintf(&b, "    parameters: %s\n", hex.EncodeToString(message.Parameters))
+	}
+
+	return b.String()
+}
+
+// NewPRUDPDissector returns a new PRUDPDissector using the settings of the given server
+func NewPRUDPDissector(server *PRUDPServer) *PRUDPDissector {
+	return &PRUDPDissector{
+		Server:         server,
+		StreamSettings: NewStreamSettings(),
+		streams:        make(map[string]*dissectorStream),
+		endpoints:      make(map[uint8]*PRUDPEndPoint),
+	}
+}
@@ -0,0 +1,53 @@
+package nex
+
+import (
+	"net"
+	"testing"
+
+	"github.com/PretendoNetwork/nex-go/v2/constants"
+	"github.com/PretendoNetwork/nex-go/v2/encryption"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPRUDPDissectorDecryptsReliableData(t *testing.T) {
+	server := NewPRUDPServer()
+	dissector := NewPRUDPDissector(server)
+
+	request := NewRMCRequest(dissector.endpoint(1))
+	request.ProtocolID = 0xA
+	request.MethodID = 1
+	request.CallID = 7
+	request.Parameters = []byte{1, 2, 3}
+
+	// * Non-secure connections use the default RC4 key
+	cipher := encryption.NewRC4Encryption()
+
+	client := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 50000}
+
+	for sequenceID := uint16(2); sequenceID < 4; sequenceID++ {
+		payload, _ := cipher.Encrypt(request.Bytes())
+
+		packet, _ := NewPRUDPPacketV1(server, nil, nil)
+		packet.SetType(constants.DataPacket)
+		packet.AddFlag(constants.PacketFlagReliable)
+		packet.AddFlag(constants.PacketFlagNeedsAck)
+		packet.SetSourceVirtualPortStreamType(constants.StreamTypeRVSecure)
+		packet.SetSourceVirtualPortStreamID(15)
+		packet.SetDestinationVirtualPortStreamType(constants.StreamTypeRVSecure)
+		packet.SetDestinationVirtualPortStreamID(1)
+		packet.SetSequenceID(sequenceID)
+		packet.setSignature(make([]byte, 16))
+		packet.SetPayload(payload)
+
+		dissected, err := dissector.Dissect(packet.Bytes(), client, true)
+		assert.NoError(t, err)
+		assert.Len(t, dissected, 1)
+		assert.Len(t, dissected[0].Messages, 1)
+
+		message := dissected[0].Messages[0]
+		assert.True(t, message.IsRequest)
+		assert.Equal(t, uint16(0xA), message.ProtocolID)
+		assert.Equal(t, uint32(7), message.CallID)
+		assert.Equal(t, []byte{1, 2, 3}, message.Parameters)
+	}
+}
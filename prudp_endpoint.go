@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"slices"
+	"sync"
 	"time"
 
 	"github.com/PretendoNetwork/nex-go/v2/constants"
@@ -21,7 +22,7 @@ import (
 type PRUDPEndPoint struct {
 	Server                            *PRUDPServer
 	StreamID                          uint8
-	DefaultStreamSettings             *StreamSettings
+	DefaultStreamSettings             *StreamSettings // * Copied by new connections. Use UpdateStreamSettings to change it once the server is running
 	Connections                       *MutexMap[string, *PRUDPConnection]
 	packetHandlers                    map[uint16]func(packet PRUDPPacketInterface)
 	packetEventHandlers               map[string][]func(packet PacketInterface)
@@ -29,10 +30,27 @@ type PRUDPEndPoint struct {
 	errorEventHandlers                []func(err *Error)
 	ConnectionIDCounter               *Counter[uint32]
 	ServerAccount                     *Account
-	AccountDetailsByPID               func(pid types.PID) (*Account, *Error)
-	AccountDetailsByUsername          func(username string) (*Account, *Error)
+	AccountStore                      AccountStore                             // * Looks up accounts for Kerberos authentication. Wrap it with NewCachingAccountStore to avoid deriving the server key on every CONNECT
+	AccountDetailsByPID               func(pid types.PID) (*Account, *Error)   // * Deprecated: Use AccountStore. Only used if AccountStore is nil
+	AccountDetailsByUsername          func(username string) (*Account, *Error) // * Deprecated: Use AccountStore. Only used if AccountStore is nil
 	IsSecureEndPoint                  bool
 	CalcRetransmissionTimeoutCallback CalcRetransmissionTimeoutCallback
+	ErrorPolicy                       func(err *Error) ErrorAction          // * Decides what to do with a connection after one of it's packets causes an error. Uses DefaultErrorPolicy if nil
+	HandlerPool                       *HandlerPool                          // * Runs the data handlers. If nil, handlers run on the goroutine which processed the packet
+	HandlerTimeout                    time.Duration                         // * How long a request may go without a response before the client is sent a Core::Timeout error. Disabled if 0
+	KerberosTicketLifetime            time.Duration                         // * How long after being issued a Kerberos ticket is accepted by a secure endpoint
+	KerberosClockSkew                 time.Duration                         // * How far the clock of the server issuing Kerberos tickets may differ from this one
+	settingsLock                      sync.RWMutex                          // * Guards the settings which ServerConfig.Reload and UpdateStreamSettings change while clients are connected
+	ticketService                     *KerberosTicketService                // * Validates the Kerberos tickets of secure CONNECTs. Replaced when the Kerberos settings change
+	usedKerberosTickets               *MutexMap[string, usedKerberosTicket] // * Ticket and check value pairs already used to connect, until they expire
+	usedKerberosTicketsPrunedAt       time.Time
+	usedKerberosTicketsPruneLock      sync.Mutex
+}
+
+// usedKerberosTicket is a Kerberos ticket and check value pair which was used to connect to a secure endpoint
+type usedKerberosTicket struct {
+	owner     string // * Address of the client which used the pair
+	expiresAt time.Time
 }
 
 // CalcRetransmissionTimeoutCallback is an optional callback which can be used to override the RTO calculation
@@ -117,7 +135,13 @@ func (pep *PRUDPEndPoint) cleanupConnection(connection *PRUDPConnection) {
 
 	// * Probably this connection is on a different PRUDPEndPoint
 	if !found {
-		logger.Warningf("Tried to delete connection %v (ID %v) but it doesn't exist!", discriminator, connection.ID)
+		pep.Server.log().Warn("Tried to delete connection but it doesn't exist", append(connectionLogFields(connection), "discriminator", discriminator)...)
+	} else {
+		pep.Server.Metrics.connectionClosed(pep)
+	}
+
+	if connection.Socket.session != nil {
+		connection.Socket.session.removeConnection(connection)
 	}
 
 	// * We can't do this during RunAndDelete, since we hold the Connections mutex then
@@ -136,11 +160,20 @@ func (pep *PRUDPEndPoint) processPacket(packet PRUDPPacketInterface, socket *Soc
 		connection.DefaultPRUDPVersion = packet.Version()
 		connection.StreamType = streamType
 		connection.StreamID = streamID
-		connection.StreamSettings = pep.DefaultStreamSettings.Copy()
+		connection.StreamSettings = pep.defaultStreamSettings().Copy()
+
+		if socket.session != nil {
+			socket.session.addConnection(connection)
+		}
+
+		pep.Server.Metrics.connectionOpened(pep)
+
 		return connection
 	})
 
 	packet.SetSender(connection)
+	connection.counters.received(packet.getRawSize())
+	pep.Server.Metrics.packetReceived(packet)
 
 	if packet.HasFlag(constants.PacketFlagAck) || packet.HasFlag(constants.PacketFlagMultiAck) {
 		pep.handleAcknowledgment(packet)
@@ -150,14 +183,14 @@ func (pep *PRUDPEndPoint) processPacket(packet PRUDPPacketInterface, socket *Soc
 	if packetHandler, ok := pep.packetHandlers[packet.Type()]; ok {
 		packetHandler(packet)
 	} else {
-		logger.Warningf("Unhandled packet type %d", packet.Type())
+		pep.handleError(ResultCodes.Core.NotImplemented, packet, fmt.Errorf("Unhandled packet type %d", packet.Type()))
 	}
 }
 
 func (pep *PRUDPEndPoint) handleAcknowledgment(packet PRUDPPacketInterface) {
 	connection := packet.Sender().(*PRUDPConnection)
 
-	if connection.ConnectionState < StateConnected {
+	if connection.state() < StateConnected {
 		return
 	}
 
@@ -170,7 +203,9 @@ func (pep *PRUDPEndPoint) handleAcknowledgment(packet PRUDPPacketInterface) {
 
 	if packet.Type() == constants.PingPacket {
 		if packet.SequenceID() == connection.outgoingPingSequenceIDCounter.Value {
-			connection.rtt.Adjust(time.Since(connection.lastSentPingTime))
+			rtt := time.Since(connection.lastSentPingTime)
+			connection.rtt.Adjust(rtt)
+			pep.Server.Metrics.rttMeasured(pep, rtt)
 		}
 	} else {
 		slidingWindow := connection.SlidingWindow(packet.SubstreamID())
@@ -242,7 +277,8 @@ func (pep *PRUDPEndPoint) handleSyn(packet PRUDPPacketInterface) {
 
 	connectionSignature, err := packet.calculateConnectionSignature(connection.Socket.Address)
 	if err != nil {
-		logger.Error(err.Error())
+		pep.handleError(ResultCodes.Transport.ConnectionFailure, packet, err)
+		return
 	}
 
 	connection.reset()
@@ -265,18 +301,31 @@ func (pep *PRUDPEndPoint) handleSyn(packet PRUDPPacketInterface) {
 		ack.supportedFunctions = pep.Server.SupportedFunctions & packet.(*PRUDPPacketV1).supportedFunctions
 	}
 
-	connection.ConnectionState = StateConnecting
+	connection.setState(StateConnecting)
 
 	pep.emit("syn", ack)
 
-	pep.Server.sendRaw(connection.Socket, ack.Bytes())
+	data := ack.Bytes()
+
+	connection.counters.sent(len(data))
+	pep.Server.Metrics.packetSent(ack)
+	if err := pep.Server.sendRaw(connection.Socket, data); err != nil {
+		pep.handleError(ResultCodes.Transport.IOError, ack, err)
+	}
 }
 
 func (pep *PRUDPEndPoint) handleConnect(packet PRUDPPacketInterface) {
 	connection := packet.Sender().(*PRUDPConnection)
 
-	if connection.ConnectionState < StateConnecting {
-		return
+	if connection.state() < StateConnecting {
+		// * With a shared ConnectionSignatureKeyRing, the SYN may have been handled by another node
+		connectionSignature, ok := pep.verifyConnectionSignature(packet)
+		if !ok {
+			return
+		}
+
+		connection.Signature = connectionSignature
+		connection.setState(StateConnecting)
 	}
 
 	connection.resetHeartbeat()
@@ -296,7 +345,8 @@ func (pep *PRUDPEndPoint) handleConnect(packet PRUDPPacketInterface) {
 
 	connectionSignature, err := packet.calculateConnectionSignature(connection.Socket.Address)
 	if err != nil {
-		logger.Error(err.Error())
+		pep.handleError(ResultCodes.Transport.ConnectionFailure, packet, err)
+		return
 	}
 
 	connection.ServerSessionID = packet.SessionID()
@@ -334,9 +384,9 @@ func (pep *PRUDPEndPoint) handleConnect(packet PRUDPPacketInterface) {
 	if pep.IsSecureEndPoint {
 		var decryptedPayload []byte
 		if pep.Server.PRUDPV0Settings.EncryptedConnect {
-			decryptedPayload, err = connection.StreamSettings.EncryptionAlgorithm.Decrypt(packet.Payload())
+			decryptedPayload, err = connection.streamSettings().EncryptionAlgorithm.Decrypt(packet.Payload())
 			if err != nil {
-				logger.Error(err.Error())
+				pep.handleError(ResultCodes.RendezVous.EncryptionFailure, packet, err)
 				return
 			}
 
@@ -344,20 +394,21 @@ func (pep *PRUDPEndPoint) handleConnect(packet PRUDPPacketInterface) {
 			decryptedPayload = packet.Payload()
 		}
 
-		decompressedPayload, err := connection.StreamSettings.CompressionAlgorithm.Decompress(decryptedPayload)
+		decompressedPayload, err := connection.streamSettings().CompressionAlgorithm.Decompress(decryptedPayload)
 		if err != nil {
-			logger.Error(err.Error())
+			pep.handleError(ResultCodes.Transport.DecompressionFailure, packet, err)
 			return
 		}
 
-		sessionKey, pid, checkValue, err := pep.readKerberosTicket(decompressedPayload)
-		if err != nil {
-			logger.Error(err.Error())
+		sessionKey, pid, checkValue, ticketError := pep.readKerberosTicket(connection, decompressedPayload)
+		if ticketError != nil {
+			pep.handleError(ticketError.ResultCode, packet, errors.New(ticketError.Message))
 			return
 		}
 
 		connection.SetPID(pid)
 		connection.setSessionKey(sessionKey)
+		pep.Server.Capture.recordSessionKey(connection.Socket.Address, sessionKey)
 
 		responseCheckValue := checkValue + 1
 		responseCheckValueBytes := make([]byte, 4)
@@ -372,17 +423,17 @@ func (pep *PRUDPEndPoint) handleConnect(packet PRUDPPacketInterface) {
 		payload = stream.Bytes()
 	}
 
-	compressedPayload, err := connection.StreamSettings.CompressionAlgorithm.Compress(payload)
+	compressedPayload, err := connection.streamSettings().CompressionAlgorithm.Compress(payload)
 	if err != nil {
-		logger.Error(err.Error())
+		pep.handleError(ResultCodes.Core.SystemError, packet, err)
 		return
 	}
 
 	var encryptedPayload []byte
 	if pep.Server.PRUDPV0Settings.EncryptedConnect {
-		encryptedPayload, err = connection.StreamSettings.EncryptionAlgorithm.Encrypt(compressedPayload)
+		encryptedPayload, err = connection.streamSettings().EncryptionAlgorithm.Encrypt(compressedPayload)
 		if err != nil {
-			logger.Error(err.Error())
+			pep.handleError(ResultCodes.RendezVous.EncryptionFailure, packet, err)
 			return
 		}
 	} else {
@@ -392,18 +443,24 @@ func (pep *PRUDPEndPoint) handleConnect(packet PRUDPPacketInterface) {
 	ack.SetPayload(encryptedPayload)
 	ack.setSignature(ack.calculateSignature([]byte{}, packet.getConnectionSignature()))
 
-	connection.ConnectionState = StateConnected
+	connection.setState(StateConnected)
 	connection.startHeartbeat()
 
 	pep.emit("connect", ack)
 
-	pep.Server.sendRaw(connection.Socket, ack.Bytes())
+	data := ack.Bytes()
+
+	connection.counters.sent(len(data))
+	pep.Server.Metrics.packetSent(ack)
+	if err := pep.Server.sendRaw(connection.Socket, data); err != nil {
+		pep.handleError(ResultCodes.Transport.IOError, ack, err)
+	}
 }
 
 func (pep *PRUDPEndPoint) handleData(packet PRUDPPacketInterface) {
 	connection := packet.Sender().(*PRUDPConnection)
 
-	if connection.ConnectionState < StateConnected {
+	if connection.state() < StateConnected {
 		return
 	}
 
@@ -436,7 +493,7 @@ func (pep *PRUDPEndPoint) handleDisconnect(packet PRUDPPacketInterface) {
 func (pep *PRUDPEndPoint) handlePing(packet PRUDPPacketInterface) {
 	connection := packet.Sender().(*PRUDPConnection)
 
-	if connection.ConnectionState < StateConnected {
+	if connection.state() < StateConnected {
 		return
 	}
 
@@ -453,74 +510,77 @@ func (pep *PRUDPEndPoint) handlePing(packet PRUDPPacketInterface) {
 	}
 }
 
-func (pep *PRUDPEndPoint) readKerberosTicket(payload []byte) ([]byte, types.PID, uint32, error) {
+func (pep *PRUDPEndPoint) readKerberosTicket(connection *PRUDPConnection, payload []byte) ([]byte, types.PID, uint32, *Error) {
 	stream := NewByteStreamIn(payload, pep.Server.LibraryVersions, pep.ByteStreamSettings())
 
 	ticketData := types.NewBuffer(nil)
 	if err := ticketData.ExtractFrom(stream); err != nil {
-		return nil, 0, 0, err
+		return nil, 0, 0, NewError(ResultCodes.Core.InvalidArgument, fmt.Sprintf("Failed to read Kerberos ticket. %s", err.Error()))
 	}
 
 	requestData := types.NewBuffer(nil)
 	if err := requestData.ExtractFrom(stream); err != nil {
-		return nil, 0, 0, err
+		return nil, 0, 0, NewError(ResultCodes.Core.InvalidArgument, fmt.Sprintf("Failed to read Kerberos request. %s", err.Error()))
 	}
 
-	// * Sanity checks
-	serverAccount, _ := pep.AccountDetailsByUsername(pep.ServerAccount.Username)
-	if serverAccount == nil {
-		return nil, 0, 0, errors.New("Failed to find endpoint server account")
-	}
+	ticket, err := pep.kerberosTicketService().validateTicket(connection.ctx, pep.accountStore(), pep.ServerAccount, ticketData, requestData)
+	if err != nil {
+		var ticketError *Error
+		if !errors.As(err, &ticketError) {
+			ticketError = NewError(ResultCodes.Transport.IncorrectRemoteAuthentication, err.Error())
+		}
 
-	if serverAccount.Password != pep.ServerAccount.Password {
-		return nil, 0, 0, errors.New("Password for endpoint server account does not match the records from AccountDetailsByUsername")
+		return nil, 0, 0, ticketError
 	}
 
-	serverKey := DeriveKerberosKey(serverAccount.PID, []byte(serverAccount.Password))
+	if pep.Server.BanList.PIDBanned(ticket.SourcePID) {
+		return nil, 0, 0, NewError(ResultCodes.RendezVous.AccountDisabled, fmt.Sprintf("PID %d is banned", ticket.SourcePID))
+	}
 
-	ticket := NewKerberosTicketInternalData(pep.Server)
-	if err := ticket.Decrypt(NewByteStreamIn(ticketData, pep.Server.LibraryVersions, pep.ByteStreamSettings()), serverKey); err != nil {
-		return nil, 0, 0, err
+	if !pep.useKerberosTicket(connection, ticketData, ticket) {
+		return nil, 0, 0, NewError(ResultCodes.RendezVous.PermissionDenied, "Kerberos ticket and check value were already used")
 	}
 
-	ticketTime := ticket.Issued.Standard()
-	serverTime := time.Now().UTC()
+	return ticket.SessionKey, ticket.SourcePID, ticket.CheckValue, nil
+}
 
-	timeLimit := ticketTime.Add(time.Minute * 2)
-	if serverTime.After(timeLimit) {
-		return nil, 0, 0, errors.New("Kerberos ticket expired")
-	}
+// useKerberosTicket records that the connection used the ticket with it's check value.
+// Returns false if another client already used the same pair, which means the CONNECT was replayed.
+// The same client may use it again, since clients resend their CONNECT until it is acknowledged
+func (pep *PRUDPEndPoint) useKerberosTicket(connection *PRUDPConnection, ticketData []byte, ticket *ValidatedKerberosTicket) bool {
+	pep.pruneUsedKerberosTickets(time.Now())
 
-	sessionKey := ticket.SessionKey
-	kerberos := NewKerberosEncryption(sessionKey)
+	checkValue := make([]byte, 4)
+	binary.LittleEndian.PutUint32(checkValue, ticket.CheckValue)
 
-	decryptedRequestData, err := kerberos.Decrypt(requestData)
-	if err != nil {
-		return nil, 0, 0, err
-	}
+	owner := connection.Socket.Address.String()
 
-	checkDataStream := NewByteStreamIn(decryptedRequestData, pep.Server.LibraryVersions, pep.ByteStreamSettings())
+	used := pep.usedKerberosTickets.GetOrSetDefault(string(ticketData)+string(checkValue), func() usedKerberosTicket {
+		return usedKerberosTicket{
+			owner:     owner,
+			expiresAt: ticket.ExpiresAt,
+		}
+	})
 
-	userPID := types.NewPID(0)
-	if err := userPID.ExtractFrom(checkDataStream); err != nil {
-		return nil, 0, 0, err
-	}
+	return used.owner == owner
+}
 
-	if userPID != ticket.SourcePID {
-		return nil, 0, 0, errors.New("User PID and ticket source PID mismatch")
-	}
+// pruneUsedKerberosTickets forgets used tickets once they expire, since expired tickets are rejected anyway.
+// Runs at most once a second
+func (pep *PRUDPEndPoint) pruneUsedKerberosTickets(now time.Time) {
+	pep.usedKerberosTicketsPruneLock.Lock()
 
-	_, err = checkDataStream.ReadUInt32LE() // * CID of secure server station url
-	if err != nil {
-		return nil, 0, 0, err
+	if now.Sub(pep.usedKerberosTicketsPrunedAt) < time.Second {
+		pep.usedKerberosTicketsPruneLock.Unlock()
+		return
 	}
 
-	responseCheck, err := checkDataStream.ReadUInt32LE()
-	if err != nil {
-		return nil, 0, 0, err
-	}
+	pep.usedKerberosTicketsPrunedAt = now
+	pep.usedKerberosTicketsPruneLock.Unlock()
 
-	return sessionKey, userPID, responseCheck, nil
+	pep.usedKerberosTickets.DeleteIf(func(_ string, used usedKerberosTicket) bool {
+		return now.After(used.expiresAt)
+	})
 }
 
 func (pep *PRUDPEndPoint) acknowledgePacket(packet PRUDPPacketInterface) {
@@ -576,27 +636,42 @@ func (pep *PRUDPEndPoint) handleReliable(packet PRUDPPacketInterface) {
 				decryptedPayload = nextPacket.Payload()
 			}
 
-			decompressedPayload, err := connection.StreamSettings.CompressionAlgorithm.Decompress(decryptedPayload)
+			decompressedPayload, err := connection.streamSettings().CompressionAlgorithm.Decompress(decryptedPayload)
 			if err != nil {
-				logger.Error(err.Error())
+				// * The message can not be rebuilt without this
+				// * fragment, so drop any fragments already buffered
+				connection.ClearOutgoingBuffer(substreamID)
+				packetDispatchQueue.Dispatched(nextPacket)
+				pep.handleError(ResultCodes.Transport.DecompressionFailure, nextPacket, err)
+				continue
 			}
 
 			incomingFragmentBuffer := connection.GetIncomingFragmentBuffer(substreamID)
 			incomingFragmentBuffer = append(incomingFragmentBuffer, decompressedPayload...)
 			connection.SetIncomingFragmentBuffer(substreamID, incomingFragmentBuffer)
+			connection.trackIncomingFragment(substreamID)
 
 			if nextPacket.getFragmentID() == 0 {
+				pep.Server.Metrics.fragmentsReassembled(pep, len(incomingFragmentBuffer))
+
 				message := NewRMCMessage(pep)
 				err := message.FromBytes(incomingFragmentBuffer)
-				if err != nil {
-					// TODO - Should this return the error too?
-					logger.Error(err.Error())
-				}
+
+				reassembly, _ := connection.fragmentReassemblies.Get(substreamID)
 
 				nextPacket.SetRMCMessage(message)
 				connection.ClearOutgoingBuffer(substreamID)
 
-				pep.emit("data", nextPacket)
+				if err != nil {
+					packetDispatchQueue.Dispatched(nextPacket)
+					pep.handleError(ResultCodes.Core.InvalidArgument, nextPacket, err)
+					continue
+				}
+
+				connection.startTrace(message, reassembly, "substream_id", substreamID, "reliable", true, "size", len(incomingFragmentBuffer))
+
+				pep.Server.Metrics.rmcRequest("prudp", message)
+				pep.dispatchData(nextPacket)
 			}
 		}
 
@@ -647,7 +722,7 @@ func (pep *PRUDPEndPoint) handleUnreliable(packet PRUDPPacketInterface) {
 	// * fragments and resulting in a bad decryption
 	// TODO - Is this actually true? I'm just assuming, based on common sense, tbh. Kinnay also does not implement fragmented unreliable packets?
 	if packet.getFragmentID() != 0 {
-		logger.Warningf("Unexpected unreliable fragment ID. Expected 0, got %d", packet.getFragmentID())
+		pep.handleError(ResultCodes.Core.InvalidSequence, packet, fmt.Errorf("Unexpected unreliable fragment ID. Expected 0, got %d", packet.getFragmentID()))
 		return
 	}
 
@@ -655,14 +730,19 @@ func (pep *PRUDPEndPoint) handleUnreliable(packet PRUDPPacketInterface) {
 
 	message := NewRMCMessage(pep)
 	err := message.FromBytes(payload)
+
+	packet.SetRMCMessage(message)
+
 	if err != nil {
-		// TODO - Should this return the error too?
-		logger.Error(err.Error())
+		pep.handleError(ResultCodes.Core.InvalidArgument, packet, err)
+		return
 	}
 
-	packet.SetRMCMessage(message)
+	connection := packet.Sender().(*PRUDPConnection)
+	connection.startTrace(message, fragmentReassembly{startedAt: time.Now(), fragments: 1}, "reliable", false, "size", len(payload))
 
-	pep.emit("data", packet)
+	pep.Server.Metrics.rmcRequest("prudp", message)
+	pep.dispatchData(packet)
 }
 
 func (pep *PRUDPEndPoint) sendPing(connection *PRUDPConnection) {
@@ -688,6 +768,66 @@ func (pep *PRUDPEndPoint) sendPing(connection *PRUDPConnection) {
 	pep.Server.sendPacket(ping)
 }
 
+// sendRMCResponse sends an RMC response to the connection, in reply to the given request packet
+func (pep *PRUDPEndPoint) sendRMCResponse(connection *PRUDPConnection, request PRUDPPacketInterface, response *RMCMessage) {
+	var responsePacket PRUDPPacketInterface
+
+	switch request.Version() {
+	case 0:
+		responsePacket, _ = NewPRUDPPacketV0(pep.Server, connection, nil)
+	case 1:
+		responsePacket, _ = NewPRUDPPacketV1(pep.Server, connection, nil)
+	case 2:
+		responsePacket, _ = NewPRUDPPacketLite(pep.Server, connection, nil)
+	}
+
+	responsePacket.SetType(constants.DataPacket)
+	responsePacket.AddFlag(constants.PacketFlagHasSize)
+	responsePacket.AddFlag(constants.PacketFlagReliable)
+	responsePacket.AddFlag(constants.PacketFlagNeedsAck)
+	responsePacket.SetSourceVirtualPortStreamType(request.DestinationVirtualPortStreamType())
+	responsePacket.SetSourceVirtualPortStreamID(request.DestinationVirtualPortStreamID())
+	responsePacket.SetDestinationVirtualPortStreamType(request.SourceVirtualPortStreamType())
+	responsePacket.SetDestinationVirtualPortStreamID(request.SourceVirtualPortStreamID())
+	responsePacket.SetSubstreamID(request.SubstreamID())
+	responsePacket.SetRMCMessage(response)
+	responsePacket.SetPayload(response.Bytes())
+
+	pep.Server.send(responsePacket)
+}
+
+// Disconnect sends a DISCONNECT packet to the connection and removes it from the endpoint
+func (pep *PRUDPEndPoint) Disconnect(connection *PRUDPConnection) {
+	var disconnect PRUDPPacketInterface
+
+	switch connection.DefaultPRUDPVersion {
+	case 0:
+		disconnect, _ = NewPRUDPPacketV0(pep.Server, connection, nil)
+	case 1:
+		disconnect, _ = NewPRUDPPacketV1(pep.Server, connection, nil)
+	case 2:
+		disconnect, _ = NewPRUDPPacketLite(pep.Server, connection, nil)
+	}
+
+	disconnect.SetType(constants.DisconnectPacket)
+	disconnect.SetSourceVirtualPortStreamType(connection.StreamType)
+	disconnect.SetSourceVirtualPortStreamID(pep.StreamID)
+	disconnect.SetDestinationVirtualPortStreamType(connection.StreamType)
+	disconnect.SetDestinationVirtualPortStreamID(connection.StreamID)
+	disconnect.SetSubstreamID(0)
+
+	// * The connection is removed right away, so the packet
+	// * will never be resent. Send it 3 times, the same as
+	// * the DISCONNECT ACK, to make it more likely to arrive
+	if connection.state() == StateConnected {
+		pep.Server.sendPacket(disconnect)
+		pep.Server.sendPacket(disconnect)
+		pep.Server.sendPacket(disconnect)
+	}
+
+	pep.cleanupConnection(connection)
+}
+
 // FindConnectionByID returns the PRUDP client connected with the given connection ID
 func (pep *PRUDPEndPoint) FindConnectionByID(connectedID uint32) *PRUDPConnection {
 	var connection *PRUDPConnection
@@ -709,7 +849,7 @@ func (pep *PRUDPEndPoint) FindConnectionByPID(pid uint64) *PRUDPConnection {
 	var connection *PRUDPConnection
 
 	pep.Connections.Each(func(discriminator string, pc *PRUDPConnection) bool {
-		if uint64(pc.pid) == pid && pc.ConnectionState == StateConnected {
+		if uint64(pc.PID()) == pid && pc.state() == StateConnected {
 			connection = pc
 			return true
 		}
@@ -720,10 +860,85 @@ func (pep *PRUDPEndPoint) FindConnectionByPID(pid uint64) *PRUDPConnection {
 	return connection
 }
 
+// UpdateStreamSettings replaces the DefaultStreamSettings used by new connections. The settings which are safe
+// to change mid-stream, such as the retransmission settings and MaxSilenceTime, are also applied to every existing connection
+func (pep *PRUDPEndPoint) UpdateStreamSettings(settings *StreamSettings) {
+	pep.settingsLock.Lock()
+	pep.DefaultStreamSettings = settings.Copy()
+	pep.settingsLock.Unlock()
+
+	pep.Connections.Each(func(_ string, connection *PRUDPConnection) bool {
+		connection.updateStreamSettings(settings)
+		return false
+	})
+}
+
+// defaultStreamSettings returns the DefaultStreamSettings. The returned settings are never modified,
+// as updates replace them, so they may be used after the lock is released
+func (pep *PRUDPEndPoint) defaultStreamSettings() *StreamSettings {
+	pep.settingsLock.RLock()
+	defer pep.settingsLock.RUnlock()
+
+	return pep.DefaultStreamSettings
+}
+
+// handlerTimeout returns the HandlerTimeout
+func (pep *PRUDPEndPoint) handlerTimeout() time.Duration {
+	pep.settingsLock.RLock()
+	defer pep.settingsLock.RUnlock()
+
+	return pep.HandlerTimeout
+}
+
+// kerberosTicketService returns the service which validates the Kerberos tickets of secure CONNECTs, creating it on first use.
+// Tickets may be validated while the settings change, so the service is replaced rather than modified when
+// KerberosTicketLifetime or KerberosClockSkew no longer match it. The service has no AccountStore, since the
+// endpoints AccountStore may be changed at any time. readKerberosTicket passes the current one on each CONNECT
+func (pep *PRUDPEndPoint) kerberosTicketService() *KerberosTicketService {
+	pep.settingsLock.RLock()
+	service := pep.ticketService
+	current := pep.ticketServiceIsCurrent()
+	pep.settingsLock.RUnlock()
+
+	if current {
+		return service
+	}
+
+	pep.settingsLock.Lock()
+	defer pep.settingsLock.Unlock()
+
+	// * Another CONNECT may have replaced it while the lock was released
+	if !pep.ticketServiceIsCurrent() {
+		pep.ticketService = NewKerberosTicketService(pep.Server, nil)
+		pep.ticketService.TicketLifetime = pep.KerberosTicketLifetime
+		pep.ticketService.ClockSkew = pep.KerberosClockSkew
+	}
+
+	return pep.ticketService
+}
+
+// ticketServiceIsCurrent returns true if the ticket service uses the current Kerberos settings.
+// Must be called with the settingsLock held
+func (pep *PRUDPEndPoint) ticketServiceIsCurrent() bool {
+	return pep.ticketService != nil && pep.ticketService.TicketLifetime == pep.KerberosTicketLifetime && pep.ticketService.ClockSkew == pep.KerberosClockSkew
+}
+
+// reloadSettings applies the settings of the reloaded endpoint which can safely change while clients are connected
+func (pep *PRUDPEndPoint) reloadSettings(reloaded *PRUDPEndPoint) {
+	pep.settingsLock.Lock()
+	pep.HandlerTimeout = reloaded.HandlerTimeout
+	pep.KerberosTicketLifetime = reloaded.KerberosTicketLifetime
+	pep.KerberosClockSkew = reloaded.KerberosClockSkew
+	pep.settingsLock.Unlock()
+
+	pep.UpdateStreamSettings(reloaded.DefaultStreamSettings)
+}
+
 // ComputeRetransmitTimeout computes the RTO (Retransmit timeout) for a given packet
 func (pep *PRUDPEndPoint) ComputeRetransmitTimeout(packet PRUDPPacketInterface) time.Duration {
 	connection := packet.Sender().(*PRUDPConnection)
 	rtt := connection.rtt
+	streamSettings := connection.streamSettings()
 
 	if callback := pep.CalcRetransmissionTimeoutCallback; callback != nil {
 		rttAverage := rtt.GetRTTSmoothedAvg()
@@ -733,9 +948,9 @@ func (pep *PRUDPEndPoint) ComputeRetransmitTimeout(packet PRUDPPacketInterface)
 
 	var retransmitTimeBase int64
 	if packet.Type() == constants.SynPacket {
-		retransmitTimeBase = int64(pep.DefaultStreamSettings.SynInitialRTT)
+		retransmitTimeBase = int64(streamSettings.SynInitialRTT)
 	} else {
-		retransmitTimeBase = int64(pep.DefaultStreamSettings.InitialRTT)
+		retransmitTimeBase = int64(streamSettings.InitialRTT)
 		if rtt.Initialized() {
 			retransmitTimeBase = int64(rtt.Average()/time.Millisecond) / 8
 		}
@@ -744,10 +959,10 @@ func (pep *PRUDPEndPoint) ComputeRetransmitTimeout(packet PRUDPPacketInterface)
 	retransmitTimeBaseMultiplier := packet.SendCount()
 
 	var retransmitMultiplier float64
-	if packet.SendCount() < pep.DefaultStreamSettings.ExtraRetransmitTimeoutTrigger {
-		retransmitMultiplier = float64(pep.DefaultStreamSettings.RetransmitTimeoutMultiplier)
+	if packet.SendCount() < streamSettings.ExtraRetransmitTimeoutTrigger {
+		retransmitMultiplier = float64(streamSettings.RetransmitTimeoutMultiplier)
 	} else {
-		retransmitMultiplier = float64(pep.DefaultStreamSettings.ExtraRetransmitTimeoutMultiplier)
+		retransmitMultiplier = float64(streamSettings.ExtraRetransmitTimeoutMultiplier)
 	}
 
 	return time.Duration(float64(retransmitTimeBase*int64(retransmitTimeBaseMultiplier))*retransmitMultiplier) * time.Millisecond
@@ -773,6 +988,18 @@ func (pep *PRUDPEndPoint) LibraryVersions() *LibraryVersions {
 	return pep.Server.LibraryVersions
 }
 
+// accountStore returns the AccountStore of the endpoint, falling back to the deprecated lookup functions
+func (pep *PRUDPEndPoint) accountStore() AccountStore {
+	if pep.AccountStore != nil {
+		return pep.AccountStore
+	}
+
+	return AccountStoreFuncs{
+		ByPID:      pep.AccountDetailsByPID,
+		ByUsername: pep.AccountDetailsByUsername,
+	}
+}
+
 // ByteStreamSettings returns the settings to be used for ByteStreams
 func (pep *PRUDPEndPoint) ByteStreamSettings() *ByteStreamSettings {
 	return pep.Server.ByteStreamSettings
@@ -805,6 +1032,10 @@ func NewPRUDPEndPoint(streamID uint8) *PRUDPEndPoint {
 		errorEventHandlers:           make([]func(err *Error), 0),
 		ConnectionIDCounter:          NewCounter[uint32](0),
 		IsSecureEndPoint:             false,
+		ErrorPolicy:                  DefaultErrorPolicy,
+		KerberosTicketLifetime:       DefaultKerberosTicketLifetime,
+		KerberosClockSkew:            DefaultKerberosClockSkew,
+		usedKerberosTickets:          NewMutexMap[string, usedKerberosTicket](),
 	}
 
 	pep.packetHandlers[constants.SynPacket] = pep.handleSyn
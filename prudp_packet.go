@@ -1,7 +1,9 @@
 package nex
 
 import (
+	"context"
 	"crypto/rc4"
+	"sync/atomic"
 	"time"
 
 	"github.com/PretendoNetwork/nex-go/v2/constants"
@@ -25,9 +27,11 @@ type PRUDPPacket struct {
 	fragmentID             uint8
 	payload                []byte
 	message                *RMCMessage
-	sendCount              uint32
-	sentAt                 time.Time
+	sendCount              atomic.Uint32 // * Written by the TimeoutManager while acknowledgements read it
+	sentAt                 atomic.Int64  // * Unix nanoseconds. Written by the TimeoutManager while acknowledgements read it
 	timeout                *Timeout
+	ctx                    context.Context
+	rawSize                int // * Size of the packet as it was read from the socket. 0 for packets created by the server
 }
 
 // SetSender sets the Client who sent the packet
@@ -178,6 +182,20 @@ func (p *PRUDPPacket) setFragmentID(fragmentID uint8) {
 	p.fragmentID = fragmentID
 }
 
+// Context returns the context of the request the packet carries. It is cancelled when the connection
+// ends, or once the request times out or is responded to. Packets which do not carry a request use the connections context
+func (p *PRUDPPacket) Context() context.Context {
+	if p.ctx == nil {
+		return context.Background()
+	}
+
+	return p.ctx
+}
+
+func (p *PRUDPPacket) setContext(ctx context.Context) {
+	p.ctx = ctx
+}
+
 // RMCMessage returns the packets RMC Message
 func (p *PRUDPPacket) RMCMessage() *RMCMessage {
 	return p.message
@@ -190,20 +208,25 @@ func (p *PRUDPPacket) SetRMCMessage(message *RMCMessage) {
 
 // SendCount returns the number of times this packet has been sent
 func (p *PRUDPPacket) SendCount() uint32 {
-	return p.sendCount
+	return p.sendCount.Load()
 }
 
 func (p *PRUDPPacket) incrementSendCount() {
-	p.sendCount++
+	p.sendCount.Add(1)
 }
 
 // SentAt returns the latest time that this packet has been sent
 func (p *PRUDPPacket) SentAt() time.Time {
-	return p.sentAt
+	sentAt := p.sentAt.Load()
+	if sentAt == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, sentAt)
 }
 
 func (p *PRUDPPacket) setSentAt(time time.Time) {
-	p.sentAt = time
+	p.sentAt.Store(time.UnixNano())
 }
 
 func (p *PRUDPPacket) getTimeout() *Timeout {
@@ -214,6 +237,10 @@ func (p *PRUDPPacket) setTimeout(timeout *Timeout) {
 	p.timeout = timeout
 }
 
+func (p *PRUDPPacket) getRawSize() int {
+	return p.rawSize
+}
+
 func (p *PRUDPPacket) processUnreliableCrypto() []byte {
 	// * Since unreliable DATA packets can come in out of
 	// * order, each packet uses a dedicated RC4 stream
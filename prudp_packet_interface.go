@@ -1,6 +1,7 @@
 package nex
 
 import (
+	"context"
 	"net"
 	"time"
 
@@ -37,6 +38,8 @@ type PRUDPPacketInterface interface {
 	SetPayload(payload []byte)
 	RMCMessage() *RMCMessage
 	SetRMCMessage(message *RMCMessage)
+	Context() context.Context
+	setContext(ctx context.Context)
 	SendCount() uint32
 	incrementSendCount()
 	SentAt() time.Time
@@ -53,4 +56,5 @@ type PRUDPPacketInterface interface {
 	getFragmentID() uint8
 	setFragmentID(fragmentID uint8)
 	processUnreliableCrypto() []byte
+	getRawSize() int
 }
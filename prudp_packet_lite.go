@@ -42,7 +42,7 @@ func (p *PRUDPPacketLite) SetSourceVirtualPortStreamID(port uint8) {
 
 // SourceVirtualPortStreamID returns the packets source VirtualPort port number
 func (p *PRUDPPacketLite) SourceVirtualPortStreamID() uint8 {
-	return p.sourceVirtualPort.StreamID()
+	return p.sourceVirtualPortStreamID
 }
 
 // SetDestinationVirtualPortStreamType sets the packets destination VirtualPort constants.StreamType
@@ -310,7 +310,7 @@ func (p *PRUDPPacketLite) calculateConnectionSignature(addr net.Addr) ([]byte, e
 	binary.BigEndian.PutUint16(portBytes, uint16(port))
 
 	data := append(ip, portBytes...)
-	hash := hmac.New(md5.New, p.server.PRUDPv1ConnectionSignatureKey)
+	hash := hmac.New(md5.New, p.server.connectionSignatureKey())
 	hash.Write(data)
 
 	return hash.Sum(nil), nil
@@ -333,10 +333,14 @@ func NewPRUDPPacketLite(server *PRUDPServer, connection *PRUDPConnection, readSt
 	packet.server = server
 
 	if readStream != nil {
+		start := readStream.ByteOffset()
+
 		err := packet.decode()
 		if err != nil {
 			return nil, fmt.Errorf("Failed to decode PRUDPLite packet. %s", err.Error())
 		}
+
+		packet.rawSize = int(readStream.ByteOffset() - start)
 	}
 
 	return packet, nil
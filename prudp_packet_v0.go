@@ -257,10 +257,14 @@ func NewPRUDPPacketV0(server *PRUDPServer, connection *PRUDPConnection, readStre
 	packet.server = server
 
 	if readStream != nil {
+		start := readStream.ByteOffset()
+
 		err := packet.decode()
 		if err != nil {
 			return nil, fmt.Errorf("Failed to decode PRUDPv0 packet. %s", err.Error())
 		}
+
+		packet.rawSize = int(readStream.ByteOffset() - start)
 	}
 
 	return packet, nil
@@ -21,6 +21,7 @@ type PRUDPPacketV1 struct {
 	supportedFunctions          uint32
 	maximumSubstreamID          uint8
 	initialUnreliableSequenceID uint16
+	connectionSignatureKey      []byte // * Overrides the servers current key, to check signatures made with other accepted keys
 }
 
 // Copy copies the packet into a new PRUDPPacketV1
@@ -306,6 +307,16 @@ func (p *PRUDPPacketV1) encodeOptions() []byte {
 	return optionsStream.Bytes()
 }
 
+// ConnectionSignatureKey returns the key the packets connection signature is made with.
+// Custom PRUDPV1Settings.ConnectionSignatureCalculator functions should use it, so the key can be rotated
+func (p *PRUDPPacketV1) ConnectionSignatureKey() []byte {
+	if p.connectionSignatureKey != nil {
+		return p.connectionSignatureKey
+	}
+
+	return p.server.connectionSignatureKey()
+}
+
 func (p *PRUDPPacketV1) calculateConnectionSignature(addr net.Addr) ([]byte, error) {
 	return p.server.PRUDPV1Settings.ConnectionSignatureCalculator(p, addr)
 }
@@ -327,10 +338,14 @@ func NewPRUDPPacketV1(server *PRUDPServer, connection *PRUDPConnection, readStre
 	packet.server = server
 
 	if readStream != nil {
+		start := readStream.ByteOffset()
+
 		err := packet.decode()
 		if err != nil {
 			return nil, fmt.Errorf("Failed to decode PRUDPv1 packet. %s", err.Error())
 		}
+
+		packet.rawSize = int(readStream.ByteOffset() - start)
 	}
 
 	return packet, nil
@@ -368,7 +383,7 @@ func defaultPRUDPv1ConnectionSignature(packet *PRUDPPacketV1, addr net.Addr) ([]
 	binary.BigEndian.PutUint16(portBytes, uint16(port))
 
 	data := append(ip, portBytes...)
-	hash := hmac.New(md5.New, packet.server.PRUDPv1ConnectionSignatureKey)
+	hash := hmac.New(md5.New, packet.ConnectionSignatureKey())
 	hash.Write(data)
 
 	return hash.Sum(nil), nil
@@ -2,9 +2,14 @@ package nex
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/PretendoNetwork/nex-go/v2/constants"
@@ -14,25 +19,40 @@ import (
 // PRUDPServer represents a bare-bones PRUDP server
 type PRUDPServer struct {
 	udpSocket                     *net.UDPConn
+	udpSockets                    []*net.UDPConn // * Every socket being read from, closed by Shutdown
+	udpBatchWriters               []*udpBatchWriter
 	websocketServer               *WebSocketServer
+	websocketServerLock           sync.Mutex // * Guards websocketServer, which is created on first use
 	Endpoints                     *MutexMap[uint8, *PRUDPEndPoint]
 	SupportedFunctions            uint32
 	AccessKey                     string
 	KerberosTicketVersion         int
 	SessionKeyLength              int
 	FragmentSize                  int
-	PRUDPv1ConnectionSignatureKey []byte
+	PRUDPv1ConnectionSignatureKey []byte                      // * Used for PRUDPv1 connection signatures if ConnectionSignatureKeyRing is nil. Random if not set
+	ConnectionSignatureKeyRing    *ConnectionSignatureKeyRing // * Rotates the PRUDPv1 connection signature key, and shares it between nodes in a cluster
 	LibraryVersions               *LibraryVersions
 	ByteStreamSettings            *ByteStreamSettings
 	PRUDPV0Settings               *PRUDPV0Settings
 	PRUDPV1Settings               *PRUDPV1Settings
+	UDPSettings                   *UDPSettings
+	WebSocketSettings             *WebSocketSettings
+	Metrics                       *Metrics
+	Capture                       *PacketCapture
+	BanList                       *BanList     // * Datagrams from banned addresses are dropped, and banned PIDs cannot connect to secure endpoints
+	RateLimiter                   *RateLimiter // * Datagrams over the limit of their address are dropped. Not limited by default
+	Logger                        Logger
+	LogLevel                      *slog.LevelVar // * Messages below this level are not passed to the Logger. Passes every message by default
+	Tracer                        Tracer
 	UseVerboseRMC                 bool
+	SessionSnapshotPath           string // * If set, Shutdown writes a SessionSnapshot of the open connections to this file
+	closing                       atomic.Bool
 }
 
 // BindPRUDPEndPoint binds a provided PRUDPEndPoint to the server
 func (ps *PRUDPServer) BindPRUDPEndPoint(endpoint *PRUDPEndPoint) {
 	if ps.Endpoints.Has(endpoint.StreamID) {
-		logger.Warningf("Tried to bind already existing PRUDPEndPoint %d", endpoint.StreamID)
+		ps.log().Warn("Tried to bind already existing PRUDPEndPoint", "stream_id", endpoint.StreamID)
 		return
 	}
 
@@ -68,10 +88,29 @@ func (ps *PRUDPServer) listenAndServeUDP(addr string) error {
 	}
 
 	ps.udpSocket = socket
+	ps.udpSockets = []*net.UDPConn{socket}
 
+	err = serveUDP(socket, func(packetData []byte, address net.Addr) {
+		go ps.handleSocketMessage(packetData, address, nil)
+	})
+
+	return ps.serveError(err)
+}
+
+// serveError returns nil for errors caused by Shutdown closing the sockets
+func (ps *PRUDPServer) serveError(err error) error {
+	if ps.closing.Load() && errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// serveUDP reads datagrams from the socket one at a time until an error occurs, calling handler for each one
+func serveUDP(socket *net.UDPConn, handler func(packetData []byte, address net.Addr)) error {
 	buffer := make([]byte, 64000)
 	for {
-		read, addr, err := ps.udpSocket.ReadFromUDP(buffer)
+		read, addr, err := socket.ReadFromUDP(buffer)
 		if err != nil {
 			return fmt.Errorf("reading from udp socket: %w", err)
 		}
@@ -79,30 +118,52 @@ func (ps *PRUDPServer) listenAndServeUDP(addr string) error {
 		packetData := make([]byte, read)
 		copy(packetData, buffer[:read])
 
-		go ps.handleSocketMessage(packetData, addr, nil)
+		handler(packetData, addr)
 	}
 }
 
-// ListenWebSocket starts a PRUDP server on a given port using a WebSocket server
-func (ps *PRUDPServer) ListenWebSocket(port int) {
+// ListenUDPMulticore starts a PRUDP server on a given port using multiple UDP sockets.
+//
+// On Linux this opens UDPSettings.Readers sockets bound with SO_REUSEPORT, each read by it's own goroutine,
+// and uses recvmmsg/sendmmsg to read and write datagrams in batches. Only IPv4 is supported in this mode.
+// On other platforms this behaves the same as ListenUDP
+func (ps *PRUDPServer) ListenUDPMulticore(port int) {
 	ps.initPRUDPv1ConnectionSignatureKey()
 
-	ps.websocketServer = &WebSocketServer{
-		prudpServer: ps,
+	err := ps.listenAndServeUDPMulticore(fmt.Sprintf(":%d", port))
+	if err != nil {
+		// panic instead of log.Fatal() to keep backwards compat behaviour
+		panic(err)
 	}
+}
 
-	ps.websocketServer.listen(port)
+// ListenWebSocket starts a PRUDP server on a given port using a WebSocket server.
+// Connections are accepted on WebSocketSettings.Path
+func (ps *PRUDPServer) ListenWebSocket(port int) {
+	ps.WebSocketServer().listen(port)
 }
 
-// ListenWebSocketSecure starts a PRUDP server on a given port using a secure (TLS) WebSocket server
+// ListenWebSocketSecure starts a PRUDP server on a given port using a secure (TLS) WebSocket server.
+// Connections are accepted on WebSocketSettings.Path
 func (ps *PRUDPServer) ListenWebSocketSecure(port int, certFile, keyFile string) {
-	ps.initPRUDPv1ConnectionSignatureKey()
+	ps.WebSocketServer().listenSecure(port, certFile, keyFile)
+}
 
-	ps.websocketServer = &WebSocketServer{
-		prudpServer: ps,
+// WebSocketServer returns the servers WebSocketServer, creating it from WebSocketSettings on the first call.
+// The WebSocketServer is an http.Handler which can be mounted into an existing HTTP server instead of using ListenWebSocket
+func (ps *PRUDPServer) WebSocketServer() *WebSocketServer {
+	ps.websocketServerLock.Lock()
+	defer ps.websocketServerLock.Unlock()
+
+	if ps.websocketServer == nil {
+		ps.initPRUDPv1ConnectionSignatureKey()
+		ps.websocketServer = NewWebSocketServer(ps)
+
+		// * Shutdown may have already checked for a WebSocketServer
+		ps.websocketServer.closing.Store(ps.closing.Load())
 	}
 
-	ps.websocketServer.listenSecure(port, certFile, keyFile)
+	return ps.websocketServer
 }
 
 func (ps *PRUDPServer) initPRUDPv1ConnectionSignatureKey() {
@@ -116,7 +177,34 @@ func (ps *PRUDPServer) initPRUDPv1ConnectionSignatureKey() {
 	}
 }
 
-func (ps *PRUDPServer) handleSocketMessage(packetData []byte, address net.Addr, webSocketConnection *gws.Conn) error {
+// handleSocketMessage processes a datagram or WebSocket message. The socket is only set for WebSocket messages,
+// since UDP clients do not have a persistent socket
+func (ps *PRUDPServer) handleSocketMessage(packetData []byte, address net.Addr, socket *SocketConnection) error {
+	// * Checked before anything else so floods from banned
+	// * or limited addresses cost as little as possible
+	if ps.BanList.AddressBanned(address) {
+		ps.log().Debug("Dropped datagram from banned address", "address", address.String())
+		return nil
+	}
+
+	if !ps.RateLimiter.Allow(address) {
+		ps.log().Debug("Dropped rate limited datagram", "address", address.String())
+		return nil
+	}
+
+	if err := ps.Capture.recordInbound(packetData, address); err != nil {
+		ps.log().Error(err.Error(), "address", address.String())
+	}
+
+	// * Every packet version starts with at least 2 bytes of header,
+	// * which are checked below to tell the versions apart
+	if len(packetData) < 2 {
+		err := fmt.Errorf("datagram from %s is %d bytes, too short to be a PRUDP packet", address.String(), len(packetData))
+		ps.log().Warn(err.Error(), "address", address.String())
+
+		return err
+	}
+
 	readStream := NewByteStreamIn(packetData, ps.LibraryVersions, ps.ByteStreamSettings)
 
 	var packets []PRUDPPacketInterface
@@ -125,7 +213,7 @@ func (ps *PRUDPServer) handleSocketMessage(packetData []byte, address net.Addr,
 	// * with that same type. Also keep reading from the stream
 	// * until no more data is left, to account for multiple
 	// * packets being sent at once
-	if ps.websocketServer != nil && packetData[0] == 0x80 {
+	if socket != nil && socket.session != nil && packetData[0] == 0x80 {
 		packets, _ = NewPRUDPPacketsLite(ps, nil, readStream)
 	} else if bytes.Equal(packetData[:2], []byte{0xEA, 0xD0}) {
 		packets, _ = NewPRUDPPacketsV1(ps, nil, readStream)
@@ -134,9 +222,15 @@ func (ps *PRUDPServer) handleSocketMessage(packetData []byte, address net.Addr,
 	}
 
 	for _, packet := range packets {
-		err := ps.processPacket(packet, address, webSocketConnection)
+		// * Only acknowledgements are processed while shutting down,
+		// * so packets which are already sent can still be acknowledged
+		if ps.closing.Load() && !packet.HasFlag(constants.PacketFlagAck) && !packet.HasFlag(constants.PacketFlagMultiAck) {
+			continue
+		}
+
+		err := ps.processPacket(packet, address, socket)
 		if err != nil {
-			logger.Warning(err.Error())
+			ps.log().Warn(err.Error(), "address", address.String(), "stream_id", packet.DestinationVirtualPortStreamID(), "substream_id", packet.SubstreamID())
 			// XXX: should we return here, or do we need to handle all packets regardless of failure?
 			return err
 		}
@@ -145,7 +239,7 @@ func (ps *PRUDPServer) handleSocketMessage(packetData []byte, address net.Addr,
 	return nil
 }
 
-func (ps *PRUDPServer) processPacket(packet PRUDPPacketInterface, address net.Addr, webSocketConnection *gws.Conn) error {
+func (ps *PRUDPServer) processPacket(packet PRUDPPacketInterface, address net.Addr, socket *SocketConnection) error {
 	if !ps.Endpoints.Has(packet.DestinationVirtualPortStreamID()) {
 		return fmt.Errorf("client %s trying to connect to unbound PRUDPEndPoint %d", address.String(), packet.DestinationVirtualPortStreamID())
 	}
@@ -182,39 +276,72 @@ func (ps *PRUDPServer) processPacket(packet PRUDPPacketInterface, address net.Ad
 		return fmt.Errorf("client %s trying to use invalid to source port number %d. Port number too large", address.String(), sourcePortNumber)
 	}
 
-	socket := NewSocketConnection(ps, address, webSocketConnection)
+	if socket == nil {
+		socket = NewSocketConnection(ps, address, nil)
+	}
+
 	endpoint.processPacket(packet, socket)
 	return nil
 }
 
-// Send sends the packet to the packets sender
+// log returns the Logger, filtered by the LogLevel
+func (ps *PRUDPServer) log() Logger {
+	return levelLogger{logger: ps.Logger, level: ps.LogLevel}
+}
+
+// Send sends the packet to the packets sender.
+//
+// Responses to requests which have already been responded to, such as after the endpoints HandlerTimeout, are dropped
 func (ps *PRUDPServer) Send(packet PacketInterface) {
 	if packet, ok := packet.(PRUDPPacketInterface); ok {
-		data := packet.Payload()
-		fragments := int(len(data) / ps.FragmentSize)
-
-		var fragmentID uint8 = 1
-		for i := 0; i <= fragments; i++ {
-			if len(data) < ps.FragmentSize {
-				packet.SetPayload(data)
-				packet.setFragmentID(0)
-			} else {
-				packet.SetPayload(data[:ps.FragmentSize])
-				packet.setFragmentID(fragmentID)
-
-				data = data[ps.FragmentSize:]
-				fragmentID++
-			}
+		connection := packet.Sender().(*PRUDPConnection)
 
-			ps.sendPacket(packet)
+		if !connection.completeCall(packet.RMCMessage()) {
+			ps.log().Debug("Dropping response to a call which was already responded to", packetLogFields(packet)...)
+			return
+		}
 
-			// * This delay is here to prevent the server from overloading the client with too many packets.
-			// * The 16ms (1/60th of a second) value is chosen based on testing with the friends server and is a good balance between
-			// * Not being too slow and also not dropping any packets because we've overloaded the client. This may be because it
-			// * roughly matches the framerate that most games target (60fps)
-			if i < fragments {
-				time.Sleep(16 * time.Millisecond)
-			}
+		ps.send(packet)
+	}
+}
+
+// send fragments and sends the packet without checking if it responds to a pending call
+func (ps *PRUDPServer) send(packet PRUDPPacketInterface) {
+	ps.Metrics.rmcResponse("prudp", packet.RMCMessage())
+
+	data := packet.Payload()
+	fragments := int(len(data) / ps.FragmentSize)
+
+	connection := packet.Sender().(*PRUDPConnection)
+	connection.traceResponse(packet, fragments+1)
+
+	var fragmentID uint8 = 1
+	for i := 0; i <= fragments; i++ {
+		if len(data) < ps.FragmentSize {
+			packet.SetPayload(data)
+			packet.setFragmentID(0)
+		} else {
+			packet.SetPayload(data[:ps.FragmentSize])
+			packet.setFragmentID(fragmentID)
+
+			data = data[ps.FragmentSize:]
+			fragmentID++
+		}
+
+		ps.sendPacket(packet)
+
+		// * This delay is here to prevent the server from overloading the client with too many packets.
+		// * The 16ms (1/60th of a second) value is chosen based on testing with the friends server and is a good balance between
+		// * Not being too slow and also not dropping any packets because we've overloaded the client. This may be because it
+		// * roughly matches the framerate that most games target (60fps)
+		if i < fragments {
+			time.Sleep(16 * time.Millisecond)
+		}
+	}
+
+	if !packet.HasFlag(constants.PacketFlagReliable) || !packet.HasFlag(constants.PacketFlagNeedsAck) {
+		if message := packet.RMCMessage(); message != nil && !message.IsRequest {
+			connection.endTrace(message.CallID, "nex.rmc.response.sent")
 		}
 	}
 }
@@ -250,12 +377,14 @@ func (ps *PRUDPServer) sendPacket(packet PRUDPPacketInterface) {
 
 			compressedPayload, err := slidingWindow.streamSettings.CompressionAlgorithm.Compress(payload)
 			if err != nil {
-				logger.Error(err.Error())
+				connection.endpoint.handleError(ResultCodes.Core.SystemError, packetCopy, err)
+				return
 			}
 
 			encryptedPayload, err := slidingWindow.streamSettings.EncryptionAlgorithm.Encrypt(compressedPayload)
 			if err != nil {
-				logger.Error(err.Error())
+				connection.endpoint.handleError(ResultCodes.RendezVous.EncryptionFailure, packetCopy, err)
+				return
 			}
 
 			packetCopy.SetPayload(encryptedPayload)
@@ -276,29 +405,140 @@ func (ps *PRUDPServer) sendPacket(packet PRUDPPacketInterface) {
 	packetCopy.incrementSendCount()
 	packetCopy.setSentAt(time.Now())
 
+	ps.Metrics.packetSent(packetCopy)
+
+	// * Encoding the packet updates it's fields, so it must be
+	// * done before the TimeoutManager can start resending it
+	data := packetCopy.Bytes()
+
 	if packetCopy.HasFlag(constants.PacketFlagReliable) && packetCopy.HasFlag(constants.PacketFlagNeedsAck) {
 		slidingWindow := connection.SlidingWindow(packetCopy.SubstreamID())
 		slidingWindow.TimeoutManager.SchedulePacketTimeout(packetCopy)
 	}
 
-	ps.sendRaw(packetCopy.Sender().(*PRUDPConnection).Socket, packetCopy.Bytes())
+	connection.counters.sent(len(data))
+
+	if err := ps.sendRaw(connection.Socket, data); err != nil {
+		connection.endpoint.handleError(ResultCodes.Transport.IOError, packetCopy, err)
+	}
 }
 
 // sendRaw will send the given socket the provided packet
-func (ps *PRUDPServer) sendRaw(socket *SocketConnection, data []byte) {
-	// TODO - Should this return the error too?
+func (ps *PRUDPServer) sendRaw(socket *SocketConnection, data []byte) error {
+	if err := ps.Capture.recordOutbound(data, socket.Address); err != nil {
+		ps.log().Error(err.Error(), "address", socket.Address.String())
+	}
 
 	var err error
 
-	if address, ok := socket.Address.(*net.UDPAddr); ok && ps.udpSocket != nil {
+	if address, ok := socket.Address.(*net.UDPAddr); ok && len(ps.udpBatchWriters) != 0 {
+		// * Always use the same socket for the same client
+		// * so datagrams are not reordered between sockets
+		writer := ps.udpBatchWriters[(int(address.Port)^int(address.IP[len(address.IP)-1]))%len(ps.udpBatchWriters)]
+		err = writer.write(data, address)
+	} else if address, ok := socket.Address.(*net.UDPAddr); ok && ps.udpSocket != nil {
 		_, err = ps.udpSocket.WriteToUDP(data, address)
+	} else if socket.session != nil {
+		err = socket.session.write(data)
 	} else if socket.WebSocketConnection != nil {
 		err = socket.WebSocketConnection.WriteMessage(gws.OpcodeBinary, data)
 	}
 
-	if err != nil {
-		logger.Error(err.Error())
+	return err
+}
+
+// Shutdown gracefully shuts down the server. From then on only acknowledgements are processed, and Shutdown
+// waits for every reliable packet already sent to be acknowledged, or for the context to expire.
+// The sockets are then closed and the connections stopped without ending them.
+//
+// If SessionSnapshotPath is set, the connections are written to it so clients can resume their sessions
+// once the server is restarted and RestoreSessions is called
+func (ps *PRUDPServer) Shutdown(ctx context.Context) error {
+	ps.closing.Store(true)
+
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+waitForAcknowledgements:
+	for ps.pendingPackets() != 0 {
+		select {
+		case <-ctx.Done():
+			ps.log().Warn("Shutting down with unacknowledged packets", "pending", ps.pendingPackets())
+			break waitForAcknowledgements
+		case <-ticker.C:
+		}
 	}
+
+	var shutdownErr error
+
+	ps.websocketServerLock.Lock()
+	websocketServer := ps.websocketServer
+	ps.websocketServerLock.Unlock()
+
+	if websocketServer != nil {
+		shutdownErr = websocketServer.Shutdown(ctx)
+	}
+
+	// * The batch writers are stopped first, so every queued
+	// * datagram is written before it's socket is closed
+	for _, writer := range ps.udpBatchWriters {
+		writer.close()
+	}
+
+	for _, socket := range ps.udpSockets {
+		socket.Close()
+	}
+
+	var snapshot *SessionSnapshot
+	if ps.SessionSnapshotPath != "" {
+		snapshot = ps.SnapshotSessions()
+	}
+
+	// * The connections are only stopped, not cleaned up, so
+	// * no connection ended events are fired for them
+	ps.Endpoints.Each(func(_ uint8, endpoint *PRUDPEndPoint) bool {
+		endpoint.Connections.Each(func(_ string, connection *PRUDPConnection) bool {
+			connection.stopHeartbeatTimers()
+			connection.slidingWindows.Each(func(_ uint8, slidingWindow *SlidingWindow) bool {
+				slidingWindow.TimeoutManager.Stop()
+				return false
+			})
+
+			return false
+		})
+
+		return false
+	})
+
+	if snapshot != nil {
+		if err := snapshot.WriteFile(ps.SessionSnapshotPath); err != nil {
+			return fmt.Errorf("Failed to write session snapshot. %s", err)
+		}
+
+		ps.log().Info("Wrote session snapshot", "path", ps.SessionSnapshotPath, "sessions", len(snapshot.Sessions))
+	}
+
+	return shutdownErr
+}
+
+// pendingPackets returns the number of reliable packets which have not been acknowledged yet
+func (ps *PRUDPServer) pendingPackets() int {
+	pending := 0
+
+	ps.Endpoints.Each(func(_ uint8, endpoint *PRUDPEndPoint) bool {
+		endpoint.Connections.Each(func(_ string, connection *PRUDPConnection) bool {
+			connection.slidingWindows.Each(func(_ uint8, slidingWindow *SlidingWindow) bool {
+				pending += slidingWindow.TimeoutManager.packets.Size()
+				return false
+			})
+
+			return false
+		})
+
+		return false
+	})
+
+	return pending
 }
 
 // SetFragmentSize sets the max size for a packets payload
@@ -327,5 +567,11 @@ func NewPRUDPServer() *PRUDPServer {
 		ByteStreamSettings: NewByteStreamSettings(),
 		PRUDPV0Settings:    NewPRUDPV0Settings(),
 		PRUDPV1Settings:    NewPRUDPV1Settings(),
+		UDPSettings:        NewUDPSettings(),
+		WebSocketSettings:  NewWebSocketSettings(),
+		BanList:            NewBanList(),
+		RateLimiter:        NewRateLimiter(0, 0),
+		Logger:             defaultLogger,
+		LogLevel:           newLogLevel(),
 	}
 }
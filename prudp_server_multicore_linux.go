@@ -0,0 +1,89 @@
+//go:build linux
+
+package nex
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"syscall"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/sys/unix"
+)
+
+// listenUDPReusePort opens a UDP socket with SO_REUSEPORT set, allowing many sockets to bind the same address.
+// The kernel then load balances incoming datagrams between them
+func listenUDPReusePort(addr string) (*net.UDPConn, error) {
+	listenConfig := net.ListenConfig{
+		Control: func(network, address string, rawConn syscall.RawConn) error {
+			var sockErr error
+
+			err := rawConn.Control(func(fd uintptr) {
+				sockErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+			})
+			if err != nil {
+				return err
+			}
+
+			return sockErr
+		},
+	}
+
+	// * Batched IO goes through ipv4.PacketConn, which
+	// * marshals IPv4 addresses as AF_INET. Those can not
+	// * be sent on a dual-stack AF_INET6 socket, so stick
+	// * to IPv4 only here
+	packetConn, err := listenConfig.ListenPacket(context.Background(), "udp4", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return packetConn.(*net.UDPConn), nil
+}
+
+func (ps *PRUDPServer) listenAndServeUDPMulticore(addr string) error {
+	settings := ps.UDPSettings
+	readers := max(settings.Readers, 1)
+	sockets := make([]*net.UDPConn, 0, readers)
+
+	for i := 0; i < readers; i++ {
+		socket, err := listenUDPReusePort(addr)
+		if err != nil {
+			for _, socket := range sockets {
+				socket.Close()
+			}
+
+			return fmt.Errorf("listening udp with SO_REUSEPORT: %w", err)
+		}
+
+		sockets = append(sockets, socket)
+	}
+
+	writers := make([]*udpBatchWriter, 0, readers)
+	for _, socket := range sockets {
+		writers = append(writers, newUDPBatchWriter(ipv4.NewPacketConn(socket), settings))
+	}
+
+	// * Any socket bound to the port can send to any client,
+	// * udpSocket is kept as a fallback for unbatched sends
+	ps.udpSocket = sockets[0]
+	ps.udpSockets = sockets
+	ps.udpBatchWriters = writers
+
+	for _, writer := range writers {
+		go writer.run()
+	}
+
+	errs := make(chan error, readers)
+	for _, socket := range sockets {
+		go func(conn *ipv4.PacketConn) {
+			errs <- serveUDPBatch(conn, settings, func(packetData []byte, address net.Addr) {
+				go ps.handleSocketMessage(packetData, address, nil)
+			})
+		}(ipv4.NewPacketConn(socket))
+	}
+
+	// * Like listenAndServeUDP, the first read error ends the server
+	return ps.serveError(<-errs)
+}
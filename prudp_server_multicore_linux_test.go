@@ -0,0 +1,146 @@
+//go:build linux
+
+package nex
+
+import (
+	"net"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/ipv4"
+)
+
+// * Datagrams are sent in windows and the sender waits for
+// * each window to be read, so the benchmarks measure the
+// * receive path rather than how fast loopback drops packets
+const benchmarkUDPWindow = 32
+
+func BenchmarkServeUDP(b *testing.B) {
+	socket, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	var received atomic.Int64
+	go serveUDP(socket, func(packetData []byte, address net.Addr) {
+		received.Add(1)
+	})
+
+	benchmarkUDPReceive(b, []*net.UDPConn{socket}, &received)
+}
+
+func BenchmarkServeUDPBatch(b *testing.B) {
+	benchmarkServeUDPBatch(b, 1)
+}
+
+func BenchmarkServeUDPBatchMulticore(b *testing.B) {
+	benchmarkServeUDPBatch(b, runtime.NumCPU())
+}
+
+func benchmarkServeUDPBatch(b *testing.B, readers int) {
+	settings := NewUDPSettings()
+	sockets := make([]*net.UDPConn, 0, readers)
+
+	var received atomic.Int64
+	for i := 0; i < readers; i++ {
+		var address string
+		if len(sockets) == 0 {
+			address = "127.0.0.1:0"
+		} else {
+			address = sockets[0].LocalAddr().String()
+		}
+
+		socket, err := listenUDPReusePort(address)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		sockets = append(sockets, socket)
+
+		go serveUDPBatch(ipv4.NewPacketConn(socket), settings, func(packetData []byte, address net.Addr) {
+			received.Add(1)
+		})
+	}
+
+	benchmarkUDPReceive(b, sockets, &received)
+}
+
+func benchmarkUDPReceive(b *testing.B, sockets []*net.UDPConn, received *atomic.Int64) {
+	defer func() {
+		for _, socket := range sockets {
+			socket.Close()
+		}
+	}()
+
+	// * One client per socket, since SO_REUSEPORT balances
+	// * by source address
+	clients := make([]*net.UDPConn, 0, len(sockets))
+	for range sockets {
+		client, err := net.DialUDP("udp4", nil, sockets[0].LocalAddr().(*net.UDPAddr))
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		defer client.Close()
+
+		clients = append(clients, client)
+	}
+
+	datagram := make([]byte, 1000)
+	var sent atomic.Int64
+
+	b.SetBytes(int64(len(datagram)))
+	b.ResetTimer()
+
+	var wg sync.WaitGroup
+	for i, client := range clients {
+		count := b.N / len(clients)
+		if i == 0 {
+			count += b.N % len(clients)
+		}
+
+		wg.Add(1)
+		go func(client *net.UDPConn, count int) {
+			defer wg.Done()
+
+			for count > 0 {
+				window := min(count, benchmarkUDPWindow)
+				for j := 0; j < window; j++ {
+					if _, err := client.Write(datagram); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+
+				count -= window
+
+				waitForUDPReceive(received, sent.Add(int64(window)))
+			}
+		}(client, count)
+	}
+
+	wg.Wait()
+
+	waitForUDPReceive(received, int64(b.N))
+
+	b.StopTimer()
+	b.ReportMetric(float64(int64(b.N)-received.Load()), "dropped")
+}
+
+// waitForUDPReceive waits until target datagrams have been read, or until reads stop making progress
+func waitForUDPReceive(received *atomic.Int64, target int64) {
+	last := received.Load()
+	lastProgress := time.Now()
+
+	for last < target && time.Since(lastProgress) < 5*time.Millisecond {
+		runtime.Gosched()
+
+		if current := received.Load(); current != last {
+			last = current
+			lastProgress = time.Now()
+		}
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package nex
+
+func (ps *PRUDPServer) listenAndServeUDPMulticore(addr string) error {
+	// * SO_REUSEPORT load balancing and recvmmsg/sendmmsg are Linux only
+	ps.log().Warn("Multi-core UDP is only supported on Linux, falling back to a single socket")
+
+	return ps.listenAndServeUDP(addr)
+}
@@ -0,0 +1,254 @@
+package nex
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/PretendoNetwork/nex-go/v2/constants"
+	"github.com/PretendoNetwork/nex-go/v2/encryption"
+	"github.com/stretchr/testify/assert"
+)
+
+// testPRUDPClient is a minimal PRUDPv1 client for testing endpoints.
+//
+// Packets are passed straight to the servers packet pipeline, and the servers responses
+// are sent to a real UDP socket which the client reads from
+type testPRUDPClient struct {
+	t                   *testing.T
+	server              *PRUDPServer
+	endpoint            *PRUDPEndPoint
+	socket              *net.UDPConn
+	address             *net.UDPAddr
+	connectionSignature []byte // * Sent by the server in it's SYN response
+	sequenceID          uint16
+	encryption          encryption.Algorithm // * Encrypts DATA payloads sent by the client. Uses the default RC4 key of non-secure endpoints
+	bytesSent           int
+	bytesReceived       int
+}
+
+// newTestPRUDPServer returns a server with an endpoint bound to stream ID 1, whose responses are sent over UDP
+func newTestPRUDPServer(t *testing.T) (*PRUDPServer, *PRUDPEndPoint) {
+	server := NewPRUDPServer()
+	server.initPRUDPv1ConnectionSignatureKey()
+
+	endpoint := NewPRUDPEndPoint(1)
+	server.BindPRUDPEndPoint(endpoint)
+
+	socket, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server.udpSocket = socket
+
+	t.Cleanup(func() {
+		server.Endpoints.Each(func(_ uint8, endpoint *PRUDPEndPoint) bool {
+			endpoint.Connections.Each(func(_ string, connection *PRUDPConnection) bool {
+				connection.stopHeartbeatTimers()
+				connection.slidingWindows.Each(func(_ uint8, slidingWindow *SlidingWindow) bool {
+					slidingWindow.TimeoutManager.Stop()
+					return false
+				})
+
+				return false
+			})
+
+			return false
+		})
+
+		socket.Close()
+	})
+
+	return server, endpoint
+}
+
+// newTestPRUDPClient returns a client for the endpoint, listening on a new UDP socket
+func newTestPRUDPClient(t *testing.T, endpoint *PRUDPEndPoint) *testPRUDPClient {
+	socket, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		socket.Close()
+	})
+
+	return &testPRUDPClient{
+		t:          t,
+		server:     endpoint.Server,
+		endpoint:   endpoint,
+		socket:     socket,
+		address:    socket.LocalAddr().(*net.UDPAddr),
+		sequenceID: 1,
+		encryption: encryption.NewRC4Encryption(),
+	}
+}
+
+// newPacket returns a packet from the client to the endpoint
+func (c *testPRUDPClient) newPacket(packetType uint16, flags ...uint16) *PRUDPPacketV1 {
+	packet, _ := NewPRUDPPacketV1(c.server, nil, nil)
+	packet.SetType(packetType)
+	packet.SetSourceVirtualPortStreamType(constants.StreamTypeRVSecure)
+	packet.SetSourceVirtualPortStreamID(15)
+	packet.SetDestinationVirtualPortStreamType(constants.StreamTypeRVSecure)
+	packet.SetDestinationVirtualPortStreamID(c.endpoint.StreamID)
+
+	for _, flag := range flags {
+		packet.AddFlag(flag)
+	}
+
+	if packetType == constants.SynPacket || packetType == constants.ConnectPacket {
+		packet.setConnectionSignature(make([]byte, 16))
+	}
+
+	return packet
+}
+
+// send passes the packet to the server as if it had been read from the socket
+func (c *testPRUDPClient) send(packet *PRUDPPacketV1) {
+	if packet.signature == nil {
+		packet.setSignature(make([]byte, 16))
+	}
+
+	data := packet.Bytes()
+	c.bytesSent += len(data)
+
+	_ = c.server.handleSocketMessage(data, c.address, nil)
+}
+
+// read returns the packets in the next datagram sent by the server, or nil if nothing is sent before the deadline
+func (c *testPRUDPClient) read(deadline time.Time) []PRUDPPacketInterface {
+	buffer := make([]byte, 2048)
+
+	_ = c.socket.SetReadDeadline(deadline)
+
+	read, _, err := c.socket.ReadFromUDP(buffer)
+	if err != nil {
+		return nil
+	}
+
+	c.bytesReceived += read
+
+	packets, err := NewPRUDPPacketsV1(c.server, nil, NewByteStreamIn(buffer[:read], c.server.LibraryVersions, c.server.ByteStreamSettings))
+	if !assert.NoError(c.t, err) {
+		c.t.FailNow()
+	}
+
+	return packets
+}
+
+// receive returns the next packet of the given type sent by the server, skipping any others.
+// Acknowledgements of DATA packets sent by the client are also skipped
+func (c *testPRUDPClient) receive(packetType uint16) *PRUDPPacketV1 {
+	deadline := time.Now().Add(2 * time.Second)
+
+	for time.Now().Before(deadline) {
+		for _, packet := range c.read(deadline) {
+			if packet.Type() == constants.DataPacket && packet.HasFlag(constants.PacketFlagAck) {
+				continue
+			}
+
+			if packet.Type() == packetType {
+				return packet.(*PRUDPPacketV1)
+			}
+		}
+	}
+
+	c.t.Fatalf("Server did not send packet type %d", packetType)
+
+	return nil
+}
+
+// receiveFor returns every packet sent by the server within the given time
+func (c *testPRUDPClient) receiveFor(wait time.Duration) []PRUDPPacketInterface {
+	deadline := time.Now().Add(wait)
+	packets := make([]PRUDPPacketInterface, 0)
+
+	for time.Now().Before(deadline) {
+		packets = append(packets, c.read(deadline)...)
+	}
+
+	return packets
+}
+
+// syn sends a SYN and stores the connection signature from the response
+func (c *testPRUDPClient) syn() {
+	c.send(c.newPacket(constants.SynPacket, constants.PacketFlagNeedsAck))
+
+	c.connectionSignature = c.receive(constants.SynPacket).getConnectionSignature()
+}
+
+// newConnect returns a CONNECT signed with the connection signature from the SYN response
+func (c *testPRUDPClient) newConnect(connectionSignature []byte) *PRUDPPacketV1 {
+	connect := c.newPacket(constants.ConnectPacket, constants.PacketFlagReliable, constants.PacketFlagNeedsAck)
+	connect.SetSequenceID(1)
+	connect.setSignature(connect.calculateSignature([]byte{}, connectionSignature))
+
+	return connect
+}
+
+// connect performs the SYN and CONNECT handshake, and returns the servers side of the connection
+func (c *testPRUDPClient) connect() *PRUDPConnection {
+	c.syn()
+	c.send(c.newConnect(c.connectionSignature))
+	c.receive(constants.ConnectPacket)
+
+	return c.connection()
+}
+
+// connection returns the servers side of the connection, if it exists
+func (c *testPRUDPClient) connection() *PRUDPConnection {
+	connection, _ := c.endpoint.Connections.Get(fmt.Sprintf("%s-%d-%d", c.address.String(), constants.StreamTypeRVSecure, 15))
+
+	return connection
+}
+
+// sendRequest sends a reliable DATA packet carrying the RMC request
+func (c *testPRUDPClient) sendRequest(request *RMCMessage) {
+	c.sendData(request.Bytes())
+}
+
+// sendData sends a reliable DATA packet carrying the payload, encrypted with the clients RC4 stream
+func (c *testPRUDPClient) sendData(data []byte) {
+	c.sequenceID++
+
+	payload, err := c.encryption.Encrypt(data)
+	if !assert.NoError(c.t, err) {
+		c.t.FailNow()
+	}
+
+	packet := c.newPacket(constants.DataPacket, constants.PacketFlagReliable, constants.PacketFlagNeedsAck, constants.PacketFlagHasSize)
+	packet.SetSequenceID(c.sequenceID)
+	packet.SetPayload(payload)
+
+	c.send(packet)
+}
+
+// receiveRMC returns the RMC message carried by the next reliable DATA packet sent by the server, and acknowledges it
+func (c *testPRUDPClient) receiveRMC() *RMCMessage {
+	packet := c.receive(constants.DataPacket)
+	c.acknowledge(packet)
+
+	payload, err := c.encryption.Decrypt(packet.Payload())
+	if !assert.NoError(c.t, err) {
+		c.t.FailNow()
+	}
+
+	message := NewRMCMessage(c.endpoint)
+	if !assert.NoError(c.t, message.FromBytes(payload)) {
+		c.t.FailNow()
+	}
+
+	return message
+}
+
+// acknowledge acknowledges a reliable packet sent by the server
+func (c *testPRUDPClient) acknowledge(packet *PRUDPPacketV1) {
+	ack := c.newPacket(packet.Type(), constants.PacketFlagAck)
+	ack.SetSequenceID(packet.SequenceID())
+	ack.SetSubstreamID(packet.SubstreamID())
+
+	c.send(ack)
+}
@@ -0,0 +1,148 @@
+package nex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// rmcCall is an RMC request received on a PRUDPConnection which is waiting for a response
+type rmcCall struct {
+	cancel  context.CancelFunc
+	claimed atomic.Bool // * Set by whichever of the handlers response and the timeout response is sent first
+}
+
+// claim marks the call as responded to. Returns false if a response was already sent
+func (c *rmcCall) claim() bool {
+	return c.claimed.CompareAndSwap(false, true)
+}
+
+// requestContext returns the parent context for RMC requests received on the connection
+func (pc *PRUDPConnection) requestContext(message *RMCMessage) context.Context {
+	if trace, ok := pc.traces.Get(message.CallID); ok {
+		return trace.ctx
+	}
+
+	return pc.ctx
+}
+
+// completeCall is called before an RMC response is sent on the connection. Returns false if
+// the response is for a call which has already been responded to and should not be sent
+func (pc *PRUDPConnection) completeCall(message *RMCMessage) bool {
+	if message == nil || message.IsRequest {
+		return true
+	}
+
+	responded := false
+	pc.calls.RunAndDelete(message.CallID, func(_ uint32, call *rmcCall) {
+		call.cancel()
+		responded = !call.claim()
+	})
+
+	return !responded
+}
+
+// dispatchData emits a DATA packet to the endpoints data handlers.
+//
+// Requests are given a context which is cancelled once they are responded to, when the connection ends
+// or after HandlerTimeout, in which case the client is sent a Core::Timeout error. Panics in the handlers
+// are recovered and logged, and a request whose handler panicked is sent a Core::Exception error
+func (pep *PRUDPEndPoint) dispatchData(packet PRUDPPacketInterface) {
+	connection := packet.Sender().(*PRUDPConnection)
+	message := packet.RMCMessage()
+
+	if !message.IsRequest {
+		packet.setContext(connection.ctx)
+		pep.HandlerPool.dispatch(pep.packetEventHandlers["data"], packet, pep.handlerRecovery(packet, nil))
+		return
+	}
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+
+	handlerTimeout := pep.handlerTimeout()
+
+	if handlerTimeout > 0 {
+		ctx, cancel = context.WithTimeout(connection.requestContext(message), handlerTimeout)
+	} else {
+		ctx, cancel = context.WithCancel(connection.requestContext(message))
+	}
+
+	call := &rmcCall{cancel: cancel}
+
+	// * Call IDs are unique per connection, so an existing call can only
+	// * be one the client has given up on
+	connection.calls.RunAndDelete(message.CallID, func(_ uint32, existing *rmcCall) {
+		existing.cancel()
+	})
+
+	connection.calls.Set(message.CallID, call)
+
+	if handlerTimeout > 0 {
+		context.AfterFunc(ctx, func() {
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				pep.handleCallTimeout(packet, call, handlerTimeout)
+			}
+		})
+	}
+
+	packet.setContext(ctx)
+	pep.HandlerPool.dispatch(pep.packetEventHandlers["data"], packet, pep.handlerRecovery(packet, call))
+}
+
+// handlerRecovery returns the function called when a data handler panics while handling the packet.
+// If the packet is a request, call is the pending call for it
+func (pep *PRUDPEndPoint) handlerRecovery(packet PRUDPPacketInterface, call *rmcCall) func(value any, stack []byte) {
+	return func(value any, stack []byte) {
+		pep.Server.log().Error(fmt.Sprintf("Recovered from panic in PRUDP data handler: %v\n%s", value, stack), packetLogFields(packet)...)
+
+		if call == nil {
+			return
+		}
+
+		message := packet.RMCMessage()
+
+		// * Calls are only failed once, so later panics from
+		// * other handlers of the same request are just logged
+		pep.failCall(packet, call, NewError(ResultCodes.Core.Exception, fmt.Sprintf("RMC call %d to protocol %d method %d panicked: %v", message.CallID, message.ProtocolID, message.MethodID, value)))
+	}
+}
+
+// handleCallTimeout sends a Core::Timeout error for a request which was not responded to in time
+func (pep *PRUDPEndPoint) handleCallTimeout(packet PRUDPPacketInterface, call *rmcCall, timeout time.Duration) {
+	message := packet.RMCMessage()
+
+	pep.failCall(packet, call, NewError(ResultCodes.Core.Timeout, fmt.Sprintf("RMC call %d to protocol %d method %d was not responded to within %s", message.CallID, message.ProtocolID, message.MethodID, timeout)))
+}
+
+// failCall sends the error to the client in response to a request, unless it was already responded to.
+// The call is left registered so a late response from the handler is dropped
+func (pep *PRUDPEndPoint) failCall(packet PRUDPPacketInterface, call *rmcCall, nexError *Error) {
+	if !call.claim() {
+		return
+	}
+
+	call.cancel()
+
+	message := packet.RMCMessage()
+	connection := packet.Sender().(*PRUDPConnection)
+
+	nexError.Packet = packet
+
+	pep.Server.log().Warn(nexError.Error(), packetLogFields(packet)...)
+
+	if trace, ok := connection.traces.Get(message.CallID); ok {
+		trace.recordError(nexError)
+	}
+
+	pep.EmitError(nexError)
+
+	response := NewRMCError(pep, nexError.ResultCode)
+	response.ProtocolID = message.ProtocolID
+	response.MethodID = message.MethodID
+	response.CallID = message.CallID
+
+	pep.sendRMCResponse(connection, packet, response)
+}
@@ -0,0 +1,815 @@
+package nex
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PretendoNetwork/nex-go/v2/compression"
+	"github.com/PretendoNetwork/nex-go/v2/encryption"
+	"github.com/PretendoNetwork/nex-go/v2/types"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFormat is the encoding of a server configuration file
+type ConfigFormat string
+
+const (
+	// ConfigFormatJSON is a JSON configuration file
+	ConfigFormatJSON ConfigFormat = "json"
+
+	// ConfigFormatYAML is a YAML configuration file
+	ConfigFormatYAML ConfigFormat = "yaml"
+)
+
+// ServerConfig is the declarative configuration of a PRUDP server, it's endpoints and a HPP server.
+//
+// Every field is optional. Fields which are not set keep the defaults of the type they configure,
+// so a config only needs to contain what differs from a server created in code
+type ServerConfig struct {
+	PRUDP *PRUDPServerConfig `json:"prudp,omitempty" yaml:"prudp,omitempty"`
+	HPP   *HPPServerConfig   `json:"hpp,omitempty" yaml:"hpp,omitempty"`
+}
+
+// PRUDPServerConfig configures a PRUDPServer and the PRUDPEndPoints bound to it
+type PRUDPServerConfig struct {
+	Profile                 string                         `json:"profile,omitempty" yaml:"profile,omitempty"` // * Name of a GameProfile applied before the rest of the config
+	AccessKey               string                         `json:"access_key,omitempty" yaml:"access_key,omitempty"`
+	LogLevel                string                         `json:"log_level,omitempty" yaml:"log_level,omitempty"`                             // * "debug", "info", "warn" or "error". Every message is logged if empty
+	KerberosTicketVersion   *int                           `json:"kerberos_ticket_version,omitempty" yaml:"kerberos_ticket_version,omitempty"` // * 0 or 1
+	SessionKeyLength        *int                           `json:"session_key_length,omitempty" yaml:"session_key_length,omitempty"`           // * 16 or 32
+	FragmentSize            *int                           `json:"fragment_size,omitempty" yaml:"fragment_size,omitempty"`
+	SupportedFunctions      *uint32                        `json:"supported_functions,omitempty" yaml:"supported_functions,omitempty"`
+	UseVerboseRMC           *bool                          `json:"use_verbose_rmc,omitempty" yaml:"use_verbose_rmc,omitempty"`
+	LibraryVersions         *LibraryVersionsConfig         `json:"library_versions,omitempty" yaml:"library_versions,omitempty"`
+	ByteStreamSettings      *ByteStreamConfig              `json:"byte_stream_settings,omitempty" yaml:"byte_stream_settings,omitempty"`
+	PRUDPV0Settings         *PRUDPV0Config                 `json:"prudp_v0_settings,omitempty" yaml:"prudp_v0_settings,omitempty"`
+	PRUDPV1Settings         *PRUDPV1Config                 `json:"prudp_v1_settings,omitempty" yaml:"prudp_v1_settings,omitempty"`
+	ConnectionSignatureKeys *ConnectionSignatureKeysConfig `json:"connection_signature_keys,omitempty" yaml:"connection_signature_keys,omitempty"`
+	StreamSettings          *StreamSettingsConfig          `json:"stream_settings,omitempty" yaml:"stream_settings,omitempty"` // * Defaults for every endpoint, which each endpoint may override
+	RateLimit               *RateLimitConfig               `json:"rate_limit,omitempty" yaml:"rate_limit,omitempty"`           // * Limits the datagrams each address may send. Not limited if unset
+	Bans                    *BanListConfig                 `json:"bans,omitempty" yaml:"bans,omitempty"`
+	EndPoints               []PRUDPEndPointConfig          `json:"endpoints,omitempty" yaml:"endpoints,omitempty"`
+}
+
+// PRUDPEndPointConfig configures a single PRUDPEndPoint
+type PRUDPEndPointConfig struct {
+	StreamID               uint8                 `json:"stream_id" yaml:"stream_id"`
+	IsSecureEndPoint       bool                  `json:"is_secure,omitempty" yaml:"is_secure,omitempty"`
+	HandlerTimeout         string                `json:"handler_timeout,omitempty" yaml:"handler_timeout,omitempty"` // * Duration such as "30s". Disabled if empty or "0"
+	StreamSettings         *StreamSettingsConfig `json:"stream_settings,omitempty" yaml:"stream_settings,omitempty"`
+	KerberosTicketLifetime string                `json:"kerberos_ticket_lifetime,omitempty" yaml:"kerberos_ticket_lifetime,omitempty"` // * Duration such as "2m". Uses DefaultKerberosTicketLifetime if empty
+	KerberosClockSkew      string                `json:"kerberos_clock_skew,omitempty" yaml:"kerberos_clock_skew,omitempty"`           // * Duration such as "30s". Uses DefaultKerberosClockSkew if empty
+}
+
+// HPPServerConfig configures a HPPServer
+type HPPServerConfig struct {
+	AccessKey          string                 `json:"access_key,omitempty" yaml:"access_key,omitempty"`
+	LogLevel           string                 `json:"log_level,omitempty" yaml:"log_level,omitempty"` // * "debug", "info", "warn" or "error". Every message is logged if empty
+	UseVerboseRMC      *bool                  `json:"use_verbose_rmc,omitempty" yaml:"use_verbose_rmc,omitempty"`
+	LibraryVersions    *LibraryVersionsConfig `json:"library_versions,omitempty" yaml:"library_versions,omitempty"`
+	ByteStreamSettings *ByteStreamConfig      `json:"byte_stream_settings,omitempty" yaml:"byte_stream_settings,omitempty"`
+	HandlerTimeout     string                 `json:"handler_timeout,omitempty" yaml:"handler_timeout,omitempty"` // * Duration such as "30s"
+	MaxRequestSize     *int64                 `json:"max_request_size,omitempty" yaml:"max_request_size,omitempty"`
+	MountPaths         []string               `json:"mount_paths,omitempty" yaml:"mount_paths,omitempty"`
+	RateLimit          *RateLimitConfig       `json:"rate_limit,omitempty" yaml:"rate_limit,omitempty"` // * Limits the requests each address may send. Not limited if unset
+	Bans               *BanListConfig         `json:"bans,omitempty" yaml:"bans,omitempty"`
+}
+
+// LibraryVersionsConfig configures LibraryVersions. Versions are written as "major.minor.patch",
+// optionally followed by "-" and a game specific patch, such as "3.10.1-AMKJ".
+//
+// Default sets every protocol with LibraryVersions.SetDefault. The other fields then override single protocols
+type LibraryVersionsConfig struct {
+	Default      string `json:"default,omitempty" yaml:"default,omitempty"`
+	Main         string `json:"main,omitempty" yaml:"main,omitempty"`
+	DataStore    string `json:"datastore,omitempty" yaml:"datastore,omitempty"`
+	MatchMaking  string `json:"matchmaking,omitempty" yaml:"matchmaking,omitempty"`
+	Ranking      string `json:"ranking,omitempty" yaml:"ranking,omitempty"`
+	Ranking2     string `json:"ranking2,omitempty" yaml:"ranking2,omitempty"`
+	Messaging    string `json:"messaging,omitempty" yaml:"messaging,omitempty"`
+	Utility      string `json:"utility,omitempty" yaml:"utility,omitempty"`
+	NATTraversal string `json:"nat_traversal,omitempty" yaml:"nat_traversal,omitempty"`
+}
+
+// ByteStreamConfig configures ByteStreamSettings
+type ByteStreamConfig struct {
+	StringLengthSize   *int  `json:"string_length_size,omitempty" yaml:"string_length_size,omitempty"` // * 2 or 4
+	PIDSize            *int  `json:"pid_size,omitempty" yaml:"pid_size,omitempty"`                     // * 4 or 8
+	UseStructureHeader *bool `json:"use_structure_header,omitempty" yaml:"use_structure_header,omitempty"`
+}
+
+// PRUDPV0Config configures PRUDPV0Settings
+type PRUDPV0Config struct {
+	IsQuazalMode              *bool `json:"is_quazal_mode,omitempty" yaml:"is_quazal_mode,omitempty"`
+	EncryptedConnect          *bool `json:"encrypted_connect,omitempty" yaml:"encrypted_connect,omitempty"`
+	LegacyConnectionSignature *bool `json:"legacy_connection_signature,omitempty" yaml:"legacy_connection_signature,omitempty"`
+	UseEnhancedChecksum       *bool `json:"use_enhanced_checksum,omitempty" yaml:"use_enhanced_checksum,omitempty"`
+}
+
+// PRUDPV1Config configures PRUDPV1Settings
+type PRUDPV1Config struct {
+	LegacyConnectionSignature *bool `json:"legacy_connection_signature,omitempty" yaml:"legacy_connection_signature,omitempty"`
+}
+
+// ConnectionSignatureKeysConfig configures a ConnectionSignatureKeyRing. Every node in a cluster should use the same values
+type ConnectionSignatureKeysConfig struct {
+	Secret           string `json:"secret" yaml:"secret"`                                           // * Hex encoded, at least 16 bytes
+	RotationInterval string `json:"rotation_interval,omitempty" yaml:"rotation_interval,omitempty"` // * Duration such as "1h". The key never changes if empty or "0"
+	GracePeriod      string `json:"grace_period,omitempty" yaml:"grace_period,omitempty"`           // * Duration such as "1m"
+}
+
+// RateLimitConfig configures a RateLimiter
+type RateLimitConfig struct {
+	PerSecond float64 `json:"per_second" yaml:"per_second"`           // * Datagrams or requests each address may send per second. Not limited if 0
+	Burst     *int    `json:"burst,omitempty" yaml:"burst,omitempty"` // * How many may be sent at once after being idle. PerSecond rounded up if unset
+}
+
+// BanListConfig configures a BanList
+type BanListConfig struct {
+	Addresses []string `json:"addresses,omitempty" yaml:"addresses,omitempty"` // * IP addresses such as "203.0.113.7", or CIDR ranges such as "203.0.113.0/24"
+	PIDs      []uint64 `json:"pids,omitempty" yaml:"pids,omitempty"`
+}
+
+// StreamSettingsConfig configures StreamSettings
+type StreamSettingsConfig struct {
+	ExtraRetransmitTimeoutTrigger    *uint32  `json:"extra_retransmit_timeout_trigger,omitempty" yaml:"extra_retransmit_timeout_trigger,omitempty"`
+	MaxPacketRetransmissions         *uint32  `json:"max_packet_retransmissions,omitempty" yaml:"max_packet_retransmissions,omitempty"`
+	KeepAliveTimeout                 *uint32  `json:"keep_alive_timeout,omitempty" yaml:"keep_alive_timeout,omitempty"`
+	ChecksumBase                     *uint32  `json:"checksum_base,omitempty" yaml:"checksum_base,omitempty"`
+	FaultDetectionEnabled            *bool    `json:"fault_detection_enabled,omitempty" yaml:"fault_detection_enabled,omitempty"`
+	InitialRTT                       *uint32  `json:"initial_rtt,omitempty" yaml:"initial_rtt,omitempty"`
+	SynInitialRTT                    *uint32  `json:"syn_initial_rtt,omitempty" yaml:"syn_initial_rtt,omitempty"`
+	EncryptionAlgorithm              string   `json:"encryption_algorithm,omitempty" yaml:"encryption_algorithm,omitempty"` // * "rc4", "quazal_rc4" or "none"
+	ExtraRetransmitTimeoutMultiplier *float32 `json:"extra_retransmit_timeout_multiplier,omitempty" yaml:"extra_retransmit_timeout_multiplier,omitempty"`
+	WindowSize                       *uint32  `json:"window_size,omitempty" yaml:"window_size,omitempty"`
+	CompressionAlgorithm             string   `json:"compression_algorithm,omitempty" yaml:"compression_algorithm,omitempty"` // * "none", "lzo" or "zlib"
+	RTTRetransmit                    *uint32  `json:"rtt_retransmit,omitempty" yaml:"rtt_retransmit,omitempty"`
+	RetransmitTimeoutMultiplier      *float32 `json:"retransmit_timeout_multiplier,omitempty" yaml:"retransmit_timeout_multiplier,omitempty"`
+	MaxSilenceTime                   *uint32  `json:"max_silence_time,omitempty" yaml:"max_silence_time,omitempty"`
+}
+
+// ConfigError holds every problem found in a ServerConfig, so they can all be fixed at once
+type ConfigError struct {
+	Problems []string // * Each problem is prefixed with the path of the field it was found in, such as "prudp.endpoints[0].stream_id"
+}
+
+// Error returns all of the problems, one per line
+func (ce *ConfigError) Error() string {
+	return fmt.Sprintf("invalid server config:\n\t%s", strings.Join(ce.Problems, "\n\t"))
+}
+
+func (ce *ConfigError) add(field, format string, args ...any) {
+	ce.Problems = append(ce.Problems, fmt.Sprintf("%s: %s", field, fmt.Sprintf(format, args...)))
+}
+
+// err returns the ConfigError if any problems were found, and nil otherwise
+func (ce *ConfigError) err() error {
+	if len(ce.Problems) == 0 {
+		return nil
+	}
+
+	return ce
+}
+
+// Validate checks every value in the config and returns a *ConfigError holding all problems found
+func (sc *ServerConfig) Validate() error {
+	problems := &ConfigError{}
+
+	if sc.PRUDP != nil {
+		sc.PRUDP.apply(NewPRUDPServer(), problems)
+	}
+
+	if sc.HPP != nil {
+		sc.HPP.apply(NewHPPServer(), problems)
+	}
+
+	return problems.err()
+}
+
+// NewPRUDPServer returns a new PRUDPServer built from the config, with every configured PRUDPEndPoint bound to it.
+// If the config is invalid, a *ConfigError is returned instead
+func (sc *ServerConfig) NewPRUDPServer() (*PRUDPServer, error) {
+	if sc.PRUDP == nil {
+		return nil, errors.New("server config has no prudp section")
+	}
+
+	server := NewPRUDPServer()
+	problems := &ConfigError{}
+
+	sc.PRUDP.apply(server, problems)
+
+	if err := problems.err(); err != nil {
+		return nil, err
+	}
+
+	return server, nil
+}
+
+// NewHPPServer returns a new HPPServer built from the config.
+// If the config is invalid, a *ConfigError is returned instead
+func (sc *ServerConfig) NewHPPServer() (*HPPServer, error) {
+	if sc.HPP == nil {
+		return nil, errors.New("server config has no hpp section")
+	}
+
+	server := NewHPPServer()
+	problems := &ConfigError{}
+
+	sc.HPP.apply(server, problems)
+
+	if err := problems.err(); err != nil {
+		return nil, err
+	}
+
+	return server, nil
+}
+
+func (psc *PRUDPServerConfig) apply(server *PRUDPServer, problems *ConfigError) {
+	defaultStreamSettings := NewStreamSettings()
+
+	if psc.Profile != "" {
+		if profile, ok := GameProfileByName(psc.Profile); ok {
+			profile.Settings.applyServer(server, defaultStreamSettings, problems)
+		} else {
+			problems.add("prudp.profile", "unknown game profile %q", psc.Profile)
+		}
+	}
+
+	psc.applyServer(server, defaultStreamSettings, problems)
+	psc.applyEndPoints(server, defaultStreamSettings, problems)
+}
+
+// applyServer applies everything but the endpoints. The stream settings are applied to defaultStreamSettings,
+// which the endpoints copy
+func (psc *PRUDPServerConfig) applyServer(server *PRUDPServer, defaultStreamSettings *StreamSettings, problems *ConfigError) {
+	if psc.AccessKey != "" {
+		server.AccessKey = psc.AccessKey
+	}
+
+	if psc.LogLevel != "" {
+		server.LogLevel.Set(parseConfigLogLevel(psc.LogLevel, "prudp.log_level", problems))
+	}
+
+	if psc.KerberosTicketVersion != nil {
+		if *psc.KerberosTicketVersion != 0 && *psc.KerberosTicketVersion != 1 {
+			problems.add("prudp.kerberos_ticket_version", "must be 0 or 1, got %d", *psc.KerberosTicketVersion)
+		}
+
+		server.KerberosTicketVersion = *psc.KerberosTicketVersion
+	}
+
+	if psc.SessionKeyLength != nil {
+		if *psc.SessionKeyLength != 16 && *psc.SessionKeyLength != 32 {
+			problems.add("prudp.session_key_length", "must be 16 or 32, got %d", *psc.SessionKeyLength)
+		}
+
+		server.SessionKeyLength = *psc.SessionKeyLength
+	}
+
+	if psc.FragmentSize != nil {
+		// * The MTU is at most 1364 bytes, so no payload can be larger
+		if *psc.FragmentSize <= 0 || *psc.FragmentSize > 1364 {
+			problems.add("prudp.fragment_size", "must be between 1 and 1364, got %d", *psc.FragmentSize)
+		}
+
+		server.SetFragmentSize(*psc.FragmentSize)
+	}
+
+	if psc.SupportedFunctions != nil {
+		server.SupportedFunctions = *psc.SupportedFunctions
+	}
+
+	if psc.UseVerboseRMC != nil {
+		server.UseVerboseRMC = *psc.UseVerboseRMC
+	}
+
+	if psc.LibraryVersions != nil {
+		psc.LibraryVersions.apply(server.LibraryVersions, "prudp.library_versions", problems)
+	}
+
+	if psc.ByteStreamSettings != nil {
+		psc.ByteStreamSettings.apply(server.ByteStreamSettings, "prudp.byte_stream_settings", problems)
+	}
+
+	if psc.PRUDPV0Settings != nil {
+		psc.PRUDPV0Settings.apply(server.PRUDPV0Settings)
+	}
+
+	if psc.PRUDPV1Settings != nil {
+		psc.PRUDPV1Settings.apply(server.PRUDPV1Settings)
+	}
+
+	if psc.ConnectionSignatureKeys != nil {
+		server.ConnectionSignatureKeyRing = psc.ConnectionSignatureKeys.keyRing("prudp.connection_signature_keys", problems)
+	}
+
+	if psc.StreamSettings != nil {
+		psc.StreamSettings.apply(defaultStreamSettings, "prudp.stream_settings", problems)
+	}
+
+	if psc.RateLimit != nil {
+		psc.RateLimit.apply(server.RateLimiter, "prudp.rate_limit", problems)
+	}
+
+	if psc.Bans != nil {
+		psc.Bans.apply(server.BanList, "prudp.bans", problems)
+	}
+}
+
+func (psc *PRUDPServerConfig) applyEndPoints(server *PRUDPServer, defaultStreamSettings *StreamSettings, problems *ConfigError) {
+	streamIDs := make(map[uint8]int)
+
+	for i, endpointConfig := range psc.EndPoints {
+		field := fmt.Sprintf("prudp.endpoints[%d]", i)
+
+		// * PRUDPLite can use port numbers 0-31, PRUDPv0 and PRUDPv1 only 0-15
+		if endpointConfig.StreamID > 31 {
+			problems.add(field+".stream_id", "must be between 0 and 31, got %d", endpointConfig.StreamID)
+		}
+
+		if previous, ok := streamIDs[endpointConfig.StreamID]; ok {
+			problems.add(field+".stream_id", "stream ID %d is already used by prudp.endpoints[%d]", endpointConfig.StreamID, previous)
+			continue
+		}
+
+		streamIDs[endpointConfig.StreamID] = i
+
+		endpoint := NewPRUDPEndPoint(endpointConfig.StreamID)
+		endpoint.IsSecureEndPoint = endpointConfig.IsSecureEndPoint
+		endpoint.HandlerTimeout = parseConfigDuration(endpointConfig.HandlerTimeout, field+".handler_timeout", problems)
+		endpoint.DefaultStreamSettings = defaultStreamSettings.Copy()
+
+		if endpointConfig.KerberosTicketLifetime != "" {
+			problemCount := len(problems.Problems)
+			endpoint.KerberosTicketLifetime = parseConfigDuration(endpointConfig.KerberosTicketLifetime, field+".kerberos_ticket_lifetime", problems)
+
+			// * Only report a zero lifetime if it was not already reported as invalid
+			if endpoint.KerberosTicketLifetime == 0 && len(problems.Problems) == problemCount {
+				problems.add(field+".kerberos_ticket_lifetime", "must be longer than 0, got %q", endpointConfig.KerberosTicketLifetime)
+			}
+		}
+
+		if endpointConfig.KerberosClockSkew != "" {
+			endpoint.KerberosClockSkew = parseConfigDuration(endpointConfig.KerberosClockSkew, field+".kerberos_clock_skew", problems)
+		}
+
+		if endpointConfig.StreamSettings != nil {
+			endpointConfig.StreamSettings.apply(endpoint.DefaultStreamSettings, field+".stream_settings", problems)
+		}
+
+		server.BindPRUDPEndPoint(endpoint)
+	}
+}
+
+func (hsc *HPPServerConfig) apply(server *HPPServer, problems *ConfigError) {
+	if hsc.AccessKey != "" {
+		server.SetAccessKey(hsc.AccessKey)
+	}
+
+	if hsc.LogLevel != "" {
+		server.LogLevel().Set(parseConfigLogLevel(hsc.LogLevel, "hpp.log_level", problems))
+	}
+
+	if hsc.UseVerboseRMC != nil {
+		server.EnableVerboseRMC(*hsc.UseVerboseRMC)
+	}
+
+	if hsc.LibraryVersions != nil {
+		hsc.LibraryVersions.apply(server.LibraryVersions(), "hpp.library_versions", problems)
+	}
+
+	if hsc.ByteStreamSettings != nil {
+		hsc.ByteStreamSettings.apply(server.ByteStreamSettings(), "hpp.byte_stream_settings", problems)
+	}
+
+	if hsc.HandlerTimeout != "" {
+		server.SetHandlerTimeout(parseConfigDuration(hsc.HandlerTimeout, "hpp.handler_timeout", problems))
+	}
+
+	if hsc.MaxRequestSize != nil {
+		if *hsc.MaxRequestSize < 0 {
+			problems.add("hpp.max_request_size", "must not be negative, got %d", *hsc.MaxRequestSize)
+		}
+
+		server.SetMaxRequestSize(*hsc.MaxRequestSize)
+	}
+
+	if hsc.RateLimit != nil {
+		hsc.RateLimit.apply(server.RateLimiter(), "hpp.rate_limit", problems)
+	}
+
+	if hsc.Bans != nil {
+		hsc.Bans.apply(server.BanList(), "hpp.bans", problems)
+	}
+
+	if len(hsc.MountPaths) != 0 {
+		valid := true
+		mux := http.NewServeMux()
+
+		for i, path := range hsc.MountPaths {
+			field := fmt.Sprintf("hpp.mount_paths[%d]", i)
+
+			if !strings.HasPrefix(path, "/") {
+				problems.add(field, "must start with \"/\", got %q", path)
+				valid = false
+
+				continue
+			}
+
+			if j := slices.Index(hsc.MountPaths[:i], path); j != -1 {
+				problems.add(field, "%q is already used by hpp.mount_paths[%d]", path, j)
+				valid = false
+
+				continue
+			}
+
+			if !registerMountPath(mux, path) {
+				problems.add(field, "%s", mountPathProblem(hsc.MountPaths[:i], path))
+				valid = false
+			}
+		}
+
+		// * http.ServeMux panics on invalid and conflicting patterns
+		if valid {
+			server.SetMountPaths(hsc.MountPaths...)
+		}
+	}
+}
+
+// registerMountPath registers the path on the mux like SetMountPaths does.
+// Returns false if the mux rejected it for being invalid or conflicting with a path registered before it
+func registerMountPath(mux *http.ServeMux, path string) (registered bool) {
+	defer func() {
+		if recover() != nil {
+			registered = false
+		}
+	}()
+
+	mux.Handle(path, http.NotFoundHandler())
+
+	return true
+}
+
+// mountPathProblem describes why http.ServeMux rejected the path, given the valid paths registered before it
+func mountPathProblem(earlier []string, path string) string {
+	if !registerMountPath(http.NewServeMux(), path) {
+		return fmt.Sprintf("must be a valid http.ServeMux pattern, got %q", path)
+	}
+
+	for i, other := range earlier {
+		mux := http.NewServeMux()
+
+		if registerMountPath(mux, other) && !registerMountPath(mux, path) {
+			return fmt.Sprintf("%q conflicts with hpp.mount_paths[%d]", path, i)
+		}
+	}
+
+	return fmt.Sprintf("%q conflicts with an earlier mount path", path)
+}
+
+func (lvc *LibraryVersionsConfig) apply(libraryVersions *LibraryVersions, field string, problems *ConfigError) {
+	if lvc.Default != "" {
+		if version := parseConfigLibraryVersion(lvc.Default, field+".default", problems); version != nil {
+			libraryVersions.SetDefault(version)
+		}
+	}
+
+	overrides := []struct {
+		name    string
+		value   string
+		version **LibraryVersion
+	}{
+		{"main", lvc.Main, &libraryVersions.Main},
+		{"datastore", lvc.DataStore, &libraryVersions.DataStore},
+		{"matchmaking", lvc.MatchMaking, &libraryVersions.MatchMaking},
+		{"ranking", lvc.Ranking, &libraryVersions.Ranking},
+		{"ranking2", lvc.Ranking2, &libraryVersions.Ranking2},
+		{"messaging", lvc.Messaging, &libraryVersions.Messaging},
+		{"utility", lvc.Utility, &libraryVersions.Utility},
+		{"nat_traversal", lvc.NATTraversal, &libraryVersions.NATTraversal},
+	}
+
+	for _, override := range overrides {
+		if override.value == "" {
+			continue
+		}
+
+		if version := parseConfigLibraryVersion(override.value, field+"."+override.name, problems); version != nil {
+			*override.version = version
+		}
+	}
+}
+
+func (bsc *ByteStreamConfig) apply(settings *ByteStreamSettings, field string, problems *ConfigError) {
+	if bsc.StringLengthSize != nil {
+		if *bsc.StringLengthSize != 2 && *bsc.StringLengthSize != 4 {
+			problems.add(field+".string_length_size", "must be 2 or 4, got %d", *bsc.StringLengthSize)
+		}
+
+		settings.StringLengthSize = *bsc.StringLengthSize
+	}
+
+	if bsc.PIDSize != nil {
+		if *bsc.PIDSize != 4 && *bsc.PIDSize != 8 {
+			problems.add(field+".pid_size", "must be 4 or 8, got %d", *bsc.PIDSize)
+		}
+
+		settings.PIDSize = *bsc.PIDSize
+	}
+
+	if bsc.UseStructureHeader != nil {
+		settings.UseStructureHeader = *bsc.UseStructureHeader
+	}
+}
+
+func (pc *PRUDPV0Config) apply(settings *PRUDPV0Settings) {
+	if pc.IsQuazalMode != nil {
+		settings.IsQuazalMode = *pc.IsQuazalMode
+	}
+
+	if pc.EncryptedConnect != nil {
+		settings.EncryptedConnect = *pc.EncryptedConnect
+	}
+
+	if pc.LegacyConnectionSignature != nil {
+		settings.LegacyConnectionSignature = *pc.LegacyConnectionSignature
+	}
+
+	if pc.UseEnhancedChecksum != nil {
+		settings.UseEnhancedChecksum = *pc.UseEnhancedChecksum
+	}
+}
+
+func (pc *PRUDPV1Config) apply(settings *PRUDPV1Settings) {
+	if pc.LegacyConnectionSignature != nil {
+		settings.LegacyConnectionSignature = *pc.LegacyConnectionSignature
+	}
+}
+
+func (cskc *ConnectionSignatureKeysConfig) keyRing(field string, problems *ConfigError) *ConnectionSignatureKeyRing {
+	secret, err := hex.DecodeString(cskc.Secret)
+	if err != nil {
+		problems.add(field+".secret", "must be hex encoded")
+	} else if len(secret) < 16 {
+		problems.add(field+".secret", "must be at least 16 bytes, got %d", len(secret))
+	}
+
+	rotationInterval := parseConfigDuration(cskc.RotationInterval, field+".rotation_interval", problems)
+	gracePeriod := parseConfigDuration(cskc.GracePeriod, field+".grace_period", problems)
+
+	// * The previous and next keys are only meant to overlap the edges of a period. A grace period as long
+	// * as the rotation interval would keep accepting keys from periods which have already ended
+	if rotationInterval > 0 && gracePeriod >= rotationInterval {
+		problems.add(field+".grace_period", "must be shorter than the rotation_interval of %q, got %q", cskc.RotationInterval, cskc.GracePeriod)
+	}
+
+	return NewConnectionSignatureKeyRing(secret, rotationInterval, gracePeriod)
+}
+
+func (rlc *RateLimitConfig) apply(rateLimiter *RateLimiter, field string, problems *ConfigError) {
+	if rlc.PerSecond < 0 {
+		problems.add(field+".per_second", "must not be negative, got %v", rlc.PerSecond)
+	}
+
+	burst := int(math.Ceil(rlc.PerSecond))
+
+	if rlc.Burst != nil {
+		// * A burst below 1 would drop every datagram or request
+		if *rlc.Burst < 1 {
+			problems.add(field+".burst", "must be at least 1, got %d", *rlc.Burst)
+		}
+
+		burst = *rlc.Burst
+	}
+
+	rateLimiter.SetLimit(rlc.PerSecond, burst)
+}
+
+func (blc *BanListConfig) apply(banList *BanList, field string, problems *ConfigError) {
+	addresses := make([]netip.Prefix, 0, len(blc.Addresses))
+
+	for i, address := range blc.Addresses {
+		prefix, err := parseConfigPrefix(address)
+		if err != nil {
+			problems.add(fmt.Sprintf("%s.addresses[%d]", field, i), "must be an IP address or CIDR range, got %q", address)
+			continue
+		}
+
+		addresses = append(addresses, prefix)
+	}
+
+	pids := make([]types.PID, 0, len(blc.PIDs))
+	for _, pid := range blc.PIDs {
+		pids = append(pids, types.NewPID(pid))
+	}
+
+	banList.Set(addresses, pids)
+}
+
+// parseConfigPrefix parses an IP address or CIDR range. An IP address is parsed as a range covering only itself
+func parseConfigPrefix(value string) (netip.Prefix, error) {
+	if strings.Contains(value, "/") {
+		return netip.ParsePrefix(value)
+	}
+
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+
+	addr = addr.WithZone("")
+
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+func (ssc *StreamSettingsConfig) apply(settings *StreamSettings, field string, problems *ConfigError) {
+	setUint32 := func(value *uint32, target *uint32) {
+		if value != nil {
+			*target = *value
+		}
+	}
+
+	setUint32(ssc.ExtraRetransmitTimeoutTrigger, &settings.ExtraRetransmitTimeoutTrigger)
+	setUint32(ssc.MaxPacketRetransmissions, &settings.MaxPacketRetransmissions)
+	setUint32(ssc.KeepAliveTimeout, &settings.KeepAliveTimeout)
+	setUint32(ssc.ChecksumBase, &settings.ChecksumBase)
+	setUint32(ssc.InitialRTT, &settings.InitialRTT)
+	setUint32(ssc.SynInitialRTT, &settings.SynInitialRTT)
+	setUint32(ssc.WindowSize, &settings.WindowSize)
+	setUint32(ssc.RTTRetransmit, &settings.RTTRetransmit)
+	setUint32(ssc.MaxSilenceTime, &settings.MaxSilenceTime)
+
+	if ssc.FaultDetectionEnabled != nil {
+		settings.FaultDetectionEnabled = *ssc.FaultDetectionEnabled
+	}
+
+	if ssc.ExtraRetransmitTimeoutMultiplier != nil {
+		if *ssc.ExtraRetransmitTimeoutMultiplier <= 0 {
+			problems.add(field+".extra_retransmit_timeout_multiplier", "must be greater than 0, got %g", *ssc.ExtraRetransmitTimeoutMultiplier)
+		}
+
+		settings.ExtraRetransmitTimeoutMultiplier = *ssc.ExtraRetransmitTimeoutMultiplier
+	}
+
+	if ssc.RetransmitTimeoutMultiplier != nil {
+		if *ssc.RetransmitTimeoutMultiplier <= 0 {
+			problems.add(field+".retransmit_timeout_multiplier", "must be greater than 0, got %g", *ssc.RetransmitTimeoutMultiplier)
+		}
+
+		settings.RetransmitTimeoutMultiplier = *ssc.RetransmitTimeoutMultiplier
+	}
+
+	switch ssc.EncryptionAlgorithm {
+	case "":
+	case "rc4":
+		settings.EncryptionAlgorithm = encryption.NewRC4Encryption()
+	case "quazal_rc4":
+		settings.EncryptionAlgorithm = encryption.NewQuazalRC4Encryption()
+	case "none":
+		settings.EncryptionAlgorithm = encryption.NewDummyEncryption()
+	default:
+		problems.add(field+".encryption_algorithm", "must be \"rc4\", \"quazal_rc4\" or \"none\", got %q", ssc.EncryptionAlgorithm)
+	}
+
+	switch ssc.CompressionAlgorithm {
+	case "":
+	case "none":
+		settings.CompressionAlgorithm = compression.NewDummyCompression()
+	case "lzo":
+		settings.CompressionAlgorithm = compression.NewLZOCompression()
+	case "zlib":
+		settings.CompressionAlgorithm = compression.NewZlibCompression()
+	default:
+		problems.add(field+".compression_algorithm", "must be \"none\", \"lzo\" or \"zlib\", got %q", ssc.CompressionAlgorithm)
+	}
+}
+
+// parseConfigLibraryVersion parses a version such as "3.10.1" or "3.10.1-AMKJ"
+func parseConfigLibraryVersion(value, field string, problems *ConfigError) *LibraryVersion {
+	version, gameSpecificPatch, _ := strings.Cut(value, "-")
+
+	parts := strings.Split(version, ".")
+	if len(parts) != 3 {
+		problems.add(field, "must be in the form \"major.minor.patch\", got %q", value)
+		return nil
+	}
+
+	numbers := make([]int, 3)
+	for i, part := range parts {
+		number, err := strconv.Atoi(part)
+		if err != nil || number < 0 {
+			problems.add(field, "must be in the form \"major.minor.patch\", got %q", value)
+			return nil
+		}
+
+		numbers[i] = number
+	}
+
+	if gameSpecificPatch != "" {
+		return NewPatchedLibraryVersion(numbers[0], numbers[1], numbers[2], gameSpecificPatch)
+	}
+
+	return NewLibraryVersion(numbers[0], numbers[1], numbers[2])
+}
+
+// parseConfigDuration parses a duration such as "30s". Empty values are 0
+func parseConfigDuration(value, field string, problems *ConfigError) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		problems.add(field, "must be a duration such as \"30s\", got %q", value)
+		return 0
+	}
+
+	if duration < 0 {
+		problems.add(field, "must not be negative, got %q", value)
+		return 0
+	}
+
+	return duration
+}
+
+func parseConfigLogLevel(value, field string, problems *ConfigError) slog.Level {
+	switch strings.ToLower(value) {
+	case "debug":
+		return slog.LevelDebug
+	case "info":
+		return slog.LevelInfo
+	case "warn":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	}
+
+	problems.add(field, "must be \"debug\", \"info\", \"warn\" or \"error\", got %q", value)
+
+	return slog.LevelDebug
+}
+
+// ParseServerConfig decodes a ServerConfig in the given format and validates it.
+//
+// Unknown fields are rejected, to catch typos. If the config decodes but is invalid,
+// the config is returned along with a *ConfigError holding every problem found
+func ParseServerConfig(data []byte, format ConfigFormat) (*ServerConfig, error) {
+	config := &ServerConfig{}
+
+	switch format {
+	case ConfigFormatJSON:
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+
+		if err := decoder.Decode(config); err != nil {
+			return nil, fmt.Errorf("Failed to decode JSON server config. %s", err)
+		}
+	case ConfigFormatYAML:
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+
+		// * An empty document decodes to io.EOF, which is a valid empty config
+		if err := decoder.Decode(config); err != nil && len(bytes.TrimSpace(data)) != 0 {
+			return nil, fmt.Errorf("Failed to decode YAML server config. %s", err)
+		}
+	default:
+		return nil, fmt.Errorf("Unsupported server config format %q", format)
+	}
+
+	return config, config.Validate()
+}
+
+// LoadServerConfig reads a ServerConfig from a file. The format is chosen from the file extension,
+// which must be ".json", ".yaml" or ".yml"
+func LoadServerConfig(path string) (*ServerConfig, error) {
+	var format ConfigFormat
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		format = ConfigFormatJSON
+	case ".yaml", ".yml":
+		format = ConfigFormatYAML
+	default:
+		return nil, fmt.Errorf("Unsupported server config file extension %q", filepath.Ext(path))
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseServerConfig(data, format)
+}
@@ -0,0 +1,108 @@
+package nex
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Reload applies the runtime settings of the config to running servers. Either server may be nil.
+//
+// Only settings which can safely change while clients are connected are reloaded: the log levels, the rate limits and bans,
+// the stream settings, handler timeouts and Kerberos ticket settings of each PRUDPEndPoint, and the handler timeout and
+// max request size of the HPPServer. Rate limits and bans are replaced by those in the config, so a server without them
+// in it's config is no longer limited and bans nothing, including bans added through the AdminHandler.
+// Stream settings apply to new connections and, where safe, to existing ones. See PRUDPEndPoint.UpdateStreamSettings.
+// Other changes, such as new endpoints or a different access key, are logged and require a restart.
+//
+// The whole config is validated first. If it is invalid, nothing is changed and a *ConfigError is returned
+func (sc *ServerConfig) Reload(prudpServer *PRUDPServer, hppServer *HPPServer) error {
+	if err := sc.Validate(); err != nil {
+		return err
+	}
+
+	if prudpServer != nil && sc.PRUDP != nil {
+		reloaded, _ := sc.NewPRUDPServer()
+
+		prudpServer.LogLevel.Set(reloaded.LogLevel.Level())
+
+		if prudpServer.RateLimiter != nil {
+			prudpServer.RateLimiter.SetLimit(reloaded.RateLimiter.Limit())
+		} else if sc.PRUDP.RateLimit != nil {
+			prudpServer.log().Warn("Rate limit set in server config, but the server has no RateLimiter")
+		}
+
+		if prudpServer.BanList != nil {
+			prudpServer.BanList.Set(reloaded.BanList.Addresses(), reloaded.BanList.PIDs())
+		} else if sc.PRUDP.Bans != nil {
+			prudpServer.log().Warn("Bans set in server config, but the server has no BanList")
+		}
+
+		reloaded.Endpoints.Each(func(streamID uint8, reloadedEndpoint *PRUDPEndPoint) bool {
+			endpoint, ok := prudpServer.Endpoints.Get(streamID)
+			if !ok {
+				prudpServer.log().Warn("Endpoint added to server config, restart to bind it", "stream_id", streamID)
+				return false
+			}
+
+			endpoint.reloadSettings(reloadedEndpoint)
+
+			return false
+		})
+
+		if sc.PRUDP.AccessKey != "" && sc.PRUDP.AccessKey != prudpServer.AccessKey {
+			prudpServer.log().Warn("Access key changed in server config, restart to apply it")
+		}
+
+		prudpServer.log().Info("Reloaded server config")
+	}
+
+	if hppServer != nil && sc.HPP != nil {
+		reloaded, _ := sc.NewHPPServer()
+
+		hppServer.LogLevel().Set(reloaded.LogLevel().Level())
+
+		hppServer.SetHandlerTimeout(reloaded.HandlerTimeout())
+		hppServer.SetMaxRequestSize(reloaded.MaxRequestSize())
+		hppServer.RateLimiter().SetLimit(reloaded.RateLimiter().Limit())
+		hppServer.BanList().Set(reloaded.BanList().Addresses(), reloaded.BanList().PIDs())
+
+		hppServer.log().Info("Reloaded HPP server config")
+	}
+
+	return nil
+}
+
+// WatchServerConfig reloads the config file at path into the running servers each time the process receives SIGHUP,
+// until the context is cancelled. Either server may be nil. Errors are logged and the previous settings are kept.
+//
+// WatchServerConfig blocks, so it is usually started in it's own goroutine
+func WatchServerConfig(ctx context.Context, path string, prudpServer *PRUDPServer, hppServer *HPPServer) {
+	logger := defaultLogger
+	if prudpServer != nil {
+		logger = prudpServer.log()
+	} else if hppServer != nil {
+		logger = hppServer.log()
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			config, err := LoadServerConfig(path)
+			if err == nil {
+				err = config.Reload(prudpServer, hppServer)
+			}
+
+			if err != nil {
+				logger.Error("Failed to reload server config", "path", path, "error", err.Error())
+			}
+		}
+	}
+}
@@ -0,0 +1,346 @@
+package nex
+
+import (
+	"errors"
+	"log/slog"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/PretendoNetwork/nex-go/v2/compression"
+	"github.com/PretendoNetwork/nex-go/v2/constants"
+	"github.com/PretendoNetwork/nex-go/v2/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerConfigBuildsServers(t *testing.T) {
+	config, err := ParseServerConfig([]byte(`
+prudp:
+  access_key: ridfebb9
+  session_key_length: 16
+  fragment_size: 962
+  library_versions:
+    default: 3.10.1
+    datastore: 3.5.4-AMKJ
+  byte_stream_settings:
+    pid_size: 8
+  stream_settings:
+    max_silence_time: 20000
+    compression_algorithm: zlib
+  connection_signature_keys:
+    secret: 000102030405060708090a0b0c0d0e0f
+    rotation_interval: 1h
+    grace_period: 1m
+  endpoints:
+    - stream_id: 1
+    - stream_id: 2
+      is_secure: true
+      handler_timeout: 10s
+      kerberos_ticket_lifetime: 5m
+      kerberos_clock_skew: 10s
+      stream_settings:
+        max_silence_time: 5000
+hpp:
+  access_key: 76f26496
+  max_request_size: 4096
+  mount_paths: [/hpp/, /v1/hpp/]
+`), ConfigFormatYAML)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	server, err := config.NewPRUDPServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, "ridfebb9", server.AccessKey)
+	assert.Equal(t, 16, server.SessionKeyLength)
+	assert.Equal(t, 962, server.FragmentSize)
+	assert.Equal(t, 8, server.ByteStreamSettings.PIDSize)
+	assert.Equal(t, 3, server.LibraryVersions.Main.Major)
+	assert.Equal(t, "AMKJ", server.LibraryVersions.DataStore.GameSpecificPatch)
+	assert.Equal(t, 2, server.Endpoints.Size())
+
+	if assert.NotNil(t, server.ConnectionSignatureKeyRing) {
+		assert.Len(t, server.ConnectionSignatureKeyRing.Secret, 16)
+		assert.Equal(t, time.Hour, server.ConnectionSignatureKeyRing.RotationInterval)
+		assert.Equal(t, time.Minute, server.ConnectionSignatureKeyRing.GracePeriod)
+	}
+
+	auth, _ := server.Endpoints.Get(1)
+	assert.False(t, auth.IsSecureEndPoint)
+	assert.Equal(t, uint32(20000), auth.DefaultStreamSettings.MaxSilenceTime)
+	assert.IsType(t, &compression.Zlib{}, auth.DefaultStreamSettings.CompressionAlgorithm)
+
+	secure, _ := server.Endpoints.Get(2)
+	assert.True(t, secure.IsSecureEndPoint)
+	assert.Equal(t, 10*time.Second, secure.HandlerTimeout)
+	assert.Equal(t, 5*time.Minute, secure.KerberosTicketLifetime)
+	assert.Equal(t, 10*time.Second, secure.KerberosClockSkew)
+	assert.Equal(t, DefaultKerberosTicketLifetime, auth.KerberosTicketLifetime)
+	assert.Equal(t, uint32(5000), secure.DefaultStreamSettings.MaxSilenceTime)
+
+	hpp, err := config.NewHPPServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, "76f26496", hpp.AccessKey())
+	assert.Equal(t, int64(4096), hpp.MaxRequestSize())
+	assert.Equal(t, []string{"/hpp/", "/v1/hpp/"}, hpp.MountPaths())
+}
+
+func TestServerConfigReportsAllErrors(t *testing.T) {
+	_, err := ParseServerConfig([]byte(`{
+		"prudp": {
+			"kerberos_ticket_version": 2,
+			"byte_stream_settings": { "string_length_size": 3, "pid_size": 6 },
+			"connection_signature_keys": { "secret": "0011", "rotation_interval": "1m", "grace_period": "1m" },
+			"endpoints": [
+				{ "stream_id": 1, "handler_timeout": "soon" },
+				{ "stream_id": 1 },
+				{ "stream_id": 2, "kerberos_ticket_lifetime": "0s" }
+			]
+		},
+		"hpp": {
+			"log_level": "verbose",
+			"library_versions": { "default": "3.x" },
+			"rate_limit": { "per_second": -1, "burst": 0 },
+			"bans": { "addresses": ["203.0.113.0/33", "localhost"] }
+		}
+	}`), ConfigFormatJSON)
+
+	var configError *ConfigError
+	if !assert.True(t, errors.As(err, &configError)) {
+		return
+	}
+
+	assert.ElementsMatch(t, []string{
+		`prudp.kerberos_ticket_version: must be 0 or 1, got 2`,
+		`prudp.byte_stream_settings.string_length_size: must be 2 or 4, got 3`,
+		`prudp.byte_stream_settings.pid_size: must be 4 or 8, got 6`,
+		`prudp.connection_signature_keys.secret: must be at least 16 bytes, got 2`,
+		`prudp.connection_signature_keys.grace_period: must be shorter than the rotation_interval of "1m", got "1m"`,
+		`prudp.endpoints[0].handler_timeout: must be a duration such as "30s", got "soon"`,
+		`prudp.endpoints[1].stream_id: stream ID 1 is already used by prudp.endpoints[0]`,
+		`prudp.endpoints[2].kerberos_ticket_lifetime: must be longer than 0, got "0s"`,
+		`hpp.log_level: must be "debug", "info", "warn" or "error", got "verbose"`,
+		`hpp.library_versions.default: must be in the form "major.minor.patch", got "3.x"`,
+		`hpp.rate_limit.per_second: must not be negative, got -1`,
+		`hpp.rate_limit.burst: must be at least 1, got 0`,
+		`hpp.bans.addresses[0]: must be an IP address or CIDR range, got "203.0.113.0/33"`,
+		`hpp.bans.addresses[1]: must be an IP address or CIDR range, got "localhost"`,
+	}, configError.Problems)
+
+	_, err = ParseServerConfig([]byte(`{ "prudp": { "acess_key": "typo" } }`), ConfigFormatJSON)
+	assert.ErrorContains(t, err, "acess_key")
+}
+
+func TestServerConfigRejectsConflictingMountPaths(t *testing.T) {
+	_, err := ParseServerConfig([]byte(`{"hpp": {"mount_paths": ["/hpp/", "/hpp/"]}}`), ConfigFormatJSON)
+
+	var configError *ConfigError
+	if !assert.True(t, errors.As(err, &configError)) {
+		return
+	}
+
+	assert.Equal(t, []string{`hpp.mount_paths[1]: "/hpp/" is already used by hpp.mount_paths[0]`}, configError.Problems)
+
+	_, err = ParseServerConfig([]byte(`{"hpp": {"mount_paths": ["/hpp/{service}/a", "hpp", "/hpp/b/{method}", "/hpp/{"]}}`), ConfigFormatJSON)
+	if !assert.True(t, errors.As(err, &configError)) {
+		return
+	}
+
+	assert.Equal(t, []string{
+		`hpp.mount_paths[1]: must start with "/", got "hpp"`,
+		`hpp.mount_paths[2]: "/hpp/b/{method}" conflicts with hpp.mount_paths[0]`,
+		`hpp.mount_paths[3]: must be a valid http.ServeMux pattern, got "/hpp/{"`,
+	}, configError.Problems)
+}
+
+func TestServerConfigReload(t *testing.T) {
+	config, err := ParseServerConfig([]byte(`{"prudp": {"endpoints": [{"stream_id": 1}]}}`), ConfigFormatJSON)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	server, err := config.NewPRUDPServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	endpoint, _ := server.Endpoints.Get(1)
+
+	connection := NewPRUDPConnection(NewSocketConnection(server, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1}, nil))
+	connection.StreamSettings = endpoint.DefaultStreamSettings.Copy()
+	encryptionAlgorithm := connection.StreamSettings.EncryptionAlgorithm
+	endpoint.Connections.Set("test", connection)
+
+	reloaded, err := ParseServerConfig([]byte(`{"prudp": {"log_level": "warn", "endpoints": [{
+		"stream_id": 1,
+		"handler_timeout": "5s",
+		"stream_settings": { "max_silence_time": 60000, "window_size": 16 }
+	}]}}`), ConfigFormatJSON)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.NoError(t, reloaded.Reload(server, nil))
+	assert.Equal(t, 5*time.Second, endpoint.HandlerTimeout)
+	assert.Equal(t, slog.LevelWarn, server.LogLevel.Level())
+
+	// * New connections get every setting
+	assert.Equal(t, uint32(60000), endpoint.DefaultStreamSettings.MaxSilenceTime)
+	assert.Equal(t, uint32(16), endpoint.DefaultStreamSettings.WindowSize)
+
+	// * Existing connections only get the settings which are safe to change
+	assert.Equal(t, uint32(60000), connection.StreamSettings.MaxSilenceTime)
+	assert.Equal(t, uint32(8), connection.StreamSettings.WindowSize)
+	assert.Same(t, encryptionAlgorithm, connection.StreamSettings.EncryptionAlgorithm)
+
+	invalid := &ServerConfig{PRUDP: &PRUDPServerConfig{FragmentSize: new(int)}}
+	assert.Error(t, invalid.Reload(server, nil))
+}
+
+func TestServerConfigReloadBansAndRateLimits(t *testing.T) {
+	config, err := ParseServerConfig([]byte(`{
+		"prudp": { "rate_limit": { "per_second": 100 }, "bans": { "addresses": ["203.0.113.7"], "pids": [1000] } },
+		"hpp": { "rate_limit": { "per_second": 10, "burst": 20 } }
+	}`), ConfigFormatJSON)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	prudpServer, err := config.NewPRUDPServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	hppServer, err := config.NewHPPServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	perSecond, burst := prudpServer.RateLimiter.Limit()
+	assert.Equal(t, float64(100), perSecond)
+	assert.Equal(t, 100, burst)
+	assert.True(t, prudpServer.BanList.PIDBanned(types.NewPID(1000)))
+
+	reloaded, err := ParseServerConfig([]byte(`{
+		"prudp": { "bans": { "addresses": ["198.51.100.0/24"], "pids": [1001] } },
+		"hpp": { "rate_limit": { "per_second": 5 }, "bans": { "pids": [1000] } }
+	}`), ConfigFormatJSON)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.NoError(t, reloaded.Reload(prudpServer, hppServer))
+
+	// * Bans are replaced, and a rate limit removed from the config is lifted
+	perSecond, _ = prudpServer.RateLimiter.Limit()
+	assert.Equal(t, float64(0), perSecond)
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("198.51.100.0/24")}, prudpServer.BanList.Addresses())
+	assert.False(t, prudpServer.BanList.PIDBanned(types.NewPID(1000)))
+	assert.True(t, prudpServer.BanList.PIDBanned(types.NewPID(1001)))
+
+	perSecond, burst = hppServer.RateLimiter().Limit()
+	assert.Equal(t, float64(5), perSecond)
+	assert.Equal(t, 5, burst)
+	assert.True(t, hppServer.BanList().PIDBanned(types.NewPID(1000)))
+}
+
+func TestServerConfigReloadDuringTraffic(t *testing.T) {
+	prudpServer, endpoint := newTestPRUDPServer(t)
+	endpoint.OnData(func(packet PacketInterface) {
+		request := packet.(PRUDPPacketInterface)
+
+		response := NewRMCSuccess(endpoint, nil)
+		response.ProtocolID = request.RMCMessage().ProtocolID
+		response.MethodID = request.RMCMessage().MethodID
+		response.CallID = request.RMCMessage().CallID
+
+		responsePacket, _ := NewPRUDPPacketV1(prudpServer, request.Sender().(*PRUDPConnection), nil)
+		responsePacket.SetType(constants.DataPacket)
+		responsePacket.AddFlag(constants.PacketFlagHasSize)
+		responsePacket.AddFlag(constants.PacketFlagReliable)
+		responsePacket.AddFlag(constants.PacketFlagNeedsAck)
+		responsePacket.SetSourceVirtualPortStreamType(request.DestinationVirtualPortStreamType())
+		responsePacket.SetSourceVirtualPortStreamID(request.DestinationVirtualPortStreamID())
+		responsePacket.SetDestinationVirtualPortStreamType(request.SourceVirtualPortStreamType())
+		responsePacket.SetDestinationVirtualPortStreamID(request.SourceVirtualPortStreamID())
+		responsePacket.SetRMCMessage(response)
+		responsePacket.SetPayload(response.Bytes())
+
+		// * Sent with Send, like service protocols do, so the call is completed
+		prudpServer.Send(responsePacket)
+	})
+
+	hppServer := newTestHPPServer()
+	hppServer.OnData(func(packet PacketInterface) {
+		response := NewRMCError(hppServer, ResultCodes.Core.InvalidArgument)
+		response.CallID = packet.RMCMessage().CallID
+
+		packet.SetRMCMessage(response)
+		hppServer.Send(packet)
+	})
+
+	configs := make([]*ServerConfig, 0, 2)
+	for _, settings := range []string{
+		`"handler_timeout": "5s", "kerberos_clock_skew": "10s", "stream_settings": { "max_silence_time": 60000, "max_packet_retransmissions": 10 }`,
+		`"handler_timeout": "10s", "kerberos_clock_skew": "20s", "stream_settings": { "max_silence_time": 30000, "max_packet_retransmissions": 20 }`,
+	} {
+		config, err := ParseServerConfig([]byte(`{
+			"prudp": { "log_level": "info", "endpoints": [{ "stream_id": 1, `+settings+` }] },
+			"hpp": { "log_level": "info", "handler_timeout": "5s", "max_request_size": 4096 }
+		}`), ConfigFormatJSON)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		configs = append(configs, config)
+	}
+
+	// * InitialRTT is not reloaded on existing connections, so this keeps
+	// * responses from being retransmitted while the reloads slow the client down
+	endpoint.DefaultStreamSettings.InitialRTT = 5000
+
+	client := newTestPRUDPClient(t, endpoint)
+	client.connect()
+
+	stop := make(chan struct{})
+	reloaded := make(chan struct{})
+
+	// * Run with -race to check the reloaded settings are never read while they are written
+	go func() {
+		defer close(reloaded)
+
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+
+			assert.NoError(t, configs[i%len(configs)].Reload(prudpServer, hppServer))
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	request := NewRMCRequest(endpoint)
+	request.ProtocolID = 0xA
+	request.MethodID = 1
+
+	for i := uint32(1); i <= 50; i++ {
+		// * Every response is acknowledged straight away, so nothing is retransmitted
+		request.CallID = i
+		client.sendRequest(request)
+		assert.Equal(t, i, client.receiveRMC().CallID)
+
+		assert.Equal(t, NewError(ResultCodes.Core.InvalidArgument, "").ResultCode, testHPPRequest(t, hppServer))
+	}
+
+	close(stop)
+	<-reloaded
+}
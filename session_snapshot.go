@@ -0,0 +1,266 @@
+package nex
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/PretendoNetwork/nex-go/v2/constants"
+	"github.com/PretendoNetwork/nex-go/v2/encryption"
+	"github.com/PretendoNetwork/nex-go/v2/types"
+)
+
+// SessionSnapshot holds the state of the PRUDP connections on a server, so clients can keep their
+// sessions across a server restart without reconnecting.
+//
+// Only UDP connections can be resumed. PRUDPLite connections end with their WebSocket
+type SessionSnapshot struct {
+	CreatedAt                     time.Time      `json:"created_at"`
+	PRUDPv1ConnectionSignatureKey []byte         `json:"prudp_v1_connection_signature_key"`
+	Sessions                      []SessionState `json:"sessions"`
+}
+
+// SessionState is the state of a single PRUDPConnection
+type SessionState struct {
+	EndPointStreamID             uint8                `json:"endpoint_stream_id"`
+	Address                      string               `json:"address"`
+	ID                           uint32               `json:"id"`
+	SessionID                    uint8                `json:"session_id"`
+	ServerSessionID              uint8                `json:"server_session_id"`
+	SessionKey                   []byte               `json:"session_key"`
+	PID                          uint64               `json:"pid"`
+	DefaultPRUDPVersion          int                  `json:"default_prudp_version"`
+	StreamType                   constants.StreamType `json:"stream_type"`
+	StreamID                     uint8                `json:"stream_id"`
+	Signature                    []byte               `json:"signature"`
+	ServerConnectionSignature    []byte               `json:"server_connection_signature"`
+	UnreliablePacketBaseKey      []byte               `json:"unreliable_packet_base_key"`
+	OutgoingUnreliableSequenceID uint16               `json:"outgoing_unreliable_sequence_id"`
+	OutgoingPingSequenceID       uint16               `json:"outgoing_ping_sequence_id"`
+	StationURLs                  []string             `json:"station_urls"`
+	Substreams                   []SubstreamState     `json:"substreams"`
+}
+
+// SubstreamState is the state of a single reliable substream of a PRUDPConnection
+type SubstreamState struct {
+	ID                     uint8                   `json:"id"`
+	OutgoingSequenceID     uint16                  `json:"outgoing_sequence_id"`      // * Last sequence ID sent by the server
+	NextIncomingSequenceID uint16                  `json:"next_incoming_sequence_id"` // * Next sequence ID expected from the client
+	Cipher                 *encryption.StreamState `json:"cipher,omitempty"`          // * Only set if the encryption algorithm is encryption.Resumable
+}
+
+// SnapshotSessions returns the state of every connected PRUDP connection on the server.
+//
+// The connections keep running, so the snapshot should be taken once the server stops processing packets,
+// as done by Shutdown. Otherwise the clients and the snapshot may disagree on sequence IDs and cipher state
+func (ps *PRUDPServer) SnapshotSessions() *SessionSnapshot {
+	snapshot := &SessionSnapshot{
+		CreatedAt:                     time.Now(),
+		PRUDPv1ConnectionSignatureKey: ps.PRUDPv1ConnectionSignatureKey,
+		Sessions:                      make([]SessionState, 0),
+	}
+
+	ps.Endpoints.Each(func(_ uint8, endpoint *PRUDPEndPoint) bool {
+		endpoint.Connections.Each(func(_ string, connection *PRUDPConnection) bool {
+			if connection.state() == StateConnected && connection.Socket.session == nil {
+				snapshot.Sessions = append(snapshot.Sessions, connection.sessionState())
+			}
+
+			return false
+		})
+
+		return false
+	})
+
+	return snapshot
+}
+
+// RestoreSessions recreates the connections in the snapshot on the servers endpoints, and returns how many were restored.
+// Sessions for endpoints which are not bound to the server are skipped.
+//
+// Sessions should be restored after the endpoints are bound, but before the server starts listening
+func (ps *PRUDPServer) RestoreSessions(snapshot *SessionSnapshot) (int, error) {
+	if len(snapshot.PRUDPv1ConnectionSignatureKey) == 16 {
+		ps.PRUDPv1ConnectionSignatureKey = snapshot.PRUDPv1ConnectionSignatureKey
+	}
+
+	restored := 0
+
+	for _, session := range snapshot.Sessions {
+		endpoint, ok := ps.Endpoints.Get(session.EndPointStreamID)
+		if !ok {
+			ps.log().Warn("Skipping session for unbound PRUDPEndPoint", "stream_id", session.EndPointStreamID, "address", session.Address)
+			continue
+		}
+
+		address, err := net.ResolveUDPAddr("udp", session.Address)
+		if err != nil {
+			return restored, fmt.Errorf("Failed to restore session for %s. %s", session.Address, err)
+		}
+
+		connection, err := endpoint.restoreConnection(NewSocketConnection(ps, address, nil), session)
+		if err != nil {
+			return restored, fmt.Errorf("Failed to restore session for %s. %s", session.Address, err)
+		}
+
+		discriminator := fmt.Sprintf("%s-%d-%d", address.String(), connection.StreamType, connection.StreamID)
+		endpoint.Connections.Set(discriminator, connection)
+		ps.Metrics.connectionOpened(endpoint)
+
+		connection.startHeartbeat()
+
+		restored++
+	}
+
+	return restored, nil
+}
+
+// sessionState returns the state of the connection needed to resume it later
+func (pc *PRUDPConnection) sessionState() SessionState {
+	state := SessionState{
+		EndPointStreamID:             pc.endpoint.StreamID,
+		Address:                      pc.Socket.Address.String(),
+		ID:                           pc.ID,
+		SessionID:                    pc.SessionID,
+		ServerSessionID:              pc.ServerSessionID,
+		SessionKey:                   pc.SessionKey,
+		PID:                          uint64(pc.PID()),
+		DefaultPRUDPVersion:          pc.DefaultPRUDPVersion,
+		StreamType:                   pc.StreamType,
+		StreamID:                     pc.StreamID,
+		Signature:                    pc.Signature,
+		ServerConnectionSignature:    pc.ServerConnectionSignature,
+		UnreliablePacketBaseKey:      pc.UnreliablePacketBaseKey,
+		OutgoingUnreliableSequenceID: pc.outgoingUnreliableSequenceIDCounter.Value,
+		OutgoingPingSequenceID:       pc.outgoingPingSequenceIDCounter.Value,
+		StationURLs:                  make([]string, 0, len(pc.StationURLs)),
+		Substreams:                   make([]SubstreamState, 0),
+	}
+
+	for _, stationURL := range pc.StationURLs {
+		state.StationURLs = append(state.StationURLs, stationURL.URL())
+	}
+
+	pc.slidingWindows.Each(func(substreamID uint8, slidingWindow *SlidingWindow) bool {
+		substream := SubstreamState{
+			ID:                 substreamID,
+			OutgoingSequenceID: slidingWindow.sequenceIDCounter.Value,
+		}
+
+		if packetDispatchQueue, ok := pc.packetDispatchQueues.Get(substreamID); ok {
+			substream.NextIncomingSequenceID = packetDispatchQueue.nextExpectedSequenceId.Value
+		} else {
+			substream.NextIncomingSequenceID = NewPacketDispatchQueue().nextExpectedSequenceId.Value
+		}
+
+		if cipher, ok := slidingWindow.streamSettings.EncryptionAlgorithm.(encryption.Resumable); ok {
+			cipherState := cipher.State()
+			substream.Cipher = &cipherState
+		}
+
+		state.Substreams = append(state.Substreams, substream)
+
+		return false
+	})
+
+	return state
+}
+
+// restoreConnection creates a connected PRUDPConnection from a saved SessionState
+func (pep *PRUDPEndPoint) restoreConnection(socket *SocketConnection, state SessionState) (*PRUDPConnection, error) {
+	connection := NewPRUDPConnection(socket)
+	connection.endpoint = pep
+	connection.ConnectionState = StateConnected
+	connection.ID = state.ID
+	connection.SessionID = state.SessionID
+	connection.ServerSessionID = state.ServerSessionID
+	connection.SessionKey = state.SessionKey
+	connection.pid = types.NewPID(state.PID)
+	connection.DefaultPRUDPVersion = state.DefaultPRUDPVersion
+	connection.StreamType = state.StreamType
+	connection.StreamID = state.StreamID
+	connection.StreamSettings = pep.defaultStreamSettings().Copy()
+	connection.Signature = state.Signature
+	connection.ServerConnectionSignature = state.ServerConnectionSignature
+	connection.UnreliablePacketBaseKey = state.UnreliablePacketBaseKey
+	connection.outgoingUnreliableSequenceIDCounter = NewCounter(state.OutgoingUnreliableSequenceID)
+	connection.outgoingPingSequenceIDCounter = NewCounter(state.OutgoingPingSequenceID)
+
+	for _, url := range state.StationURLs {
+		connection.StationURLs = append(connection.StationURLs, types.NewStationURL(types.NewString(url)))
+	}
+
+	for _, substream := range state.Substreams {
+		slidingWindow := connection.CreateSlidingWindow(substream.ID)
+		slidingWindow.sequenceIDCounter.Value = substream.OutgoingSequenceID
+
+		if substream.Cipher != nil {
+			cipher, ok := slidingWindow.streamSettings.EncryptionAlgorithm.(encryption.Resumable)
+			if !ok {
+				return nil, fmt.Errorf("Encryption algorithm of substream %d can not be restored", substream.ID)
+			}
+
+			if err := cipher.Restore(*substream.Cipher); err != nil {
+				return nil, err
+			}
+		}
+
+		packetDispatchQueue := connection.CreatePacketDispatchQueue(substream.ID)
+		packetDispatchQueue.nextExpectedSequenceId.Value = substream.NextIncomingSequenceID
+	}
+
+	// * New connections must not reuse the IDs of restored ones
+	if pep.ConnectionIDCounter.Value < state.ID {
+		pep.ConnectionIDCounter.Value = state.ID
+	}
+
+	return connection, nil
+}
+
+// WriteFile writes the snapshot to a file as JSON.
+// The snapshot contains session keys, so the file is only readable by the current user
+func (ss *SessionSnapshot) WriteFile(path string) error {
+	data, err := json.Marshal(ss)
+	if err != nil {
+		return err
+	}
+
+	// * Write to a temporary file first, so a crash
+	// * never leaves a partially written snapshot
+	temp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(temp.Name())
+
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return err
+	}
+
+	if err := temp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(temp.Name(), path)
+}
+
+// LoadSessionSnapshot reads a snapshot written by SessionSnapshot.WriteFile
+func LoadSessionSnapshot(path string) (*SessionSnapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &SessionSnapshot{}
+
+	if err := json.Unmarshal(data, snapshot); err != nil {
+		return nil, fmt.Errorf("Failed to decode session snapshot. %s", err)
+	}
+
+	return snapshot, nil
+}
@@ -0,0 +1,90 @@
+package nex
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/PretendoNetwork/nex-go/v2/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestSessionServer() (*PRUDPServer, *PRUDPEndPoint) {
+	server := NewPRUDPServer()
+	endpoint := NewPRUDPEndPoint(1)
+	endpoint.IsSecureEndPoint = true
+	server.BindPRUDPEndPoint(endpoint)
+
+	return server, endpoint
+}
+
+func TestSessionSnapshotRestore(t *testing.T) {
+	server, endpoint := newTestSessionServer()
+	server.SessionSnapshotPath = filepath.Join(t.TempDir(), "sessions.json")
+
+	address := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 60000}
+
+	connection := NewPRUDPConnection(NewSocketConnection(server, address, nil))
+	connection.endpoint = endpoint
+	connection.ID = endpoint.ConnectionIDCounter.Next()
+	connection.StreamSettings = endpoint.DefaultStreamSettings.Copy()
+	connection.ConnectionState = StateConnected
+	connection.SetPID(types.NewPID(1000))
+	connection.InitializeSlidingWindows(1)
+	connection.InitializePacketDispatchQueues(1)
+	connection.setSessionKey([]byte("0123456789abcdef"))
+	connection.StationURLs = append(connection.StationURLs, types.NewStationURL(types.NewString("prudps:/address=127.0.0.1;port=60000")))
+	endpoint.Connections.Set("test", connection)
+
+	// * Move the substreams along, as if packets were exchanged
+	slidingWindow := connection.SlidingWindow(1)
+	slidingWindow.NextOutgoingSequenceID()
+	_, _ = slidingWindow.Encrypt([]byte("response"))
+	_, _ = slidingWindow.Decrypt([]byte("request"))
+	connection.PacketDispatchQueue(1).nextExpectedSequenceId.Next()
+
+	assert.NoError(t, server.Shutdown(context.Background()))
+
+	snapshot, err := LoadSessionSnapshot(server.SessionSnapshotPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Len(t, snapshot.Sessions, 1)
+
+	restartedServer, restartedEndpoint := newTestSessionServer()
+
+	restored, err := restartedServer.RestoreSessions(snapshot)
+	if !assert.NoError(t, err) || !assert.Equal(t, 1, restored) {
+		return
+	}
+
+	restoredConnection, ok := restartedEndpoint.Connections.Get("127.0.0.1:60000-0-0")
+	if !assert.True(t, ok) {
+		return
+	}
+
+	defer restoredConnection.stopHeartbeatTimers()
+
+	assert.Equal(t, StateConnected, restoredConnection.ConnectionState)
+	assert.Equal(t, types.NewPID(1000), restoredConnection.PID())
+	assert.Equal(t, connection.SessionKey, restoredConnection.SessionKey)
+	assert.Equal(t, connection.UnreliablePacketBaseKey, restoredConnection.UnreliablePacketBaseKey)
+	assert.True(t, connection.StationURLs[0].Equals(restoredConnection.StationURLs[0]))
+	assert.Equal(t, server.PRUDPv1ConnectionSignatureKey, restartedServer.PRUDPv1ConnectionSignatureKey)
+	assert.Equal(t, uint32(1), restartedEndpoint.ConnectionIDCounter.Value)
+
+	// * The restored substreams continue where the original ones stopped
+	restoredSlidingWindow := restoredConnection.SlidingWindow(1)
+	assert.Equal(t, uint16(2), restoredSlidingWindow.NextOutgoingSequenceID())
+	assert.Equal(t, uint16(3), restoredConnection.PacketDispatchQueue(1).nextExpectedSequenceId.Value)
+
+	expected, _ := slidingWindow.Encrypt([]byte("next"))
+	encrypted, _ := restoredSlidingWindow.Encrypt([]byte("next"))
+	assert.Equal(t, expected, encrypted)
+
+	expected, _ = slidingWindow.Decrypt([]byte("next"))
+	decrypted, _ := restoredSlidingWindow.Decrypt([]byte("next"))
+	assert.Equal(t, expected, decrypted)
+}
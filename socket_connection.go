@@ -12,6 +12,12 @@ type SocketConnection struct {
 	Server              *PRUDPServer // * PRUDP server the socket is connected to
 	Address             net.Addr     // * Sockets address
 	WebSocketConnection *gws.Conn    // * Only used in PRUDPLite
+	session             *WebSocketSession
+}
+
+// WebSocketSession returns the WebSocket session the socket belongs to. Only used in PRUDPLite, nil otherwise
+func (sc *SocketConnection) WebSocketSession() *WebSocketSession {
+	return sc.session
 }
 
 // NewSocketConnection creates a new SocketConnection
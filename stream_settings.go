@@ -38,6 +38,7 @@ func (ss *StreamSettings) Copy() *StreamSettings {
 	copied.ChecksumBase = ss.ChecksumBase
 	copied.FaultDetectionEnabled = ss.FaultDetectionEnabled
 	copied.InitialRTT = ss.InitialRTT
+	copied.SynInitialRTT = ss.SynInitialRTT
 	copied.EncryptionAlgorithm = ss.EncryptionAlgorithm.Copy()
 	copied.ExtraRetransmitTimeoutMultiplier = ss.ExtraRetransmitTimeoutMultiplier
 	copied.WindowSize = ss.WindowSize
@@ -2,6 +2,7 @@
 package main
 
 import (
+	"context"
 	"encoding/hex"
 	"fmt"
 
@@ -20,8 +21,7 @@ func startAuthenticationServer() {
 
 	authEndpoint = nex.NewPRUDPEndPoint(1)
 
-	authEndpoint.AccountDetailsByPID = accountDetailsByPID
-	authEndpoint.AccountDetailsByUsername = accountDetailsByUsername
+	authEndpoint.AccountStore = accountStore
 	authEndpoint.ServerAccount = authenticationServerAccount
 
 	authEndpoint.OnData(func(packet nex.PacketInterface) {
@@ -68,7 +68,12 @@ func login(packet nex.PRUDPPacketInterface) {
 
 	retval := types.NewQResultSuccess(0x00010001)
 	pidPrincipal := sourceAccount.PID
-	pbufResponse := types.NewBuffer(generateTicket(sourceAccount, targetAccount, authServer.SessionKeyLength))
+	ticket, err := nex.NewKerberosTicketService(authServer, accountStore).IssueTicket(context.Background(), sourceAccount, targetAccount)
+	if err != nil {
+		panic(err)
+	}
+
+	pbufResponse := types.NewBuffer(ticket)
 	pConnectionData := types.NewRVConnectionData()
 	strReturnMsg := types.NewString("Test Build")
 
@@ -133,7 +138,12 @@ func requestTicket(packet nex.PRUDPPacketInterface) {
 	targetAccount, _ := accountDetailsByPID(idTarget)
 
 	retval := types.NewQResultSuccess(0x00010001)
-	pbufResponse := types.NewBuffer(generateTicket(sourceAccount, targetAccount, authServer.SessionKeyLength))
+	ticket, err := nex.NewKerberosTicketService(authServer, accountStore).IssueTicket(context.Background(), sourceAccount, targetAccount)
+	if err != nil {
+		panic(err)
+	}
+
+	pbufResponse := types.NewBuffer(ticket)
 
 	responseStream := nex.NewByteStreamOut(authEndpoint.LibraryVersions(), authEndpoint.ByteStreamSettings())
 
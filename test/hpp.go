@@ -79,8 +79,7 @@ func startHPPServer() {
 
 	hppServer.LibraryVersions().SetDefault(nex.NewLibraryVersion(2, 4, 1))
 	hppServer.SetAccessKey("76f26496")
-	hppServer.AccountDetailsByPID = accountDetailsByPID
-	hppServer.AccountDetailsByUsername = accountDetailsByUsername
+	hppServer.AccountStore = accountStore
 
 	hppServer.Listen(12345)
 }
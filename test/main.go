@@ -2,6 +2,7 @@ package main
 
 import (
 	"sync"
+	"time"
 
 	"github.com/PretendoNetwork/nex-go/v2"
 	"github.com/PretendoNetwork/nex-go/v2/types"
@@ -12,6 +13,7 @@ var wg sync.WaitGroup
 var authenticationServerAccount *nex.Account
 var secureServerAccount *nex.Account
 var testUserAccount *nex.Account
+var accountStore *nex.CachingAccountStore
 
 func accountDetailsByPID(pid types.PID) (*nex.Account, *nex.Error) {
 	if pid.Equals(authenticationServerAccount.PID) {
@@ -50,6 +52,11 @@ func main() {
 	secureServerAccount = nex.NewAccount(types.NewPID(2), "Quazal Rendez-Vous", "securepassword")
 	testUserAccount = nex.NewAccount(types.NewPID(1800000000), "1800000000", "nexuserpassword")
 
+	accountStore = nex.NewCachingAccountStore(nex.AccountStoreFuncs{
+		ByPID:      accountDetailsByPID,
+		ByUsername: accountDetailsByUsername,
+	}, time.Hour)
+
 	wg.Add(3)
 
 	go startAuthenticationServer()
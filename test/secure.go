@@ -49,8 +49,7 @@ func startSecureServer() {
 
 	secureEndpoint = nex.NewPRUDPEndPoint(1)
 
-	secureEndpoint.AccountDetailsByPID = accountDetailsByPID
-	secureEndpoint.AccountDetailsByUsername = accountDetailsByUsername
+	secureEndpoint.AccountStore = accountStore
 	secureEndpoint.ServerAccount = secureServerAccount
 	secureEndpoint.IsSecureEndPoint = true
 
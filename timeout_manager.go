@@ -7,10 +7,9 @@ import (
 
 // TimeoutManager is an implementation of rdv::TimeoutManager and manages the resending of reliable PRUDP packets
 type TimeoutManager struct {
-	ctx            context.Context
-	cancel         context.CancelFunc
-	packets        *MutexMap[uint16, PRUDPPacketInterface]
-	streamSettings *StreamSettings
+	ctx     context.Context
+	cancel  context.CancelFunc
+	packets *MutexMap[uint16, PRUDPPacketInterface]
 }
 
 // SchedulePacketTimeout adds a packet to the scheduler and begins it's timer
@@ -34,10 +33,14 @@ func (tm *TimeoutManager) SchedulePacketTimeout(packet PRUDPPacketInterface) {
 func (tm *TimeoutManager) AcknowledgePacket(sequenceID uint16) {
 	// * Acknowledge the packet
 	tm.packets.RunAndDelete(sequenceID, func(_ uint16, packet PRUDPPacketInterface) {
+		connection := packet.Sender().(*PRUDPConnection)
+		connection.traceAcknowledged(packet)
+
 		// * Update the RTT on the connection if the packet hasn't been resent
-		if packet.SendCount() >= tm.streamSettings.RTTRetransmit {
+		if packet.SendCount() >= connection.streamSettings().RTTRetransmit {
 			rttm := time.Since(packet.SentAt())
-			packet.Sender().(*PRUDPConnection).rtt.Adjust(rttm)
+			connection.rtt.Adjust(rttm)
+			connection.endpoint.Server.Metrics.rttMeasured(connection.endpoint, rttm)
 		}
 	})
 }
@@ -48,7 +51,7 @@ func (tm *TimeoutManager) start(packet PRUDPPacketInterface) {
 	connection := packet.Sender().(*PRUDPConnection)
 
 	// * If the connection is closed stop trying to resend
-	if connection.ConnectionState != StateConnected {
+	if connection.state() != StateConnected {
 		return
 	}
 
@@ -56,7 +59,7 @@ func (tm *TimeoutManager) start(packet PRUDPPacketInterface) {
 		endpoint := packet.Sender().Endpoint().(*PRUDPEndPoint)
 
 		// * This is `<` instead of `<=` for accuracy with observed behavior, even though we're comparing send count vs _resend_ max
-		if packet.SendCount() < tm.streamSettings.MaxPacketRetransmissions {
+		if packet.SendCount() < connection.streamSettings().MaxPacketRetransmissions {
 			packet.incrementSendCount()
 			packet.setSentAt(time.Now())
 			rto := endpoint.ComputeRetransmitTimeout(packet)
@@ -67,15 +70,24 @@ func (tm *TimeoutManager) start(packet PRUDPPacketInterface) {
 			timeout.ctx = ctx
 			timeout.cancel = cancel
 
+			// * Encode the packet before the next attempt is scheduled,
+			// * since encoding it updates it's fields
+			data := packet.Bytes()
+
 			// * Schedule the packet to be resent
 			go tm.start(packet)
 
 			// * Resend the packet to the connection
 			server := connection.endpoint.Server
-			data := packet.Bytes()
-			server.sendRaw(connection.Socket, data)
+			connection.counters.retransmitted(len(data))
+			server.Metrics.packetRetransmitted(endpoint)
+			connection.traceRetransmission(packet)
+			if err := server.sendRaw(connection.Socket, data); err != nil {
+				endpoint.handleError(ResultCodes.Transport.IOError, packet, err)
+			}
 		} else {
 			// * Packet has been retried too many times, consider the connection dead
+			endpoint.Server.Metrics.connectionTimedOut(endpoint)
 			endpoint.cleanupConnection(connection)
 		}
 	}
@@ -91,9 +103,8 @@ func (tm *TimeoutManager) Stop() {
 func NewTimeoutManager() *TimeoutManager {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &TimeoutManager{
-		ctx:            ctx,
-		cancel:         cancel,
-		packets:        NewMutexMap[uint16, PRUDPPacketInterface](),
-		streamSettings: NewStreamSettings(),
+		ctx:     ctx,
+		cancel:  cancel,
+		packets: NewMutexMap[uint16, PRUDPPacketInterface](),
 	}
 }
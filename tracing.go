@@ -0,0 +1,197 @@
+package nex
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/PretendoNetwork/nex-go/v2/constants"
+)
+
+// Tracer starts the spans used to trace RMC calls, from the arrival of the first request fragment
+// until the response has been sent, and acknowledged if it was sent reliably.
+//
+// Tracer and Span mirror the parts of the OpenTelemetry tracing API used by the server, without
+// depending on it. An OpenTelemetry trace.Tracer can be used with a small adapter which converts
+// the start time into trace.WithTimestamp and the key value pair attributes into attribute.KeyValue.
+// Attributes are passed as alternating keys and values, the same as with Logger
+type Tracer interface {
+	Start(ctx context.Context, name string, startTime time.Time, attributes ...any) (context.Context, Span)
+}
+
+// Span is a single traced RMC call started by a Tracer
+type Span interface {
+	AddEvent(name string, attributes ...any)
+	RecordError(err error)
+	End()
+}
+
+// rmcTrace is the span of an RMC call currently being handled
+type rmcTrace struct {
+	sync.Mutex
+	ctx              context.Context
+	span             Span
+	pendingFragments int // * Reliable response fragments which have not yet been acknowledged
+}
+
+func (t *rmcTrace) addEvent(name string, attributes ...any) {
+	if t == nil {
+		return
+	}
+
+	t.span.AddEvent(name, attributes...)
+}
+
+func (t *rmcTrace) recordError(err error) {
+	if t == nil {
+		return
+	}
+
+	t.span.RecordError(err)
+}
+
+func (t *rmcTrace) end() {
+	if t == nil {
+		return
+	}
+
+	t.span.End()
+}
+
+// fragmentReassembly tracks the fragments of a message being reassembled on a substream
+type fragmentReassembly struct {
+	startedAt time.Time
+	fragments int
+}
+
+// startRMCTrace starts a span for an RMC request. Returns nil if the tracer is nil or the message is not a request
+func startRMCTrace(ctx context.Context, tracer Tracer, message *RMCMessage, startTime time.Time, attributes ...any) *rmcTrace {
+	if tracer == nil || message == nil || !message.IsRequest {
+		return nil
+	}
+
+	name := fmt.Sprintf("RMC %d/%d", message.ProtocolID, message.MethodID)
+	attributes = append(attributes,
+		"protocol_id", message.ProtocolID,
+		"method_id", message.MethodID,
+		"call_id", message.CallID,
+	)
+
+	ctx, span := tracer.Start(ctx, name, startTime, attributes...)
+
+	return &rmcTrace{
+		ctx:  ctx,
+		span: span,
+	}
+}
+
+// trackIncomingFragment records the arrival of a fragment on the given substream
+func (pc *PRUDPConnection) trackIncomingFragment(substreamID uint8) {
+	reassembly, ok := pc.fragmentReassemblies.Get(substreamID)
+	if !ok {
+		reassembly = fragmentReassembly{startedAt: time.Now()}
+	}
+
+	reassembly.fragments++
+	pc.fragmentReassemblies.Set(substreamID, reassembly)
+}
+
+// startTrace starts a span for an RMC request received on the connection
+func (pc *PRUDPConnection) startTrace(message *RMCMessage, reassembly fragmentReassembly, attributes ...any) {
+	attributes = append(connectionLogFields(pc), attributes...)
+	attributes = append(attributes, "transport", "prudp", "fragments", reassembly.fragments)
+
+	trace := startRMCTrace(pc.ctx, pc.endpoint.Server.Tracer, message, reassembly.startedAt, attributes...)
+	if trace == nil {
+		return
+	}
+
+	// * Call IDs are unique per connection, so an existing trace can only
+	// * be a call the client has given up on
+	pc.endTrace(message.CallID, "nex.rmc.superseded")
+
+	pc.traces.Set(message.CallID, trace)
+}
+
+// traceResponse records an RMC response about to be sent in the given number of fragments.
+// Reliable responses end the trace once all fragments are acknowledged, unreliable ones with endTrace
+func (pc *PRUDPConnection) traceResponse(packet PRUDPPacketInterface, fragments int) {
+	trace := pc.responseTrace(packet)
+	if trace == nil {
+		return
+	}
+
+	message := packet.RMCMessage()
+	trace.addEvent("nex.rmc.response",
+		"success", message.IsSuccess,
+		"error_code", message.ErrorCode,
+		"size", len(packet.Payload()),
+		"fragments", fragments,
+	)
+
+	if packet.HasFlag(constants.PacketFlagReliable) && packet.HasFlag(constants.PacketFlagNeedsAck) {
+		// * Set before any fragment is sent, so an early
+		// * acknowledgement can not end the trace too soon
+		trace.Lock()
+		trace.pendingFragments += fragments
+		trace.Unlock()
+	}
+}
+
+// responseTrace returns the active trace of the RMC call the response packet belongs to, if any
+func (pc *PRUDPConnection) responseTrace(packet PRUDPPacketInterface) *rmcTrace {
+	message := packet.RMCMessage()
+
+	// * Requests sent by the server use their own call IDs,
+	// * which may collide with the clients
+	if message == nil || message.IsRequest {
+		return nil
+	}
+
+	trace, _ := pc.traces.Get(message.CallID)
+
+	return trace
+}
+
+// endTrace ends the trace of an RMC call
+func (pc *PRUDPConnection) endTrace(callID uint32, event string) {
+	pc.traces.RunAndDelete(callID, func(_ uint32, trace *rmcTrace) {
+		trace.addEvent(event)
+		trace.end()
+	})
+}
+
+// traceAcknowledged records the acknowledgement of a reliable response fragment
+func (pc *PRUDPConnection) traceAcknowledged(packet PRUDPPacketInterface) {
+	trace := pc.responseTrace(packet)
+	if trace == nil {
+		return
+	}
+
+	trace.Lock()
+	trace.pendingFragments--
+	done := trace.pendingFragments <= 0
+	trace.Unlock()
+
+	if done {
+		pc.endTrace(packet.RMCMessage().CallID, "nex.rmc.response.acknowledged")
+	}
+}
+
+// traceRetransmission records a reliable response fragment being resent
+func (pc *PRUDPConnection) traceRetransmission(packet PRUDPPacketInterface) {
+	pc.responseTrace(packet).addEvent("nex.prudp.retransmit",
+		"sequence_id", packet.SequenceID(),
+		"fragment_id", packet.getFragmentID(),
+		"send_count", packet.SendCount(),
+	)
+}
+
+// endTraces ends all active traces on the connection
+func (pc *PRUDPConnection) endTraces(reason string) {
+	pc.traces.Clear(func(_ uint32, trace *rmcTrace) {
+		trace.addEvent(reason)
+		trace.end()
+	})
+}
@@ -0,0 +1,90 @@
+package nex
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PretendoNetwork/nex-go/v2/constants"
+	"github.com/stretchr/testify/assert"
+)
+
+type testSpan struct {
+	sync.Mutex
+	name   string
+	events []string
+	ended  bool
+}
+
+func (s *testSpan) AddEvent(name string, attributes ...any) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.events = append(s.events, name)
+}
+
+func (s *testSpan) RecordError(err error) {}
+
+func (s *testSpan) End() {
+	s.Lock()
+	defer s.Unlock()
+
+	s.ended = true
+}
+
+type testTracer struct {
+	spans []*testSpan
+}
+
+func (t *testTracer) Start(ctx context.Context, name string, startTime time.Time, attributes ...any) (context.Context, Span) {
+	span := &testSpan{name: name}
+	t.spans = append(t.spans, span)
+
+	return ctx, span
+}
+
+func TestReliableResponseTraceEndsWhenAcknowledged(t *testing.T) {
+	tracer := &testTracer{}
+
+	server := NewPRUDPServer()
+	server.Tracer = tracer
+
+	endpoint := NewPRUDPEndPoint(1)
+	server.BindPRUDPEndPoint(endpoint)
+
+	connection := NewPRUDPConnection(NewSocketConnection(server, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1}, nil))
+	connection.endpoint = endpoint
+
+	request := NewRMCRequest(endpoint)
+	request.ProtocolID = 10
+	request.MethodID = 1
+	request.CallID = 5
+
+	connection.trackIncomingFragment(0)
+	connection.trackIncomingFragment(0)
+	reassembly, _ := connection.fragmentReassemblies.Get(0)
+	connection.startTrace(request, reassembly)
+
+	assert.Len(t, tracer.spans, 1)
+	assert.Equal(t, "RMC 10/1", tracer.spans[0].name)
+
+	response := NewRMCSuccess(endpoint, nil)
+	response.CallID = 5
+
+	packet, _ := NewPRUDPPacketV1(server, connection, nil)
+	packet.AddFlag(constants.PacketFlagReliable)
+	packet.AddFlag(constants.PacketFlagNeedsAck)
+	packet.SetRMCMessage(response)
+
+	connection.traceResponse(packet, 2)
+	connection.traceRetransmission(packet)
+	connection.traceAcknowledged(packet)
+	assert.False(t, tracer.spans[0].ended)
+
+	connection.traceAcknowledged(packet)
+	assert.True(t, tracer.spans[0].ended)
+	assert.Equal(t, []string{"nex.rmc.response", "nex.prudp.retransmit", "nex.rmc.response.acknowledged"}, tracer.spans[0].events)
+	assert.False(t, connection.traces.Has(5))
+}
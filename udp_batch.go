@@ -0,0 +1,216 @@
+package nex
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"golang.org/x/net/ipv4"
+)
+
+// udpReceiveSlab hands out receive buffers for batched reads.
+//
+// Decoded packets keep references to the datagram they were read from
+// (payloads are sub-slices of it), so a buffer can never be safely handed
+// back to a pool once the datagram has been processed. Instead, datagrams
+// are carved out of a large shared slab. Each datagram only consumes the
+// bytes it actually used, and old slabs are released by the GC once every
+// packet referencing them is gone. This removes the per-datagram allocation
+// of the single socket path
+type udpReceiveSlab struct {
+	settings *UDPSettings
+	buffer   []byte
+	offset   int
+}
+
+// prepare points every message slot at a free region of the slab, replacing the slab if it is too full
+func (s *udpReceiveSlab) prepare(messages []ipv4.Message) {
+	if len(s.buffer)-s.offset < len(messages)*s.settings.MaxDatagramSize {
+		s.buffer = make([]byte, max(s.settings.SlabSize, len(messages)*s.settings.MaxDatagramSize))
+		s.offset = 0
+	}
+
+	offset := s.offset
+	for i := range messages {
+		messages[i].Buffers[0] = s.buffer[offset : offset+s.settings.MaxDatagramSize]
+		offset += s.settings.MaxDatagramSize
+	}
+}
+
+// take returns the datagram read into the given slot and compacts it into the slab
+func (s *udpReceiveSlab) take(message ipv4.Message) []byte {
+	// * Slots are laid out back to back, so the data for
+	// * every slot after the first needs to be moved down
+	// * to sit directly after the previous datagram
+	start := s.offset
+	copy(s.buffer[start:], message.Buffers[0][:message.N])
+	s.offset += message.N
+
+	// * Cap the slice so appends can never write into
+	// * the next datagram
+	return s.buffer[start:s.offset:s.offset]
+}
+
+func newUDPReceiveSlab(settings *UDPSettings) *udpReceiveSlab {
+	return &udpReceiveSlab{settings: settings}
+}
+
+// serveUDPBatch reads datagrams from the socket in batches until an error occurs, calling handler for each one
+func serveUDPBatch(conn *ipv4.PacketConn, settings *UDPSettings, handler func(packetData []byte, address net.Addr)) error {
+	slab := newUDPReceiveSlab(settings)
+	messages := make([]ipv4.Message, settings.BatchSize)
+	for i := range messages {
+		messages[i].Buffers = make([][]byte, 1)
+	}
+
+	for {
+		slab.prepare(messages)
+
+		read, err := conn.ReadBatch(messages, 0)
+		if err != nil {
+			return fmt.Errorf("reading batch from udp socket: %w", err)
+		}
+
+		for i := 0; i < read; i++ {
+			if messages[i].N == 0 {
+				continue
+			}
+
+			handler(slab.take(messages[i]), messages[i].Addr)
+		}
+	}
+}
+
+// udpBatchRequest is a datagram queued on a udpBatchWriter, and the channel the result of sending it is reported on
+type udpBatchRequest struct {
+	message ipv4.Message
+	result  chan error
+}
+
+// udpBatchResults pools the result channels of udpBatchRequest, so sends don't allocate one each
+var udpBatchResults = sync.Pool{
+	New: func() any {
+		return make(chan error, 1)
+	},
+}
+
+// udpBatchWriter queues outgoing datagrams for a socket and flushes them with sendmmsg.
+//
+// Senders wait for their datagram to be written, so write errors are returned to them like on the unbatched path.
+// Concurrent senders still share syscalls, since every datagram queued while a batch is being written goes in the next one
+type udpBatchWriter struct {
+	conn      *ipv4.PacketConn
+	queue     chan udpBatchRequest
+	batchSize int
+	closeLock sync.RWMutex
+	closed    bool
+	done      chan struct{}
+}
+
+// write queues the datagram and waits for it to be written
+func (w *udpBatchWriter) write(data []byte, address *net.UDPAddr) error {
+	w.closeLock.RLock()
+
+	if w.closed {
+		w.closeLock.RUnlock()
+		return net.ErrClosed
+	}
+
+	result := udpBatchResults.Get().(chan error)
+
+	w.queue <- udpBatchRequest{
+		message: ipv4.Message{
+			Buffers: [][]byte{data},
+			Addr:    address,
+		},
+		result: result,
+	}
+
+	w.closeLock.RUnlock()
+
+	err := <-result
+	udpBatchResults.Put(result)
+
+	return err
+}
+
+// close stops the writer once every queued datagram has been written. Later writes return net.ErrClosed
+func (w *udpBatchWriter) close() {
+	w.closeLock.Lock()
+
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+
+	w.closeLock.Unlock()
+
+	<-w.done
+}
+
+func (w *udpBatchWriter) run() {
+	defer close(w.done)
+
+	requests := make([]udpBatchRequest, 0, w.batchSize)
+	batch := make([]ipv4.Message, 0, w.batchSize)
+
+	for request := range w.queue {
+		requests = append(requests[:0], request)
+
+		// * Grab whatever else is already waiting, without
+		// * blocking, so busy sockets send many datagrams
+		// * per syscall while idle sockets send immediately
+	drain:
+		for len(requests) < w.batchSize {
+			select {
+			case request, ok := <-w.queue:
+				if !ok {
+					break drain
+				}
+
+				requests = append(requests, request)
+			default:
+				break drain
+			}
+		}
+
+		batch = batch[:0]
+		for _, request := range requests {
+			batch = append(batch, request.message)
+		}
+
+		sent := 0
+		for sent < len(batch) {
+			written, err := w.conn.WriteBatch(batch[sent:], 0)
+			if err == nil && written == 0 {
+				err = errors.New("No datagrams written")
+			}
+
+			if err != nil {
+				// * The datagram which failed is reported, and the
+				// * rest are retried, since one bad address should
+				// * not fail every datagram batched with it
+				requests[sent].result <- fmt.Errorf("Failed to write batch to UDP socket. %s", err.Error())
+				sent++
+
+				continue
+			}
+
+			for _, request := range requests[sent : sent+written] {
+				request.result <- nil
+			}
+
+			sent += written
+		}
+	}
+}
+
+func newUDPBatchWriter(conn *ipv4.PacketConn, settings *UDPSettings) *udpBatchWriter {
+	return &udpBatchWriter{
+		conn:      conn,
+		queue:     make(chan udpBatchRequest, settings.WriteQueueSize),
+		batchSize: settings.BatchSize,
+		done:      make(chan struct{}),
+	}
+}
@@ -0,0 +1,144 @@
+package nex
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/ipv4"
+)
+
+// newTestUDPMessages returns message slots as serveUDPBatch creates them
+func newTestUDPMessages(count int) []ipv4.Message {
+	messages := make([]ipv4.Message, count)
+	for i := range messages {
+		messages[i].Buffers = make([][]byte, 1)
+	}
+
+	return messages
+}
+
+// receiveTestDatagram fills a prepared slot as if the kernel had read data into it
+func receiveTestDatagram(message *ipv4.Message, data string) {
+	message.N = copy(message.Buffers[0], data)
+}
+
+func TestUDPReceiveSlabCompaction(t *testing.T) {
+	settings := NewUDPSettings()
+	settings.MaxDatagramSize = 8
+	settings.SlabSize = 64
+
+	slab := newUDPReceiveSlab(settings)
+	messages := newTestUDPMessages(3)
+
+	slab.prepare(messages)
+	receiveTestDatagram(&messages[0], "abc")
+	receiveTestDatagram(&messages[1], "defgh")
+	receiveTestDatagram(&messages[2], "ij")
+
+	first := slab.take(messages[0])
+	second := slab.take(messages[1])
+	third := slab.take(messages[2])
+
+	assert.Equal(t, "abc", string(first))
+	assert.Equal(t, "defgh", string(second))
+	assert.Equal(t, "ij", string(third))
+
+	// * The datagrams sit back to back, and only use the bytes which were read
+	assert.Equal(t, "abcdefghij", string(slab.buffer[:slab.offset]))
+
+	// * Appending to a datagram can never overwrite the one after it
+	assert.Equal(t, len(first), cap(first))
+	_ = append(first, 'x')
+	assert.Equal(t, "defgh", string(second))
+
+	// * The next batch continues in the same slab, without moving the earlier datagrams
+	slab.prepare(messages)
+	receiveTestDatagram(&messages[0], "kl")
+
+	assert.Equal(t, "kl", string(slab.take(messages[0])))
+	assert.Equal(t, "abc", string(first))
+	assert.Equal(t, "abcdefghijkl", string(slab.buffer[:slab.offset]))
+}
+
+func TestUDPReceiveSlabRollover(t *testing.T) {
+	settings := NewUDPSettings()
+	settings.MaxDatagramSize = 8
+	settings.SlabSize = 32
+
+	slab := newUDPReceiveSlab(settings)
+	messages := newTestUDPMessages(3)
+
+	slab.prepare(messages)
+	receiveTestDatagram(&messages[0], "abcdefgh")
+	receiveTestDatagram(&messages[1], "ijklmnop")
+
+	first := slab.take(messages[0])
+	second := slab.take(messages[1])
+	oldSlab := slab.buffer
+
+	// * 16 bytes are left, which is less than the 24 needed for 3 slots
+	slab.prepare(messages)
+	assert.Equal(t, 0, slab.offset)
+	assert.NotSame(t, &oldSlab[0], &slab.buffer[0])
+
+	receiveTestDatagram(&messages[0], "qrstuvwx")
+	assert.Equal(t, "qrstuvwx", string(slab.take(messages[0])))
+
+	// * Datagrams from the old slab are untouched
+	assert.Equal(t, "abcdefgh", string(first))
+	assert.Equal(t, "ijklmnop", string(second))
+
+	// * Slabs are always large enough for a full batch
+	messages = newTestUDPMessages(5)
+	slab.prepare(messages)
+	assert.Len(t, slab.buffer, 5*settings.MaxDatagramSize)
+}
+
+func TestUDPBatchWriterRoundTrip(t *testing.T) {
+	server, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	defer server.Close()
+
+	client, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	defer client.Close()
+
+	writer := newUDPBatchWriter(ipv4.NewPacketConn(server), NewUDPSettings())
+	go writer.run()
+
+	clientAddress := client.LocalAddr().(*net.UDPAddr)
+
+	for _, datagram := range []string{"first", "second", "third"} {
+		assert.NoError(t, writer.write([]byte(datagram), clientAddress))
+	}
+
+	buffer := make([]byte, 64)
+	for _, expected := range []string{"first", "second", "third"} {
+		_ = client.SetReadDeadline(time.Now().Add(time.Second))
+
+		read, address, err := client.ReadFromUDP(buffer)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Equal(t, expected, string(buffer[:read]))
+		assert.Equal(t, server.LocalAddr().(*net.UDPAddr).Port, address.Port)
+	}
+
+	// * Write errors are returned to the sender
+	server.Close()
+	assert.Error(t, writer.write([]byte("closed socket"), clientAddress))
+
+	// * close stops the writer, and later writes fail instead of blocking forever
+	writer.close()
+	assert.True(t, errors.Is(writer.write([]byte("closed writer"), clientAddress), net.ErrClosed))
+}
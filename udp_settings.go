@@ -0,0 +1,23 @@
+package nex
+
+import "runtime"
+
+// UDPSettings defines settings for the multi-core UDP transport started with PRUDPServer.ListenUDPMulticore
+type UDPSettings struct {
+	Readers         int // * Number of SO_REUSEPORT sockets, each with it's own reader goroutine. Defaults to the number of CPUs
+	BatchSize       int // * Max number of datagrams read or written in a single recvmmsg/sendmmsg call
+	MaxDatagramSize int // * Size of each receive slot. Datagrams larger than this are truncated by the kernel
+	SlabSize        int // * Size of the receive slabs datagrams are carved out of. Must be at least BatchSize*MaxDatagramSize
+	WriteQueueSize  int // * Number of outgoing datagrams which may be queued per socket before sendRaw blocks
+}
+
+// NewUDPSettings returns a new UDPSettings
+func NewUDPSettings() *UDPSettings {
+	return &UDPSettings{
+		Readers:         runtime.NumCPU(),
+		BatchSize:       32,
+		MaxDatagramSize: 2048, // * Larger than the max PRUDP MTU of 1364 bytes
+		SlabSize:        1 << 20,
+		WriteQueueSize:  1024,
+	}
+}
@@ -1,51 +1,42 @@
 package nex
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/lxzan/gws"
 )
 
-const (
-	pingInterval = 5 * time.Second
-	pingWait     = 10 * time.Second
-)
-
 type wsEventHandler struct {
 	prudpServer *PRUDPServer
+	ws          *WebSocketServer
 }
 
 func (wseh *wsEventHandler) OnOpen(socket *gws.Conn) {
-	_ = socket.SetDeadline(time.Now().Add(pingInterval + pingWait))
+	wseh.ws.refreshDeadline(socket)
 }
 
 func (wseh *wsEventHandler) OnClose(wsConn *gws.Conn, _ error) {
-	// * Loop over all connections on all endpoints
-	wseh.prudpServer.Endpoints.Each(func(streamid uint8, pep *PRUDPEndPoint) bool {
-		connections := make([]*PRUDPConnection, 0)
-
-		pep.Connections.Each(func(discriminator string, pc *PRUDPConnection) bool {
-			if pc.Socket.Address == wsConn.RemoteAddr() {
-				connections = append(connections, pc)
-			}
-			return false
-		})
-
-		// * We cannot modify a MutexMap while looping over it
-		// * since the mutex is locked. We first need to grab
-		// * the entries we want to delete, and then loop over
-		// * them here to actually clean them up
-		for _, connection := range connections {
-			pep.cleanupConnection(connection) // * "removed" event is dispatched here
-		}
-		return false
+	var closed *WebSocketSession
+	wseh.ws.sessions.RunAndDelete(wsConn, func(_ *gws.Conn, session *WebSocketSession) {
+		closed = session
 	})
+
+	// * We can't do this during RunAndDelete, since we hold the sessions mutex then
+	if closed != nil {
+		closed.closeConnections()
+	}
 }
 
 func (wseh *wsEventHandler) OnPing(socket *gws.Conn, payload []byte) {
-	_ = socket.SetDeadline(time.Now().Add(pingInterval + pingWait))
+	wseh.ws.refreshDeadline(socket)
 	_ = socket.WritePong(nil)
 }
 
@@ -58,56 +49,223 @@ func (wseh *wsEventHandler) OnMessage(socket *gws.Conn, message *gws.Message) {
 	// * If this is not done, then the byte slice sometimes
 	// * gets modified in unexpected places
 	packetData := append([]byte(nil), message.Bytes()...)
-	err := wseh.prudpServer.handleSocketMessage(packetData, socket.RemoteAddr(), socket)
+
+	session, ok := wseh.ws.sessions.Get(socket)
+	if !ok {
+		return
+	}
+
+	err := wseh.prudpServer.handleSocketMessage(packetData, socket.RemoteAddr(), session.Socket)
 	if err != nil {
-		logger.Error(err.Error())
+		wseh.prudpServer.log().Error(err.Error(), "address", socket.RemoteAddr().String())
 	}
 }
 
-// WebSocketServer wraps a WebSocket server to create an easier API to consume
+// WebSocketServer accepts PRUDPLite connections over WebSockets for a PRUDPServer.
+//
+// WebSocketServer implements http.Handler, so it can be mounted into an existing HTTP server at any path.
+// Settings are read from the PRUDPServers WebSocketSettings when the WebSocketServer is created
 type WebSocketServer struct {
-	mux         *http.ServeMux
-	upgrader    *gws.Upgrader
-	prudpServer *PRUDPServer
+	settings       *WebSocketSettings
+	upgrader       *gws.Upgrader
+	prudpServer    *PRUDPServer
+	httpServer     *http.Server // * Started by ListenWebSocket. Guarded by httpServerLock, since Shutdown may run on another goroutine
+	httpServerLock sync.Mutex
+	sessions       *MutexMap[*gws.Conn, *WebSocketSession]
+	sessionsLock   sync.Mutex // * Held while a session is added and while Shutdown starts closing, so every session added is closed and waited for
+	wg             sync.WaitGroup
+	closing        atomic.Bool
 }
 
-func (ws *WebSocketServer) init() {
-	ws.upgrader = gws.NewUpgrader(&wsEventHandler{
-		prudpServer: ws.prudpServer,
-	}, &gws.ServerOption{
-		ParallelEnabled: true,         // * Parallel message processing
-		Recovery:        gws.Recovery, // * Exception recovery
-		ReadBufferSize:  64000,
-		WriteBufferSize: 64000,
+// ServeHTTP upgrades the request to a WebSocket connection and starts reading PRUDPLite packets from it
+func (ws *WebSocketServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if ws.closing.Load() {
+		http.Error(w, "Server is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+
+	if !ws.originAllowed(r) {
+		ws.prudpServer.log().Warn("Rejected WebSocket connection from disallowed origin", "address", r.RemoteAddr, "origin", r.Header.Get("Origin"))
+		http.Error(w, "Origin not allowed", http.StatusForbidden)
+		return
+	}
+
+	if ws.prudpServer.BanList.AddressBanned(requestAddr(r)) {
+		ws.prudpServer.log().Debug("Rejected WebSocket connection from banned address", "address", r.RemoteAddr)
+		http.Error(w, "Address banned", http.StatusForbidden)
+		return
+	}
+
+	socket, err := ws.upgrader.Upgrade(w, r)
+	if err != nil {
+		ws.prudpServer.log().Debug("WebSocket upgrade failed", "address", r.RemoteAddr, "error", err.Error())
+		return
+	}
+
+	ws.sessionsLock.Lock()
+	defer ws.sessionsLock.Unlock()
+
+	// * Shutdown may have started while upgrading
+	if ws.closing.Load() {
+		_ = socket.WriteClose(1001, []byte("server shutting down")) // * 1001 - Going Away
+		return
+	}
+
+	ws.sessions.Set(socket, newWebSocketSession(ws.prudpServer, socket))
+
+	ws.wg.Add(1)
+	go func() {
+		defer ws.wg.Done()
+		socket.ReadLoop() // * Blocking prevents the context from being GC
+	}()
+}
+
+// Sessions returns the WebSocket sessions currently open on the server
+func (ws *WebSocketServer) Sessions() []*WebSocketSession {
+	sessions := make([]*WebSocketSession, 0, ws.sessions.Size())
+
+	ws.sessions.Each(func(_ *gws.Conn, session *WebSocketSession) bool {
+		sessions = append(sessions, session)
+		return false
 	})
 
-	ws.mux = http.NewServeMux()
-	ws.mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		socket, err := ws.upgrader.Upgrade(w, r)
-		if err != nil {
-			return
+	return sessions
+}
+
+// Shutdown gracefully shuts down the server. New connections are refused, and every open connection
+// is sent a close frame and cleaned up from the PRUDPServers endpoints. Shutdown waits for the
+// connections to close until the context expires
+func (ws *WebSocketServer) Shutdown(ctx context.Context) error {
+	ws.sessionsLock.Lock()
+	ws.closing.Store(true)
+	ws.sessionsLock.Unlock()
+
+	ws.httpServerLock.Lock()
+	httpServer := ws.httpServer
+	ws.httpServerLock.Unlock()
+
+	if httpServer != nil {
+		if err := httpServer.Shutdown(ctx); err != nil {
+			return err
 		}
+	}
 
-		go func() {
-			socket.ReadLoop() // * Blocking prevents the context from being GC
-		}()
+	ws.sessions.Each(func(_ *gws.Conn, session *WebSocketSession) bool {
+		_ = session.Close(1001, "server shutting down") // * 1001 - Going Away
+		return false
 	})
+
+	done := make(chan struct{})
+	go func() {
+		ws.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
-func (ws *WebSocketServer) listen(port int) {
-	ws.init()
+// originAllowed checks the requests Origin header against the allowed origins.
+// Requests without an Origin header do not come from browsers and are always allowed
+func (ws *WebSocketServer) originAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || len(ws.settings.AllowedOrigins) == 0 {
+		return true
+	}
 
-	err := http.ListenAndServe(fmt.Sprintf(":%d", port), ws.mux)
+	originURL, err := url.Parse(origin)
 	if err != nil {
+		return false
+	}
+
+	for _, allowed := range ws.settings.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) || strings.EqualFold(allowed, originURL.Host) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (ws *WebSocketServer) refreshDeadline(socket *gws.Conn) {
+	_ = socket.SetDeadline(time.Now().Add(ws.settings.PingInterval + ws.settings.PingWait))
+}
+
+// newHTTPServer creates the http.Server used by ListenWebSocket. Returns nil if the server is already shutting down.
+// The http.Server is set before it starts listening, so Shutdown either stops it or prevents it from being created
+func (ws *WebSocketServer) newHTTPServer(port int) *http.Server {
+	ws.httpServerLock.Lock()
+	defer ws.httpServerLock.Unlock()
+
+	if ws.closing.Load() {
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(ws.settings.Path, ws)
+
+	ws.httpServer = &http.Server{
+		Addr:      fmt.Sprintf(":%d", port),
+		Handler:   mux,
+		TLSConfig: ws.settings.TLSConfig,
+	}
+
+	return ws.httpServer
+}
+
+func (ws *WebSocketServer) listen(port int) {
+	httpServer := ws.newHTTPServer(port)
+	if httpServer == nil {
+		return
+	}
+
+	err := httpServer.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		panic(err)
 	}
 }
 
 func (ws *WebSocketServer) listenSecure(port int, certFile, keyFile string) {
-	ws.init()
+	httpServer := ws.newHTTPServer(port)
+	if httpServer == nil {
+		return
+	}
 
-	err := http.ListenAndServeTLS(fmt.Sprintf(":%d", port), certFile, keyFile, ws.mux)
-	if err != nil {
+	err := httpServer.ListenAndServeTLS(certFile, keyFile)
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		panic(err)
 	}
 }
+
+// NewWebSocketServer returns a new WebSocketServer for the PRUDPServer, using it's WebSocketSettings
+func NewWebSocketServer(prudpServer *PRUDPServer) *WebSocketServer {
+	settings := prudpServer.WebSocketSettings
+	if settings == nil {
+		settings = NewWebSocketSettings()
+	}
+
+	ws := &WebSocketServer{
+		settings:    settings,
+		prudpServer: prudpServer,
+		sessions:    NewMutexMap[*gws.Conn, *WebSocketSession](),
+	}
+
+	ws.upgrader = gws.NewUpgrader(&wsEventHandler{
+		prudpServer: prudpServer,
+		ws:          ws,
+	}, &gws.ServerOption{
+		ParallelEnabled:     true,         // * Parallel message processing
+		Recovery:            gws.Recovery, // * Exception recovery
+		ReadBufferSize:      settings.ReadBufferSize,
+		ReadMaxPayloadSize:  settings.ReadMaxPayloadSize,
+		WriteMaxPayloadSize: settings.WriteMaxPayloadSize,
+		HandshakeTimeout:    settings.HandshakeTimeout,
+		SubProtocols:        settings.SubProtocols,
+	})
+
+	return ws
+}
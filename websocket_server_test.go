@@ -0,0 +1,157 @@
+package nex
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PretendoNetwork/nex-go/v2/constants"
+	"github.com/lxzan/gws"
+	"github.com/stretchr/testify/assert"
+)
+
+// testWebSocketURL mounts the servers WebSocketServer on a test HTTP server and returns it's URL
+func testWebSocketURL(t *testing.T, server *PRUDPServer) string {
+	httpServer := httptest.NewServer(server.WebSocketServer())
+	t.Cleanup(httpServer.Close)
+
+	return "ws" + strings.TrimPrefix(httpServer.URL, "http")
+}
+
+type testWebSocketClient struct {
+	gws.BuiltinEventHandler
+	closed chan struct{}
+}
+
+func (c *testWebSocketClient) OnClose(socket *gws.Conn, err error) {
+	close(c.closed)
+}
+
+func TestWebSocketServerOriginsAndShutdown(t *testing.T) {
+	server := NewPRUDPServer()
+	server.WebSocketSettings.AllowedOrigins = []string{"https://allowed.example"}
+
+	mux := http.NewServeMux()
+	mux.Handle("/prudp", server.WebSocketServer())
+
+	httpServer := httptest.NewServer(mux)
+	defer httpServer.Close()
+
+	address := "ws" + strings.TrimPrefix(httpServer.URL, "http") + "/prudp"
+
+	_, response, err := gws.NewClient(&testWebSocketClient{}, &gws.ClientOption{
+		Addr:          address,
+		RequestHeader: http.Header{"Origin": []string{"https://evil.example"}},
+	})
+	assert.Error(t, err)
+	if assert.NotNil(t, response) {
+		assert.Equal(t, http.StatusForbidden, response.StatusCode)
+	}
+
+	client := &testWebSocketClient{closed: make(chan struct{})}
+	socket, _, err := gws.NewClient(client, &gws.ClientOption{
+		Addr:          address,
+		RequestHeader: http.Header{"Origin": []string{"https://allowed.example"}},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	go socket.ReadLoop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	assert.NoError(t, server.WebSocketServer().Shutdown(ctx))
+
+	select {
+	case <-client.closed:
+	case <-time.After(5 * time.Second):
+		t.Error("Client was not disconnected on shutdown")
+	}
+}
+
+func TestWebSocketSessionOwnsConnections(t *testing.T) {
+	server := NewPRUDPServer()
+
+	endpoint := NewPRUDPEndPoint(1)
+	server.BindPRUDPEndPoint(endpoint)
+
+	ended := make(chan *PRUDPConnection, 2)
+	endpoint.OnConnectionEnded(func(connection *PRUDPConnection) {
+		ended <- connection
+	})
+
+	socket, _, err := gws.NewClient(&testWebSocketClient{closed: make(chan struct{})}, &gws.ClientOption{Addr: testWebSocketURL(t, server)})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	go socket.ReadLoop()
+
+	// * Two virtual connections multiplexed over the same WebSocket
+	for _, sourcePort := range []uint8{14, 15} {
+		syn, _ := NewPRUDPPacketLite(server, nil, nil)
+		syn.SetType(constants.SynPacket)
+		syn.AddFlag(constants.PacketFlagNeedsAck)
+		syn.SetSourceVirtualPortStreamType(constants.StreamTypeRVSecure)
+		syn.SetSourceVirtualPortStreamID(sourcePort)
+		syn.SetDestinationVirtualPortStreamType(constants.StreamTypeRVSecure)
+		syn.SetDestinationVirtualPortStreamID(1)
+
+		assert.NoError(t, socket.WriteMessage(gws.OpcodeBinary, syn.Bytes()))
+	}
+
+	assert.Eventually(t, func() bool {
+		return endpoint.Connections.Size() == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	sessions := server.WebSocketServer().Sessions()
+	if assert.Len(t, sessions, 1) {
+		assert.Len(t, sessions[0].Connections(), 2)
+
+		for _, connection := range sessions[0].Connections() {
+			assert.Same(t, sessions[0].Socket, connection.Socket)
+		}
+	}
+
+	assert.NoError(t, socket.WriteClose(1000, nil))
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-ended:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Connection was not ended when the WebSocket closed")
+		}
+	}
+
+	assert.Equal(t, 0, endpoint.Connections.Size())
+	assert.Empty(t, server.WebSocketServer().Sessions())
+}
+
+func TestWebSocketServerShutdownWhileListening(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// * Shutdown may run before or after the listener starts, and must stop it either way
+	for i := 0; i < 10; i++ {
+		server := NewPRUDPServer()
+		stopped := make(chan struct{})
+
+		go func() {
+			defer close(stopped)
+			server.ListenWebSocket(0)
+		}()
+
+		assert.NoError(t, server.Shutdown(ctx))
+
+		select {
+		case <-stopped:
+		case <-time.After(5 * time.Second):
+			t.Fatal("ListenWebSocket did not return after Shutdown")
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package nex
+
+import (
+	"net"
+
+	"github.com/lxzan/gws"
+)
+
+// WebSocketSession is a single WebSocket connection to the server.
+//
+// PRUDPLite multiplexes many virtual connections, one per stream, over a single WebSocket. The session owns
+// all of them, so they are all closed together when the WebSocket closes, and writes to any of them go
+// straight to the WebSocket they came from
+type WebSocketSession struct {
+	Socket      *SocketConnection // * Shared by every PRUDPConnection on the WebSocket
+	conn        *gws.Conn
+	connections *MutexMap[*PRUDPConnection, struct{}]
+}
+
+// Address returns the remote address of the WebSocket
+func (wss *WebSocketSession) Address() net.Addr {
+	return wss.Socket.Address
+}
+
+// Connections returns the PRUDPLite connections currently open on the WebSocket
+func (wss *WebSocketSession) Connections() []*PRUDPConnection {
+	connections := make([]*PRUDPConnection, 0, wss.connections.Size())
+
+	wss.connections.Each(func(connection *PRUDPConnection, _ struct{}) bool {
+		connections = append(connections, connection)
+		return false
+	})
+
+	return connections
+}
+
+// Close sends a close frame to the client. Once the WebSocket closes, all of it's connections are cleaned up
+func (wss *WebSocketSession) Close(code uint16, reason string) error {
+	return wss.conn.WriteClose(code, []byte(reason))
+}
+
+func (wss *WebSocketSession) write(data []byte) error {
+	return wss.conn.WriteMessage(gws.OpcodeBinary, data)
+}
+
+func (wss *WebSocketSession) addConnection(connection *PRUDPConnection) {
+	wss.connections.Set(connection, struct{}{})
+}
+
+func (wss *WebSocketSession) removeConnection(connection *PRUDPConnection) {
+	wss.connections.Delete(connection)
+}
+
+// closeConnections cleans up every connection on the WebSocket from it's endpoint
+func (wss *WebSocketSession) closeConnections() {
+	// * cleanupConnection removes the connection from the session,
+	// * so the connections can not be cleaned up while iterating
+	for _, connection := range wss.Connections() {
+		connection.endpoint.cleanupConnection(connection) // * "removed" event is dispatched here
+	}
+}
+
+// newWebSocketSession creates a new WebSocketSession for a WebSocket accepted by the server
+func newWebSocketSession(server *PRUDPServer, conn *gws.Conn) *WebSocketSession {
+	wss := &WebSocketSession{
+		conn:        conn,
+		connections: NewMutexMap[*PRUDPConnection, struct{}](),
+	}
+
+	wss.Socket = NewSocketConnection(server, conn.RemoteAddr(), conn)
+	wss.Socket.session = wss
+
+	return wss
+}
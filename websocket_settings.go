@@ -0,0 +1,33 @@
+package nex
+
+import (
+	"crypto/tls"
+	"time"
+)
+
+// WebSocketSettings defines settings for the PRUDPLite WebSocket server
+type WebSocketSettings struct {
+	Path                string        // * Path the server accepts WebSocket connections on when started with ListenWebSocket. Defaults to "/"
+	AllowedOrigins      []string      // * Origins allowed to open a connection, such as "https://example.com". "*" allows any origin. Requests without an Origin header are always allowed. If empty, all origins are allowed
+	SubProtocols        []string      // * Subprotocols the server supports, in order of preference. Connections which request none of them are rejected
+	TLSConfig           *tls.Config   // * TLS config used by ListenWebSocketSecure
+	ReadBufferSize      int           // * Size of the read buffer of each connection
+	ReadMaxPayloadSize  int           // * Largest message accepted from a client. Connections which send larger messages are closed
+	WriteMaxPayloadSize int           // * Largest message which may be sent to a client
+	HandshakeTimeout    time.Duration // * How long the upgrade handshake may take
+	PingInterval        time.Duration // * How often clients are expected to send a ping
+	PingWait            time.Duration // * How long after PingInterval to wait for a ping before the connection is closed
+}
+
+// NewWebSocketSettings returns a new WebSocketSettings
+func NewWebSocketSettings() *WebSocketSettings {
+	return &WebSocketSettings{
+		Path:                "/",
+		ReadBufferSize:      64000,
+		ReadMaxPayloadSize:  16 * 1024 * 1024, // * The gws default
+		WriteMaxPayloadSize: 16 * 1024 * 1024,
+		HandshakeTimeout:    5 * time.Second,
+		PingInterval:        5 * time.Second,
+		PingWait:            10 * time.Second,
+	}
+}